@@ -1,5 +1,11 @@
 package api
 
+import (
+	"strconv"
+
+	"sigs.k8s.io/kube-scheduler-wasm-extension/kubernetes/proto/resource"
+)
+
 // ImageStateSummary provides summarized information about the state of an image.
 type ImageStateSummary struct {
 	// Size of the image
@@ -7,3 +13,92 @@ type ImageStateSummary struct {
 	// Used to track how many nodes have this image
 	NumNodes uint32
 }
+
+// DefaultBindAllHostIP defines the default ip address used to bind to all host.
+const DefaultBindAllHostIP = "0.0.0.0"
+
+// Resource is a collection of compute resources, like framework.Resource.
+type Resource struct {
+	MilliCPU         int64
+	Memory           int64
+	EphemeralStorage int64
+	AllowedPodNumber int
+	// ScalarResources are any other resources, such as extended resources,
+	// keyed by resource name.
+	ScalarResources map[string]int64
+}
+
+// ResourceList returns the resources as quantities keyed by resource name,
+// like v1.ResourceList. Zero values are omitted.
+func (r *Resource) ResourceList() map[string]*resource.Quantity {
+	list := make(map[string]*resource.Quantity, 4+len(r.ScalarResources))
+	add := func(name, value string, ok bool) {
+		if ok {
+			list[name] = &resource.Quantity{String_: &value}
+		}
+	}
+	add("cpu", strconv.FormatInt(r.MilliCPU, 10)+"m", r.MilliCPU != 0)
+	add("memory", strconv.FormatInt(r.Memory, 10), r.Memory != 0)
+	add("ephemeral-storage", strconv.FormatInt(r.EphemeralStorage, 10), r.EphemeralStorage != 0)
+	add("pods", strconv.Itoa(r.AllowedPodNumber), r.AllowedPodNumber != 0)
+	for name, v := range r.ScalarResources {
+		add(name, strconv.FormatInt(v, 10), v != 0)
+	}
+	return list
+}
+
+// ProtocolPort represents a protocol port pair, e.g. tcp:80.
+type ProtocolPort struct {
+	Protocol string
+	Port     int32
+}
+
+// HostPortInfo stores mapping from ip to a set of ProtocolPort, like
+// framework.HostPortInfo.
+type HostPortInfo map[string]map[ProtocolPort]struct{}
+
+// Len returns the total number of (ip, protocol, port) tuple in HostPortInfo
+func (h HostPortInfo) Len() int {
+	length := 0
+	for _, m := range h {
+		length += len(m)
+	}
+	return length
+}
+
+// CheckConflict checks if the input (ip, protocol, port) conflicts with the existing
+// ones in HostPortInfo.
+func (h HostPortInfo) CheckConflict(ip, protocol string, port int32) bool {
+	if port <= 0 {
+		return false
+	}
+
+	if len(ip) == 0 {
+		ip = DefaultBindAllHostIP
+	}
+	if len(protocol) == 0 {
+		protocol = "TCP"
+	}
+	pp := ProtocolPort{Protocol: protocol, Port: port}
+
+	// If ip is 0.0.0.0 check all IP's (protocol, port) pair
+	if ip == DefaultBindAllHostIP {
+		for _, m := range h {
+			if _, ok := m[pp]; ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	// If ip isn't 0.0.0.0, only check IP and 0.0.0.0's (protocol, port) pair
+	for _, key := range []string{DefaultBindAllHostIP, ip} {
+		if m, ok := h[key]; ok {
+			if _, ok2 := m[pp]; ok2 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,85 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package api
+
+import (
+	"testing"
+)
+
+func TestHostPortInfo_CheckConflict(t *testing.T) {
+	h := HostPortInfo{
+		"127.0.0.1":          {{Protocol: "TCP", Port: 80}: {}},
+		DefaultBindAllHostIP: {{Protocol: "UDP", Port: 53}: {}},
+	}
+
+	tests := []struct {
+		name     string
+		ip       string
+		protocol string
+		port     int32
+		expected bool
+	}{
+		{name: "same ip", ip: "127.0.0.1", protocol: "TCP", port: 80, expected: true},
+		{name: "default protocol", ip: "127.0.0.1", port: 80, expected: true},
+		{name: "different protocol", ip: "127.0.0.1", protocol: "UDP", port: 80},
+		{name: "different ip", ip: "127.0.0.2", protocol: "TCP", port: 80},
+		{name: "all ips", ip: DefaultBindAllHostIP, protocol: "TCP", port: 80, expected: true},
+		{name: "default ip", protocol: "TCP", port: 80, expected: true},
+		{name: "bound to all ips", ip: "127.0.0.2", protocol: "UDP", port: 53, expected: true},
+		{name: "no port", ip: "127.0.0.1", protocol: "TCP"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if want, have := tc.expected, h.CheckConflict(tc.ip, tc.protocol, tc.port); want != have {
+				t.Fatalf("unexpected conflict: %v != %v", want, have)
+			}
+		})
+	}
+
+	if want, have := 2, h.Len(); want != have {
+		t.Fatalf("unexpected len: %v != %v", want, have)
+	}
+}
+
+func TestResource_ResourceList(t *testing.T) {
+	r := &Resource{
+		MilliCPU:         1500,
+		Memory:           1024,
+		AllowedPodNumber: 110,
+		ScalarResources:  map[string]int64{"example.com/gpu": 2, "example.com/none": 0},
+	}
+
+	have := map[string]string{}
+	for name, q := range r.ResourceList() {
+		have[name] = q.GetString_()
+	}
+	want := map[string]string{
+		"cpu":             "1500m",
+		"memory":          "1024",
+		"pods":            "110",
+		"example.com/gpu": "2",
+	}
+	if len(want) != len(have) {
+		t.Fatalf("unexpected resources: %v != %v", want, have)
+	}
+	for name, v := range want {
+		if have[name] != v {
+			t.Fatalf("unexpected %s: %v != %v", name, v, have[name])
+		}
+	}
+}
@@ -17,6 +17,8 @@
 package api
 
 import (
+	"time"
+
 	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/api/proto"
 )
 
@@ -38,11 +40,69 @@ type CycleState interface {
 	Delete(key string)
 }
 
+// StateData is optionally implemented by values written to CycleState.
+//
+// The scheduler clones CycleState when it filters with nominated pods or
+// selects victims for preemption. Values that don't implement StateData are
+// shared by the copies, so must not be changed once written.
+type StateData interface {
+	// Clone returns a copy of the value, for a copy of CycleState.
+	Clone() StateData
+}
+
 // Plugin is a WebAssembly implementation of framework.Plugin.
 type Plugin interface {
 	// This doesn't define `Name() string`. See /RATIONALE.md for impact
 }
 
+// PreEnqueuePlugin is a WebAssembly implementation of
+// framework.PreEnqueuePlugin. A pod is only added to the active queue when
+// all PreEnqueue plugins return success.
+//
+// # Notes
+//
+//   - This is not a part of the scheduling cycle, so CycleState is not
+//     available.
+//   - The pod parameter is lazy to avoid unmarshal overhead when unused.
+type PreEnqueuePlugin interface {
+	Plugin
+
+	PreEnqueue(pod proto.Pod) *Status
+}
+
+// QueueSortPlugin is a WebAssembly implementation of
+// framework.QueueSortPlugin.
+//
+// # Notes
+//
+//   - Less is called very frequently, on every change to the scheduling
+//     queue. Decoded pods are cached by UID and resource version, so prefer
+//     reading QueuedPodInfo fields before the pod where possible.
+//   - The host falls back to the default order, by priority then timestamp,
+//     if the guest fails.
+type QueueSortPlugin interface {
+	Plugin
+
+	Less(podInfo1, podInfo2 QueuedPodInfo) bool
+}
+
+// QueuedPodInfo is a WebAssembly implementation of framework.QueuedPodInfo.
+type QueuedPodInfo interface {
+	// Pod is the queued pod. It is lazy to avoid unmarshal overhead when
+	// unused.
+	Pod() proto.Pod
+
+	// Timestamp is when the pod was added to the scheduling queue.
+	Timestamp() time.Time
+
+	// Attempts is the number of schedule attempts.
+	Attempts() int
+
+	// InitialAttemptTimestamp is when the pod was first added to the
+	// scheduling queue, or zero if unknown.
+	InitialAttemptTimestamp() time.Time
+}
+
 // PreFilterPlugin is a WebAssembly implementation of
 // framework.PreFilterPlugin. When non-nil, the `nodeNames` result contains a
 // unique set of node names to process.
@@ -98,6 +158,31 @@ type EnqueueExtensions interface {
 	EventsToRegister() []ClusterEvent
 }
 
+// QueueingHintExtensions is an EnqueueExtensions which decides whether each
+// registered event may make a rejected pod schedulable. It is the WebAssembly
+// implementation of framework.QueueingHintFn.
+//
+// Note: oldObj and newObj are nil when the event has none, such as oldObj on
+// Add. They are also nil unless the event's Resource is Pod or Node.
+type QueueingHintExtensions interface {
+	EnqueueExtensions
+
+	QueueingHint(event ClusterEvent, pod proto.Pod, oldObj, newObj proto.KObject) QueueingHint
+}
+
+// QueueingHint is the result of QueueingHintExtensions.QueueingHint.
+type QueueingHint uint32
+
+// These are predefined queueing hints
+const (
+	// QueueSkip means the event doesn't make the pod schedulable, so it
+	// stays in the unschedulable queue.
+	QueueSkip QueueingHint = iota
+	// Queue means the event may make the pod schedulable, so it is moved to
+	// the active or backoff queue.
+	Queue
+)
+
 // PreScorePlugin is a WebAssembly implementation of framework.PreScorePlugin.
 //
 // Note: The pod and nodeList parameters are lazy to avoid unmarshal overhead
@@ -176,6 +261,24 @@ type NodeInfo interface {
 	Node() proto.Node
 	ImageStates() map[string]*ImageStateSummary
 
+	// Pods returns the pods on the node.
+	Pods() []proto.Pod
+	// PodsWithAffinity returns the pods on the node with affinity.
+	PodsWithAffinity() []proto.Pod
+	// PodsWithRequiredAntiAffinity returns the pods on the node with required
+	// anti-affinity.
+	PodsWithRequiredAntiAffinity() []proto.Pod
+
+	// Requested returns the total resources requested by pods on the node.
+	Requested() *Resource
+	// NonZeroRequested is like Requested, except pods that don't request CPU
+	// or memory count with the defaults of the scheduler.
+	NonZeroRequested() *Resource
+	// Allocatable returns the resources of the node available for pods.
+	Allocatable() *Resource
+	// UsedPorts returns the host ports used by pods on the node.
+	UsedPorts() HostPortInfo
+
 	// ... we'll support more fields of NodeInfo of the scheduling framework.
 }
 
@@ -23,23 +23,39 @@ import (
 	"unsafe"
 
 	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/api"
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/api/proto"
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/internal/imports"
 	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/internal/plugin"
+	internalproto "sigs.k8s.io/kube-scheduler-wasm-extension/guest/internal/proto"
+	protoapi "sigs.k8s.io/kube-scheduler-wasm-extension/kubernetes/proto/api"
 )
 
 // enqueue is the current plugin assigned with SetPlugin.
 var enqueue api.EnqueueExtensions
 
+// queueingHint is set when enqueue also implements
+// api.QueueingHintExtensions.
+var queueingHint api.QueueingHintExtensions
+
+// clusterEvents are the events returned by enqueue, indexed by the host when
+// calling queueinghint.
+var clusterEvents []api.ClusterEvent
+
 // SetPlugin is exposed to prevent package cycles.
 func SetPlugin(enqueueExtensions api.EnqueueExtensions) {
 	if enqueueExtensions == nil {
 		panic("nil enqueueExtensions")
 	}
 	enqueue = enqueueExtensions
+	queueingHint, _ = enqueueExtensions.(api.QueueingHintExtensions)
 	plugin.MustSet(enqueueExtensions)
 }
 
 // prevent unused lint errors (lint is run with normal go).
-var _ func() = _enqueue
+var (
+	_ func()              = _enqueue
+	_ func(uint32) uint32 = _queueinghint
+)
 
 // enqueue is only exported to the host.
 //
@@ -51,7 +67,7 @@ func _enqueue() {
 		return
 	}
 
-	clusterEvents := enqueue.EventsToRegister()
+	clusterEvents = enqueue.EventsToRegister()
 
 	// If plugin returned clusterEvents, encode them and call the host with the
 	// count and memory region.
@@ -63,3 +79,62 @@ func _enqueue() {
 		runtime.KeepAlive(encoded) // until ptr is no longer needed.
 	}
 }
+
+// queueinghint is only exported to the host.
+//
+//export queueinghint
+func _queueinghint(eventIndex uint32) uint32 {
+	if queueingHint == nil { // Then, the user didn't define one.
+		return uint32(api.Queue)
+	}
+
+	// The host may call a different guest instance than the one it called
+	// enqueue on, so get the events again if needed.
+	if clusterEvents == nil {
+		clusterEvents = enqueue.EventsToRegister()
+	}
+	if int(eventIndex) >= len(clusterEvents) {
+		panic("unexpected event index")
+	}
+	event := clusterEvents[eventIndex]
+
+	var msg protoapi.Pod
+	if err := imports.CurrentPod(msg.UnmarshalVT); err != nil {
+		panic(err.Error())
+	}
+	pod := &internalproto.Pod{Msg: &msg}
+
+	oldObj := eventObject(event.Resource, imports.EventOldObject)
+	newObj := eventObject(event.Resource, imports.EventNewObject)
+	return uint32(queueingHint.QueueingHint(event, pod, oldObj, newObj))
+}
+
+// eventObject decodes the object of the current event, or returns nil if
+// there is none or its resource isn't a Pod or Node.
+func eventObject(resource api.GVK, get func(func([]byte) error) error) (obj proto.KObject) {
+	var err error
+	switch resource {
+	case api.Pod:
+		err = get(func(b []byte) error {
+			if len(b) == 0 {
+				return nil
+			}
+			var msg protoapi.Pod
+			obj = &internalproto.Pod{Msg: &msg}
+			return msg.UnmarshalVT(b)
+		})
+	case api.Node:
+		err = get(func(b []byte) error {
+			if len(b) == 0 {
+				return nil
+			}
+			var msg protoapi.Node
+			obj = &internalproto.Node{Msg: &msg}
+			return msg.UnmarshalVT(b)
+		})
+	}
+	if err != nil {
+		panic(err.Error())
+	}
+	return
+}
@@ -0,0 +1,37 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package handle
+
+import (
+	"runtime"
+
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/internal/mem"
+)
+
+// Activate moves the pods, keyed like "namespace/name", to the active queue,
+// if they are in the unschedulable or backoff queue. For example, a Reserve
+// or PostBind plugin can use this to retry pods waiting for capacity it freed,
+// such as members of the same gang. Pods which don't exist are ignored.
+func Activate(podKeys ...string) {
+	cString := mem.ToNULTerminated(podKeys)
+	if cString == nil {
+		return
+	}
+	ptr, size := mem.BytesToPtr(cString)
+	activate(ptr, size)
+	runtime.KeepAlive(cString) // until ptr is no longer needed.
+}
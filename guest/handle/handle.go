@@ -14,7 +14,8 @@
    limitations under the License.
 */
 
-// Package handle exports an api.RejectWaitingPod and GetWaitingPod to the host.
+// Package handle exports an api.RejectWaitingPod, GetWaitingPod,
+// NominatedPodsForNode and Activate to the host.
 // Only import this package when setting Plugin, as doing otherwise will cause overhead.
 package handle
 
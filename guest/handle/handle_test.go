@@ -0,0 +1,40 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package handle_test
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/handle"
+)
+
+func ExampleNominatedPodsForNode() {
+	// The stub outside TinyGo never returns any pods.
+	pods := handle.NominatedPodsForNode("node-1")
+	fmt.Println(len(pods))
+
+	// Output:
+	// 0
+}
+
+func ExampleActivate() {
+	// Retry other members of the gang, which were waiting for this one.
+	handle.Activate("default/gang-member-1", "default/gang-member-2")
+
+	// Output:
+	//
+}
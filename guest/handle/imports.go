@@ -25,3 +25,9 @@ func getWaitingPod(input_ptr, input_size, ptr uint32, limit mem.BufLimit)
 
 //go:wasmimport k8s.io/scheduler handle.reject_waiting_pod
 func rejectWaitingPod(input_ptr, input_size, ptr uint32, limit mem.BufLimit)
+
+//go:wasmimport k8s.io/scheduler handle.nominated_pods_for_node
+func nominatedPodsForNode(nodename, nodename_len, ptr uint32, limit mem.BufLimit) (len uint32)
+
+//go:wasmimport k8s.io/scheduler handle.activate
+func activate(ptr, size uint32)
@@ -25,3 +25,9 @@ func getWaitingPod(uint32, uint32, uint32, mem.BufLimit) {}
 
 // rejectWaitingPod is stubbed for compilation outside TinyGo.
 func rejectWaitingPod(uint32, uint32, uint32, mem.BufLimit) {}
+
+// nominatedPodsForNode is stubbed for compilation outside TinyGo.
+func nominatedPodsForNode(uint32, uint32, uint32, mem.BufLimit) (len uint32) { return }
+
+// activate is stubbed for compilation outside TinyGo.
+func activate(uint32, uint32) {}
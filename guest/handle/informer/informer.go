@@ -0,0 +1,151 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package informer gets and lists objects from the scheduler's informers.
+// Only resources in the informers of the plugin config are visible, and
+// others panic.
+//
+// Resources are formatted like group/version/resource, or version/resource
+// for the core group. For example, "v1/namespaces" or
+// "example.com/v1alpha1/tenants".
+//
+// # Notes
+//
+//   - Resources not built into the scheduler, such as custom resources, use a
+//     dynamic informer which the scheduler doesn't wait for. Until it syncs,
+//     Get and GetJSON don't find objects, and List and ListJSON return none.
+package informer
+
+import (
+	"encoding/json"
+	"errors"
+	"runtime"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/internal/mem"
+)
+
+const (
+	encodingProtobuf uint32 = iota
+	encodingJSON
+)
+
+// Get calls unmarshal with the protobuf encoding of the object with the given
+// namespace and name, returning false if it isn't found. The namespace is
+// empty for cluster-scoped resources.
+//
+// Only built-in resources support protobuf. For example:
+//
+//	var ns protoapi.Namespace
+//	found, err := informer.Get("v1/namespaces", "", "default", ns.UnmarshalVT)
+func Get(resource, namespace, name string, unmarshal func([]byte) error) (found bool, err error) {
+	err = get(resource, namespace, name, encodingProtobuf, func(data []byte) error {
+		if found = len(data) > 0; !found {
+			return nil
+		}
+		return unmarshal(data)
+	})
+	return
+}
+
+// GetJSON is like Get, except it decodes the JSON encoding of the object into
+// v. This supports any resource, including custom resources.
+func GetJSON(resource, namespace, name string, v any) (found bool, err error) {
+	err = get(resource, namespace, name, encodingJSON, func(data []byte) error {
+		if found = len(data) > 0; !found {
+			return nil
+		}
+		return json.Unmarshal(data, v)
+	})
+	return
+}
+
+// List calls unmarshal with the protobuf encoding of each object matching the
+// label selector, such as "tenant=a". An empty namespace lists objects in all
+// namespaces, and an empty selector matches all objects.
+//
+// Only built-in resources support protobuf. An invalid label selector returns
+// an error.
+func List(resource, namespace, labelSelector string, unmarshal func([]byte) error) error {
+	return list(resource, namespace, labelSelector, encodingProtobuf, func(data []byte) error {
+		// Each object is a bytes field 1, like a repeated field in a message.
+		for len(data) > 0 {
+			_, typ, n := protowire.ConsumeTag(data)
+			if n < 0 || typ != protowire.BytesType {
+				return errors.New("invalid list encoding")
+			}
+			data = data[n:]
+			item, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return errors.New("invalid list encoding")
+			}
+			data = data[n:]
+			if err := unmarshal(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListJSON is like List, except it decodes the JSON array of objects into v,
+// such as a pointer to a slice. This supports any resource, including custom
+// resources.
+func ListJSON(resource, namespace, labelSelector string, v any) error {
+	return list(resource, namespace, labelSelector, encodingJSON, func(data []byte) error {
+		if len(data) == 0 {
+			return nil // no objects
+		}
+		return json.Unmarshal(data, v)
+	})
+}
+
+func get(resource, namespace, name string, encoding uint32, updater func([]byte) error) error {
+	resourcePtr, resourceSize := mem.StringToPtr(resource)
+	namespacePtr, namespaceSize := mem.StringToPtr(namespace)
+	namePtr, nameSize := mem.StringToPtr(name)
+	// Wrap to avoid TinyGo 0.28: cannot use an exported function as value
+	err := mem.Update(func(ptr uint32, limit mem.BufLimit) (len uint32) {
+		return informerGet(resourcePtr, resourceSize, namespacePtr, namespaceSize, namePtr, nameSize, encoding, ptr, limit)
+	}, updater)
+	runtime.KeepAlive(resource) // until ptrs are no longer needed.
+	runtime.KeepAlive(namespace)
+	runtime.KeepAlive(name)
+	return err
+}
+
+func list(resource, namespace, labelSelector string, encoding uint32, updater func([]byte) error) error {
+	resourcePtr, resourceSize := mem.StringToPtr(resource)
+	namespacePtr, namespaceSize := mem.StringToPtr(namespace)
+	selectorPtr, selectorSize := mem.StringToPtr(labelSelector)
+	var invalid bool
+	// Wrap to avoid TinyGo 0.28: cannot use an exported function as value
+	err := mem.Update(func(ptr uint32, limit mem.BufLimit) (len uint32) {
+		errLen := informerList(resourcePtr, resourceSize, namespacePtr, namespaceSize, selectorPtr, selectorSize, encoding, ptr, limit)
+		invalid = errLen>>32 == 1
+		return uint32(errLen)
+	}, func(data []byte) error {
+		if invalid {
+			return errors.New(string(data))
+		}
+		return updater(data)
+	})
+	runtime.KeepAlive(resource) // until ptrs are no longer needed.
+	runtime.KeepAlive(namespace)
+	runtime.KeepAlive(labelSelector)
+	return err
+}
@@ -0,0 +1,55 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package informer_test
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/handle/informer"
+	protoapi "sigs.k8s.io/kube-scheduler-wasm-extension/kubernetes/proto/api"
+)
+
+func ExampleGet() {
+	var ns protoapi.Namespace
+	found, err := informer.Get("v1/namespaces", "", "default", ns.UnmarshalVT)
+	if err != nil {
+		panic(err)
+	}
+	// The stub outside TinyGo never finds anything.
+	fmt.Println(found)
+
+	// Output:
+	// false
+}
+
+func ExampleListJSON() {
+	// Tenant is the subset of a custom resource used by the plugin.
+	type Tenant struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+	}
+
+	var tenants []Tenant
+	if err := informer.ListJSON("example.com/v1alpha1/tenants", "", "tier=gold", &tenants); err != nil {
+		panic(err)
+	}
+	fmt.Println(len(tenants))
+
+	// Output:
+	// 0
+}
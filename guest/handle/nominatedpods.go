@@ -0,0 +1,49 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package handle
+
+import (
+	"runtime"
+
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/api/proto"
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/internal/mem"
+	internalproto "sigs.k8s.io/kube-scheduler-wasm-extension/guest/internal/proto"
+	protoapi "sigs.k8s.io/kube-scheduler-wasm-extension/kubernetes/proto/api"
+)
+
+// NominatedPodsForNode returns pods that are nominated to run on the given
+// node, but may not be running on it yet. Filter plugins can use this to
+// account for resources that preemption freed for these pods.
+func NominatedPodsForNode(nodeName string) []proto.Pod {
+	nodenamePtr, nodenameSize := mem.StringToPtr(nodeName)
+
+	var msg protoapi.PodList
+	// Wrap to avoid TinyGo 0.28: cannot use an exported function as value
+	err := mem.Update(func(ptr uint32, limit mem.BufLimit) (len uint32) {
+		return nominatedPodsForNode(nodenamePtr, nodenameSize, ptr, limit)
+	}, msg.UnmarshalVT)
+	runtime.KeepAlive(nodeName) // until nodenamePtr is no longer needed.
+	if err != nil {
+		panic(err)
+	}
+
+	pods := make([]proto.Pod, len(msg.Items))
+	for i := range msg.Items {
+		pods[i] = &internalproto.Pod{Msg: msg.Items[i]}
+	}
+	return pods
+}
@@ -6,6 +6,14 @@ import (
 
 type SharedLister interface {
 	NodeInfos() guestapi.NodeInfoList
+	StorageInfos() StorageInfoLister
+}
+
+// StorageInfoLister is a WebAssembly implementation of framework.StorageInfoLister.
+type StorageInfoLister interface {
+	// IsPVCUsedByPods returns true/false on whether the PVC is used by one or
+	// more scheduled pods, keyed in the format "namespace/name".
+	IsPVCUsedByPods(key string) bool
 }
 
 type UnimplementedSharedLister struct{}
@@ -13,3 +21,7 @@ type UnimplementedSharedLister struct{}
 func (UnimplementedSharedLister) NodeInfos() guestapi.NodeInfoList {
 	return nil
 }
+
+func (UnimplementedSharedLister) StorageInfos() StorageInfoLister {
+	return nil
+}
@@ -1,11 +1,29 @@
 package internal
 
-import guestapi "sigs.k8s.io/kube-scheduler-wasm-extension/guest/api"
+import (
+	guestapi "sigs.k8s.io/kube-scheduler-wasm-extension/guest/api"
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/handle/sharedlister/api"
+)
 
 type SharedLister struct {
-	NodeInfoList guestapi.NodeInfoList
+	NodeInfoList      guestapi.NodeInfoList
+	StorageInfoLister api.StorageInfoLister
 }
 
 func (s SharedLister) NodeInfos() guestapi.NodeInfoList {
 	return s.NodeInfoList
 }
+
+func (s SharedLister) StorageInfos() api.StorageInfoLister {
+	return s.StorageInfoLister
+}
+
+// StorageInfoLister implements api.StorageInfoLister with the host function
+// IsPVCUsedByPodsFn.
+type StorageInfoLister struct {
+	IsPVCUsedByPodsFn func(key string) bool
+}
+
+func (s StorageInfoLister) IsPVCUsedByPods(key string) bool {
+	return s.IsPVCUsedByPodsFn(key)
+}
@@ -1,14 +1,20 @@
 package sharedlister
 
 import (
+	"runtime"
+
 	guestapi "sigs.k8s.io/kube-scheduler-wasm-extension/guest/api"
 	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/handle/sharedlister/api"
 	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/handle/sharedlister/internal"
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/internal/mem"
 	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/internal/prefilter"
 )
 
 var sharedListerInstance api.SharedLister = &internal.SharedLister{
 	NodeInfoList: prefilter.Nodes,
+	StorageInfoLister: &internal.StorageInfoLister{
+		IsPVCUsedByPodsFn: IsPVCUsedByPodsFn,
+	},
 }
 
 func Get() api.SharedLister {
@@ -19,3 +25,15 @@ func Get() api.SharedLister {
 func NodeInfos() guestapi.NodeInfoList {
 	return sharedListerInstance.NodeInfos()
 }
+
+// StorageInfos is a convenience that calls the same method documented on api.StorageInfos.
+func StorageInfos() api.StorageInfoLister {
+	return sharedListerInstance.StorageInfos()
+}
+
+func IsPVCUsedByPodsFn(key string) bool {
+	ptr, size := mem.StringToPtr(key)
+	used := isPVCUsedByPods(ptr, size)
+	runtime.KeepAlive(key) // until ptr is no longer needed.
+	return used == 1
+}
@@ -10,3 +10,10 @@ func ExampleNodeInfos() {
 	// Output:
 	//
 }
+
+func ExampleStorageInfos() {
+	sharedlister.StorageInfos().IsPVCUsedByPods("default/my-pvc")
+
+	// Output:
+	//
+}
@@ -0,0 +1,81 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package api includes the storage.k8s.io types a guest can look up.
+//
+// Note: These are decoded from JSON and only include fields needed for
+// scheduling. Unlike core types, there's no generated code for storage.k8s.io
+// types in kubernetes/proto, because the update-kubernetes-proto target in the
+// Makefile only generates core and apimachinery protos. Adding them requires
+// regenerating with protoc from the kubernetes submodule, after adding their
+// generated.proto to kubernetes/kubernetes.checkout.
+package api
+
+// ObjectMeta is the subset of metav1.ObjectMeta used for scheduling.
+type ObjectMeta struct {
+	Name        string            `json:"name"`
+	UID         string            `json:"uid,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// StorageClass is the subset of storagev1.StorageClass used for scheduling.
+type StorageClass struct {
+	Metadata             ObjectMeta             `json:"metadata"`
+	Provisioner          string                 `json:"provisioner"`
+	Parameters           map[string]string      `json:"parameters,omitempty"`
+	ReclaimPolicy        *string                `json:"reclaimPolicy,omitempty"`
+	AllowVolumeExpansion *bool                  `json:"allowVolumeExpansion,omitempty"`
+	VolumeBindingMode    *string                `json:"volumeBindingMode,omitempty"`
+	AllowedTopologies    []TopologySelectorTerm `json:"allowedTopologies,omitempty"`
+}
+
+// TopologySelectorTerm is the same as v1.TopologySelectorTerm.
+type TopologySelectorTerm struct {
+	MatchLabelExpressions []TopologySelectorLabelRequirement `json:"matchLabelExpressions,omitempty"`
+}
+
+// TopologySelectorLabelRequirement is the same as v1.TopologySelectorLabelRequirement.
+type TopologySelectorLabelRequirement struct {
+	Key    string   `json:"key"`
+	Values []string `json:"values"`
+}
+
+// CSINode is the subset of storagev1.CSINode used for scheduling.
+type CSINode struct {
+	Metadata ObjectMeta  `json:"metadata"`
+	Spec     CSINodeSpec `json:"spec"`
+}
+
+// CSINodeSpec is the same as storagev1.CSINodeSpec.
+type CSINodeSpec struct {
+	Drivers []CSINodeDriver `json:"drivers"`
+}
+
+// CSINodeDriver is the same as storagev1.CSINodeDriver.
+type CSINodeDriver struct {
+	Name         string               `json:"name"`
+	NodeID       string               `json:"nodeID"`
+	TopologyKeys []string             `json:"topologyKeys"`
+	Allocatable  *VolumeNodeResources `json:"allocatable,omitempty"`
+}
+
+// VolumeNodeResources is the same as storagev1.VolumeNodeResources.
+type VolumeNodeResources struct {
+	// Count is the maximum number of unique volumes managed by the CSI driver
+	// that can be used on a node, or nil if unbounded.
+	Count *int32 `json:"count,omitempty"`
+}
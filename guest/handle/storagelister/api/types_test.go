@@ -0,0 +1,72 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// TestCSINode_JSON ensures the types decode the JSON encoding of the host.
+func TestCSINode_JSON(t *testing.T) {
+	// This is the JSON encoding of a storagev1.CSINode.
+	b := []byte(`{"metadata":{"name":"node","uid":"uid","creationTimestamp":null},"spec":{"drivers":[{"name":"csi.example.com","nodeID":"node","topologyKeys":["topology.example.com/zone"],"allocatable":{"count":10}}]}}`)
+
+	var have CSINode
+	if err := json.Unmarshal(b, &have); err != nil {
+		t.Fatal(err)
+	}
+
+	count := int32(10)
+	want := CSINode{
+		Metadata: ObjectMeta{Name: "node", UID: "uid"},
+		Spec: CSINodeSpec{Drivers: []CSINodeDriver{{
+			Name:         "csi.example.com",
+			NodeID:       "node",
+			TopologyKeys: []string{"topology.example.com/zone"},
+			Allocatable:  &VolumeNodeResources{Count: &count},
+		}}},
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Fatalf("unexpected csi node: %+v != %+v", want, have)
+	}
+}
+
+// TestStorageClass_JSON ensures the types decode the JSON encoding of the host.
+func TestStorageClass_JSON(t *testing.T) {
+	// This is the JSON encoding of a storagev1.StorageClass.
+	b := []byte(`{"metadata":{"name":"sc","creationTimestamp":null},"provisioner":"csi.example.com","volumeBindingMode":"WaitForFirstConsumer","allowedTopologies":[{"matchLabelExpressions":[{"key":"topology.example.com/zone","values":["a"]}]}]}`)
+
+	var have StorageClass
+	if err := json.Unmarshal(b, &have); err != nil {
+		t.Fatal(err)
+	}
+
+	mode := "WaitForFirstConsumer"
+	want := StorageClass{
+		Metadata:          ObjectMeta{Name: "sc"},
+		Provisioner:       "csi.example.com",
+		VolumeBindingMode: &mode,
+		AllowedTopologies: []TopologySelectorTerm{{
+			MatchLabelExpressions: []TopologySelectorLabelRequirement{{Key: "topology.example.com/zone", Values: []string{"a"}}},
+		}},
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Fatalf("unexpected storage class: %+v != %+v", want, have)
+	}
+}
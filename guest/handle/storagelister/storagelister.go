@@ -0,0 +1,123 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package storagelister looks up storage objects from the scheduler's
+// informers, such as volumes and CSI drivers installed on a node.
+package storagelister
+
+import (
+	"encoding/json"
+	"runtime"
+
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/handle/storagelister/api"
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/internal/mem"
+	protoapi "sigs.k8s.io/kube-scheduler-wasm-extension/kubernetes/proto/api"
+)
+
+// PersistentVolumeClaim returns the PersistentVolumeClaim with the given
+// namespace and name, or nil if it isn't found.
+func PersistentVolumeClaim(namespace, name string) *protoapi.PersistentVolumeClaim {
+	namespacePtr, namespaceSize := mem.StringToPtr(namespace)
+	namePtr, nameSize := mem.StringToPtr(name)
+
+	var pvc *protoapi.PersistentVolumeClaim
+	// Wrap to avoid TinyGo 0.28: cannot use an exported function as value
+	err := mem.Update(func(ptr uint32, limit mem.BufLimit) (len uint32) {
+		return getPersistentVolumeClaim(namespacePtr, namespaceSize, namePtr, nameSize, ptr, limit)
+	}, func(data []byte) error {
+		if len(data) == 0 {
+			return nil // not found
+		}
+		pvc = &protoapi.PersistentVolumeClaim{}
+		return pvc.UnmarshalVT(data)
+	})
+	runtime.KeepAlive(namespace) // until namespacePtr is no longer needed.
+	runtime.KeepAlive(name)      // until namePtr is no longer needed.
+	if err != nil {
+		panic(err)
+	}
+	return pvc
+}
+
+// PersistentVolume returns the PersistentVolume with the given name, or nil
+// if it isn't found.
+func PersistentVolume(name string) *protoapi.PersistentVolume {
+	namePtr, nameSize := mem.StringToPtr(name)
+
+	var pv *protoapi.PersistentVolume
+	// Wrap to avoid TinyGo 0.28: cannot use an exported function as value
+	err := mem.Update(func(ptr uint32, limit mem.BufLimit) (len uint32) {
+		return getPersistentVolume(namePtr, nameSize, ptr, limit)
+	}, func(data []byte) error {
+		if len(data) == 0 {
+			return nil // not found
+		}
+		pv = &protoapi.PersistentVolume{}
+		return pv.UnmarshalVT(data)
+	})
+	runtime.KeepAlive(name) // until namePtr is no longer needed.
+	if err != nil {
+		panic(err)
+	}
+	return pv
+}
+
+// StorageClass returns the StorageClass with the given name, or nil if it
+// isn't found.
+func StorageClass(name string) *api.StorageClass {
+	namePtr, nameSize := mem.StringToPtr(name)
+
+	var sc api.StorageClass
+	// Wrap to avoid TinyGo 0.28: cannot use an exported function as value
+	found := getJSON(func(ptr uint32, limit mem.BufLimit) (len uint32) {
+		return getStorageClass(namePtr, nameSize, ptr, limit)
+	}, &sc)
+	runtime.KeepAlive(name) // until namePtr is no longer needed.
+	if !found {
+		return nil
+	}
+	return &sc
+}
+
+// CSINode returns the CSINode with the given name, which is the same as the
+// node name, or nil if it isn't found.
+func CSINode(name string) *api.CSINode {
+	namePtr, nameSize := mem.StringToPtr(name)
+
+	var csiNode api.CSINode
+	// Wrap to avoid TinyGo 0.28: cannot use an exported function as value
+	found := getJSON(func(ptr uint32, limit mem.BufLimit) (len uint32) {
+		return getCSINode(namePtr, nameSize, ptr, limit)
+	}, &csiNode)
+	runtime.KeepAlive(name) // until namePtr is no longer needed.
+	if !found {
+		return nil
+	}
+	return &csiNode
+}
+
+// getJSON decodes the JSON result of fn into v, returning false if nothing
+// was found.
+func getJSON(fn func(ptr uint32, limit mem.BufLimit) (len uint32), v any) bool {
+	jsonStr := mem.GetString(fn)
+	if jsonStr == "" {
+		return false
+	}
+	if err := json.Unmarshal([]byte(jsonStr), v); err != nil {
+		panic(err)
+	}
+	return true
+}
@@ -0,0 +1,45 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package storagelister_test
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/handle/storagelister"
+)
+
+func ExamplePersistentVolumeClaim() {
+	// The stub outside TinyGo never finds anything.
+	pvc := storagelister.PersistentVolumeClaim("default", "my-pvc")
+	fmt.Println(pvc == nil)
+
+	// Output:
+	// true
+}
+
+func ExampleCSINode() {
+	if csiNode := storagelister.CSINode("node-1"); csiNode != nil {
+		for _, driver := range csiNode.Spec.Drivers {
+			if driver.Allocatable != nil && driver.Allocatable.Count != nil {
+				fmt.Println(driver.Name, *driver.Allocatable.Count)
+			}
+		}
+	}
+
+	// Output:
+	//
+}
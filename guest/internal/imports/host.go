@@ -18,10 +18,17 @@ package imports
 
 import (
 	"encoding/json"
+	"errors"
 	"runtime"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
 
 	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/api"
 	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/internal/mem"
+	protoapi "sigs.k8s.io/kube-scheduler-wasm-extension/kubernetes/proto/api"
+	"sigs.k8s.io/kube-scheduler-wasm-extension/kubernetes/proto/resource"
 )
 
 // StatusToCode returns a WebAssembly compatible result for the input status,
@@ -69,6 +76,186 @@ func NodeImageStates(nodeName string) map[string]*api.ImageStateSummary {
 	return nodeImageStates
 }
 
+// NodeInfoPods calls the updater with the PodList of pods on the node.
+func NodeInfoPods(nodeName string, updater func([]byte) error) error {
+	nodenamePtr, nodenameSize := mem.StringToPtr(nodeName)
+	// Wrap to avoid TinyGo 0.28: cannot use an exported function as value
+	err := mem.Update(func(ptr uint32, limit mem.BufLimit) (len uint32) {
+		return k8sSchedulerNodeInfoPods(nodenamePtr, nodenameSize, ptr, limit)
+	}, updater)
+	runtime.KeepAlive(nodeName) // until nodenamePtr is no longer needed.
+	return err
+}
+
+// NodeInfoPodsWithAffinity calls the updater with the PodList of pods on the
+// node with affinity.
+func NodeInfoPodsWithAffinity(nodeName string, updater func([]byte) error) error {
+	nodenamePtr, nodenameSize := mem.StringToPtr(nodeName)
+	// Wrap to avoid TinyGo 0.28: cannot use an exported function as value
+	err := mem.Update(func(ptr uint32, limit mem.BufLimit) (len uint32) {
+		return k8sSchedulerNodeInfoPodsWithAffinity(nodenamePtr, nodenameSize, ptr, limit)
+	}, updater)
+	runtime.KeepAlive(nodeName) // until nodenamePtr is no longer needed.
+	return err
+}
+
+// NodeInfoPodsWithRequiredAntiAffinity calls the updater with the PodList of
+// pods on the node with required anti-affinity.
+func NodeInfoPodsWithRequiredAntiAffinity(nodeName string, updater func([]byte) error) error {
+	nodenamePtr, nodenameSize := mem.StringToPtr(nodeName)
+	// Wrap to avoid TinyGo 0.28: cannot use an exported function as value
+	err := mem.Update(func(ptr uint32, limit mem.BufLimit) (len uint32) {
+		return k8sSchedulerNodeInfoPodsWithRequiredAntiAffinity(nodenamePtr, nodenameSize, ptr, limit)
+	}, updater)
+	runtime.KeepAlive(nodeName) // until nodenamePtr is no longer needed.
+	return err
+}
+
+// NodeResources is the resource accounting of a node.
+type NodeResources struct {
+	Requested        *api.Resource
+	NonZeroRequested *api.Resource
+	Allocatable      *api.Resource
+	UsedPorts        api.HostPortInfo
+}
+
+// NodeInfoResources returns the resource accounting of the node. The fields
+// are zero if the node wasn't found.
+func NodeInfoResources(nodeName string) *NodeResources {
+	nodenamePtr, nodenameSize := mem.StringToPtr(nodeName)
+	resources := &NodeResources{
+		Requested:        &api.Resource{},
+		NonZeroRequested: &api.Resource{},
+		Allocatable:      &api.Resource{},
+		UsedPorts:        api.HostPortInfo{},
+	}
+	// Wrap to avoid TinyGo 0.28: cannot use an exported function as value
+	err := mem.Update(func(ptr uint32, limit mem.BufLimit) (len uint32) {
+		return k8sSchedulerNodeInfoResources(nodenamePtr, nodenameSize, ptr, limit)
+	}, resources.unmarshal)
+	runtime.KeepAlive(nodeName) // until nodenamePtr is no longer needed.
+	if err != nil {
+		panic(err)
+	}
+	return resources
+}
+
+// Field numbers of the message written by the host, which has no generated
+// code. Resources are maps of resource.Quantity, and used ports are
+// v1.ContainerPort with only hostIP, protocol and hostPort set.
+const (
+	nodeResourcesRequested        protowire.Number = 1
+	nodeResourcesNonZeroRequested protowire.Number = 2
+	nodeResourcesAllocatable      protowire.Number = 3
+	nodeResourcesUsedPorts        protowire.Number = 4
+)
+
+// unmarshal decodes the message written by the host into r.
+func (r *NodeResources) unmarshal(b []byte) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if typ != protowire.BytesType {
+			if n = protowire.ConsumeFieldValue(num, typ, b); n < 0 {
+				return protowire.ParseError(n)
+			}
+			b = b[n:]
+			continue
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		var err error
+		switch num {
+		case nodeResourcesRequested:
+			err = unmarshalResourceEntry(r.Requested, v)
+		case nodeResourcesNonZeroRequested:
+			err = unmarshalResourceEntry(r.NonZeroRequested, v)
+		case nodeResourcesAllocatable:
+			err = unmarshalResourceEntry(r.Allocatable, v)
+		case nodeResourcesUsedPorts:
+			err = unmarshalUsedPort(r.UsedPorts, v)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unmarshalResourceEntry decodes a map entry of resource name and quantity,
+// and sets it in r. The host writes quantities as integers, with the suffix
+// "m" for cpu, like api.Resource.ResourceList.
+func unmarshalResourceEntry(r *api.Resource, b []byte) error {
+	var name string
+	var quantity resource.Quantity
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 || typ != protowire.BytesType {
+			return errors.New("invalid resource entry")
+		}
+		b = b[n:]
+		switch num {
+		case 1:
+			name = string(v)
+		case 2:
+			if err := quantity.UnmarshalVT(v); err != nil {
+				return err
+			}
+		}
+	}
+
+	value := quantity.GetString_()
+	if name == "cpu" {
+		value = strings.TrimSuffix(value, "m")
+	}
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return err
+	}
+	switch name {
+	case "cpu":
+		r.MilliCPU = i
+	case "memory":
+		r.Memory = i
+	case "ephemeral-storage":
+		r.EphemeralStorage = i
+	case "pods":
+		r.AllowedPodNumber = int(i)
+	default:
+		if r.ScalarResources == nil {
+			r.ScalarResources = map[string]int64{}
+		}
+		r.ScalarResources[name] = i
+	}
+	return nil
+}
+
+// unmarshalUsedPort decodes a v1.ContainerPort, and adds it to ports.
+func unmarshalUsedPort(ports api.HostPortInfo, b []byte) error {
+	var port protoapi.ContainerPort
+	if err := port.UnmarshalVT(b); err != nil {
+		return err
+	}
+	ip := port.GetHostIP()
+	if _, ok := ports[ip]; !ok {
+		ports[ip] = map[api.ProtocolPort]struct{}{}
+	}
+	ports[ip][api.ProtocolPort{Protocol: port.GetProtocol(), Port: port.GetHostPort()}] = struct{}{}
+	return nil
+}
+
 func Node(nodeName string, updater func([]byte) error) error {
 	nodenamePtr, nodenameSize := mem.StringToPtr(nodeName)
 	// Wrap to avoid TinyGo 0.28: cannot use an exported function as value
@@ -98,6 +285,24 @@ func TargetPod(updater func([]byte) error) error {
 	}, updater)
 }
 
+// EventOldObject calls the updater with the object before the current
+// cluster event, or no bytes if there is none.
+func EventOldObject(updater func([]byte) error) error {
+	// Wrap to avoid TinyGo 0.28: cannot use an exported function as value
+	return mem.Update(func(ptr uint32, limit mem.BufLimit) (len uint32) {
+		return k8sSchedulerEventOldObject(ptr, limit)
+	}, updater)
+}
+
+// EventNewObject calls the updater with the object after the current
+// cluster event, or no bytes if there is none.
+func EventNewObject(updater func([]byte) error) error {
+	// Wrap to avoid TinyGo 0.28: cannot use an exported function as value
+	return mem.Update(func(ptr uint32, limit mem.BufLimit) (len uint32) {
+		return k8sSchedulerEventNewObject(ptr, limit)
+	}, updater)
+}
+
 func NodeToStatusMap() map[string]api.StatusCode {
 	// Wrap to avoid TinyGo 0.28: cannot use an exported function as value
 	jsonStr := mem.GetString(func(ptr uint32, limit mem.BufLimit) (len uint32) {
@@ -0,0 +1,84 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package imports
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/api"
+	protoapi "sigs.k8s.io/kube-scheduler-wasm-extension/kubernetes/proto/api"
+	"sigs.k8s.io/kube-scheduler-wasm-extension/kubernetes/proto/resource"
+)
+
+func TestNodeResources_unmarshal(t *testing.T) {
+	// Encode the message like the host does.
+	var b []byte
+	appendQuantity := func(num protowire.Number, name, value string) {
+		quantity, err := (&resource.Quantity{String_: &value}).MarshalVT()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var entry []byte
+		entry = protowire.AppendTag(entry, 1, protowire.BytesType)
+		entry = protowire.AppendString(entry, name)
+		entry = protowire.AppendTag(entry, 2, protowire.BytesType)
+		entry = protowire.AppendBytes(entry, quantity)
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	appendQuantity(nodeResourcesRequested, "cpu", "100m")
+	appendQuantity(nodeResourcesRequested, "memory", "1024")
+	appendQuantity(nodeResourcesNonZeroRequested, "cpu", "200m")
+	appendQuantity(nodeResourcesAllocatable, "pods", "110")
+	appendQuantity(nodeResourcesAllocatable, "ephemeral-storage", "2048")
+	appendQuantity(nodeResourcesAllocatable, "example.com/gpu", "2")
+
+	ip, protocol, hostPort := "0.0.0.0", "TCP", int32(8080)
+	port, err := (&protoapi.ContainerPort{HostIP: &ip, Protocol: &protocol, HostPort: &hostPort}).MarshalVT()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = protowire.AppendTag(b, nodeResourcesUsedPorts, protowire.BytesType)
+	b = protowire.AppendBytes(b, port)
+
+	have := &NodeResources{
+		Requested:        &api.Resource{},
+		NonZeroRequested: &api.Resource{},
+		Allocatable:      &api.Resource{},
+		UsedPorts:        api.HostPortInfo{},
+	}
+	if err = have.unmarshal(b); err != nil {
+		t.Fatal(err)
+	}
+
+	want := &NodeResources{
+		Requested:        &api.Resource{MilliCPU: 100, Memory: 1024},
+		NonZeroRequested: &api.Resource{MilliCPU: 200},
+		Allocatable: &api.Resource{
+			AllowedPodNumber: 110,
+			EphemeralStorage: 2048,
+			ScalarResources:  map[string]int64{"example.com/gpu": 2},
+		},
+		UsedPorts: api.HostPortInfo{"0.0.0.0": {{Protocol: "TCP", Port: 8080}: {}}},
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Fatalf("unexpected resources: %+v != %+v", want, have)
+	}
+}
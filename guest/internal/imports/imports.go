@@ -44,5 +44,23 @@ func k8sSchedulerCurrentPod(ptr uint32, limit mem.BufLimit) (len uint32)
 //go:wasmimport k8s.io/scheduler targetPod
 func k8sSchedulerTargetPod(ptr uint32, limit mem.BufLimit) (len uint32)
 
+//go:wasmimport k8s.io/scheduler event.old_object
+func k8sSchedulerEventOldObject(ptr uint32, limit mem.BufLimit) (len uint32)
+
+//go:wasmimport k8s.io/scheduler event.new_object
+func k8sSchedulerEventNewObject(ptr uint32, limit mem.BufLimit) (len uint32)
+
 //go:wasmimport k8s.io/scheduler nodeImageStates
 func k8sSchedulerNodeImageStates(uint32, uint32, uint32, mem.BufLimit) (len uint32)
+
+//go:wasmimport k8s.io/scheduler node_info.pods
+func k8sSchedulerNodeInfoPods(uint32, uint32, uint32, mem.BufLimit) (len uint32)
+
+//go:wasmimport k8s.io/scheduler node_info.pods_with_affinity
+func k8sSchedulerNodeInfoPodsWithAffinity(uint32, uint32, uint32, mem.BufLimit) (len uint32)
+
+//go:wasmimport k8s.io/scheduler node_info.pods_with_required_anti_affinity
+func k8sSchedulerNodeInfoPodsWithRequiredAntiAffinity(uint32, uint32, uint32, mem.BufLimit) (len uint32)
+
+//go:wasmimport k8s.io/scheduler node_info.resources
+func k8sSchedulerNodeInfoResources(uint32, uint32, uint32, mem.BufLimit) (len uint32)
@@ -44,5 +44,25 @@ func k8sSchedulerCurrentPod(uint32, mem.BufLimit) (len uint32) { return }
 // k8sSchedulerTargetPod is stubbed for compilation outside TinyGo.
 func k8sSchedulerTargetPod(uint32, mem.BufLimit) (len uint32) { return }
 
+// k8sSchedulerEventOldObject is stubbed for compilation outside TinyGo.
+func k8sSchedulerEventOldObject(uint32, mem.BufLimit) (len uint32) { return }
+
+// k8sSchedulerEventNewObject is stubbed for compilation outside TinyGo.
+func k8sSchedulerEventNewObject(uint32, mem.BufLimit) (len uint32) { return }
+
 // k8sSchedulerNodeImageStates is stubbed for compilation outside TinyGo.
 func k8sSchedulerNodeImageStates(uint32, uint32, uint32, mem.BufLimit) (len uint32) { return }
+
+// k8sSchedulerNodeInfoPods is stubbed for compilation outside TinyGo.
+func k8sSchedulerNodeInfoPods(uint32, uint32, uint32, mem.BufLimit) (len uint32) { return }
+
+// k8sSchedulerNodeInfoPodsWithAffinity is stubbed for compilation outside TinyGo.
+func k8sSchedulerNodeInfoPodsWithAffinity(uint32, uint32, uint32, mem.BufLimit) (len uint32) { return }
+
+// k8sSchedulerNodeInfoPodsWithRequiredAntiAffinity is stubbed for compilation outside TinyGo.
+func k8sSchedulerNodeInfoPodsWithRequiredAntiAffinity(uint32, uint32, uint32, mem.BufLimit) (len uint32) {
+	return
+}
+
+// k8sSchedulerNodeInfoResources is stubbed for compilation outside TinyGo.
+func k8sSchedulerNodeInfoResources(uint32, uint32, uint32, mem.BufLimit) (len uint32) { return }
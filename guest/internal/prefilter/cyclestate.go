@@ -0,0 +1,77 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package prefilter
+
+import "sigs.k8s.io/kube-scheduler-wasm-extension/guest/api"
+
+// cycleStateSnapshots are copies of the cycle state saved by the host, keyed
+// by an ID it chose. These are cleared by prefilter.
+var cycleStateSnapshots = map[uint32]map[string]any{}
+
+// prevent unused lint errors (lint is run with normal go).
+var (
+	_ func(uint32) = _snapshotcyclestate
+	_ func(uint32) = _restorecyclestate
+	_ func(uint32) = _dropcyclestate
+)
+
+// _snapshotcyclestate is only exported to the host. The host calls this
+// before switching to a different framework.CycleState, such as a clone used
+// for preemption.
+//
+//export snapshotcyclestate
+func _snapshotcyclestate(id uint32) { //nolint
+	cycleStateSnapshots[id] = cloneCycleState(currentCycleState)
+}
+
+// _restorecyclestate is only exported to the host. The host calls this when
+// switching back to a framework.CycleState saved by snapshotcyclestate.
+//
+//export restorecyclestate
+func _restorecyclestate(id uint32) { //nolint
+	snapshot, ok := cycleStateSnapshots[id]
+	if !ok {
+		panic("unknown cycle state")
+	}
+	// Copy the snapshot as it can be restored again, e.g. for each node
+	// considered for preemption.
+	currentCycleState = cloneCycleState(snapshot)
+	// The cycle state switched, so pods on nodes may be different.
+	ResetNodeInfoPods()
+}
+
+// _dropcyclestate is only exported to the host. The host calls this when the
+// framework.CycleState saved by snapshotcyclestate is no longer used, such as
+// a clone for a node already considered for preemption.
+//
+//export dropcyclestate
+func _dropcyclestate(id uint32) { //nolint
+	delete(cycleStateSnapshots, id)
+}
+
+// cloneCycleState copies the cycle state, cloning any values that implement
+// api.StateData.
+func cloneCycleState(state map[string]any) map[string]any {
+	clone := make(map[string]any, len(state))
+	for k, v := range state {
+		if data, ok := v.(api.StateData); ok {
+			v = data.Clone()
+		}
+		clone[k] = v
+	}
+	return clone
+}
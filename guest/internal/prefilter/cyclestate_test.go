@@ -0,0 +1,98 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package prefilter
+
+import (
+	"testing"
+
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/api"
+)
+
+type counter struct{ n int }
+
+func (c *counter) Clone() api.StateData {
+	return &counter{n: c.n}
+}
+
+func TestSnapshotRestoreCycleState(t *testing.T) {
+	currentCycleState = map[string]any{}
+	clear(cycleStateSnapshots)
+
+	c := &counter{n: 1}
+	CycleState.Write("counter", c)
+	CycleState.Write("shared", "a")
+	_snapshotcyclestate(1)
+
+	// Simulate preemption changing a clone of the cycle state.
+	c.n = 2
+	CycleState.Delete("shared")
+	_snapshotcyclestate(2)
+
+	// Restoring copies the snapshot, so it can be restored again.
+	for i := 0; i < 2; i++ {
+		_restorecyclestate(1)
+		val, ok := CycleState.Read("counter")
+		if !ok {
+			t.Fatal("expected counter")
+		}
+		if want, have := 1, val.(*counter).n; want != have {
+			t.Fatalf("unexpected counter: %v != %v", want, have)
+		}
+		if val == any(c) {
+			t.Fatal("expected counter to be cloned")
+		}
+		val.(*counter).n = 3
+		if val, _ := CycleState.Read("shared"); val != "a" {
+			t.Fatalf("unexpected shared: %v", val)
+		}
+	}
+
+	_restorecyclestate(2)
+	if val, _ := CycleState.Read("counter"); val.(*counter).n != 2 {
+		t.Fatalf("unexpected counter: %v", val.(*counter).n)
+	}
+	if _, ok := CycleState.Read("shared"); ok {
+		t.Fatal("expected shared to be deleted")
+	}
+}
+
+func TestDropCycleState(t *testing.T) {
+	currentCycleState = map[string]any{}
+	clear(cycleStateSnapshots)
+
+	_snapshotcyclestate(1)
+	_snapshotcyclestate(2)
+	_dropcyclestate(1)
+
+	if _, ok := cycleStateSnapshots[1]; ok {
+		t.Fatal("expected snapshot 1 to be dropped")
+	}
+	if _, ok := cycleStateSnapshots[2]; !ok {
+		t.Fatal("expected snapshot 2 to be kept")
+	}
+}
+
+func TestRestoreCycleState_Unknown(t *testing.T) {
+	clear(cycleStateSnapshots)
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+	_restorecyclestate(1)
+}
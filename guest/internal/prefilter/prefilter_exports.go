@@ -49,6 +49,7 @@ func _prefilter() uint32 { //nolint
 	// This function begins a new scheduling cycle: zero out any cycle state.
 	currentPod = nil
 	currentCycleState = map[string]any{}
+	clear(cycleStateSnapshots)
 	currentNodeInfoList = nil
 	isFullNodeInfoList = false
 
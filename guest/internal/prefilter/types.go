@@ -97,6 +97,14 @@ var _ api.NodeInfoList = (*nodeInfoList)(nil)
 
 type nodeInfoList struct{}
 
+// hostNodes, hostNodeInfoPods and hostNodeInfoResources call the host. They
+// are variables, so that tests can replace them.
+var (
+	hostNodes             = imports.Nodes
+	hostNodeInfoPods      = imports.NodeInfoPods
+	hostNodeInfoResources = imports.NodeInfoResources
+)
+
 // currentNodeInfoList is a cache for a list of NodeInfo.
 var currentNodeInfoList []api.NodeInfo
 
@@ -136,7 +144,7 @@ func (n *nodeInfoList) List() []api.NodeInfo {
 	}
 
 	var msg protoapi.NodeList
-	if err := imports.Nodes(msg.UnmarshalVT); err != nil {
+	if err := hostNodes(msg.UnmarshalVT); err != nil {
 		panic(err)
 	}
 
@@ -148,6 +156,7 @@ func (n *nodeInfoList) List() []api.NodeInfo {
 	items := make([]api.NodeInfo, size)
 	for i := range msg.Items {
 		items[i] = &nodeInfo{
+			name: msg.Items[i].GetMetadata().GetName(),
 			node: &internalproto.Node{Msg: msg.Items[i]},
 		}
 	}
@@ -164,6 +173,16 @@ type nodeInfo struct {
 
 	node        proto.Node
 	imageStates map[string]*api.ImageStateSummary
+
+	// pods, podsWithAffinity and podsWithRequiredAntiAffinity are nil until
+	// fetched, and reset when pods on the node may have changed.
+	pods                         []proto.Pod
+	podsWithAffinity             []proto.Pod
+	podsWithRequiredAntiAffinity []proto.Pod
+
+	// resources, including used ports, are nil until fetched, and reset with
+	// pods.
+	resources *imports.NodeResources
 }
 
 // newNodeInfo initializes a nodeInfo with the given nodeName.
@@ -231,3 +250,77 @@ func (n *nodeInfo) ImageStates() map[string]*api.ImageStateSummary {
 
 	return n.imageStates
 }
+
+func (n *nodeInfo) Pods() []proto.Pod {
+	if n.pods == nil {
+		n.pods = fetchPods(n.name, hostNodeInfoPods)
+	}
+	return n.pods
+}
+
+func (n *nodeInfo) PodsWithAffinity() []proto.Pod {
+	if n.podsWithAffinity == nil {
+		n.podsWithAffinity = fetchPods(n.name, imports.NodeInfoPodsWithAffinity)
+	}
+	return n.podsWithAffinity
+}
+
+func (n *nodeInfo) PodsWithRequiredAntiAffinity() []proto.Pod {
+	if n.podsWithRequiredAntiAffinity == nil {
+		n.podsWithRequiredAntiAffinity = fetchPods(n.name, imports.NodeInfoPodsWithRequiredAntiAffinity)
+	}
+	return n.podsWithRequiredAntiAffinity
+}
+
+func (n *nodeInfo) Requested() *api.Resource {
+	return n.lazyResources().Requested
+}
+
+func (n *nodeInfo) NonZeroRequested() *api.Resource {
+	return n.lazyResources().NonZeroRequested
+}
+
+func (n *nodeInfo) Allocatable() *api.Resource {
+	return n.lazyResources().Allocatable
+}
+
+func (n *nodeInfo) UsedPorts() api.HostPortInfo {
+	return n.lazyResources().UsedPorts
+}
+
+// lazyResources fetches the resource accounting of the node from the host.
+func (n *nodeInfo) lazyResources() *imports.NodeResources {
+	if n.resources == nil {
+		n.resources = hostNodeInfoResources(n.name)
+	}
+	return n.resources
+}
+
+// fetchPods fetches a list of pods on the node from the host. The result is
+// never nil, so that an empty list is cached.
+func fetchPods(nodeName string, fetch func(string, func([]byte) error) error) []proto.Pod {
+	var msg protoapi.PodList
+	if err := fetch(nodeName, msg.UnmarshalVT); err != nil {
+		panic(err)
+	}
+
+	pods := make([]proto.Pod, len(msg.Items))
+	for i := range msg.Items {
+		pods[i] = &internalproto.Pod{Msg: msg.Items[i]}
+	}
+	return pods
+}
+
+// ResetNodeInfoPods drops any pods and resources cached by NodeInfo. This is
+// called when the scheduler may have changed pods on nodes, such as when it
+// adds or removes pods in a copy of NodeInfo for preemption.
+func ResetNodeInfoPods() {
+	for _, item := range currentNodeInfoList {
+		if ni, ok := item.(*nodeInfo); ok {
+			ni.pods = nil
+			ni.podsWithAffinity = nil
+			ni.podsWithRequiredAntiAffinity = nil
+			ni.resources = nil
+		}
+	}
+}
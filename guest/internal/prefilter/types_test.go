@@ -0,0 +1,99 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package prefilter
+
+import (
+	"testing"
+
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/api"
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/api/proto"
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/internal/imports"
+	protoapi "sigs.k8s.io/kube-scheduler-wasm-extension/kubernetes/proto/api"
+	meta "sigs.k8s.io/kube-scheduler-wasm-extension/kubernetes/proto/meta"
+)
+
+func TestResetNodeInfoPods(t *testing.T) {
+	ni := newNodeInfo("node")
+	ni.pods = []proto.Pod{}
+	ni.podsWithAffinity = []proto.Pod{}
+	ni.podsWithRequiredAntiAffinity = []proto.Pod{}
+	ni.resources = &imports.NodeResources{}
+	currentNodeInfoList = []api.NodeInfo{ni}
+	defer func() { currentNodeInfoList = nil }()
+
+	ResetNodeInfoPods()
+
+	if ni.pods != nil || ni.podsWithAffinity != nil || ni.podsWithRequiredAntiAffinity != nil || ni.resources != nil {
+		t.Fatal("expected pods and resources to be reset")
+	}
+	if want, have := "node", ni.GetName(); want != have {
+		t.Fatalf("unexpected name: %v != %v", want, have)
+	}
+}
+
+func TestNodeInfoList_List(t *testing.T) {
+	defer func() {
+		hostNodes, hostNodeInfoPods, hostNodeInfoResources = imports.Nodes, imports.NodeInfoPods, imports.NodeInfoResources
+		currentNodeInfoList, isFullNodeInfoList = nil, false
+	}()
+
+	name := "node"
+	hostNodes = func(updater func([]byte) error) error {
+		list := &protoapi.NodeList{Items: []*protoapi.Node{{Metadata: &meta.ObjectMeta{Name: &name}}}}
+		b, err := list.MarshalVT()
+		if err != nil {
+			return err
+		}
+		return updater(b)
+	}
+	var podsOf, resourcesOf string
+	hostNodeInfoPods = func(nodeName string, updater func([]byte) error) error {
+		podsOf = nodeName
+		return updater(nil)
+	}
+	hostNodeInfoResources = func(nodeName string) *imports.NodeResources {
+		resourcesOf = nodeName
+		return &imports.NodeResources{Requested: &api.Resource{MilliCPU: 100}}
+	}
+
+	items := Nodes.List()
+	if len(items) != 1 {
+		t.Fatalf("unexpected nodes: %v", items)
+	}
+	if want, have := name, items[0].GetName(); want != have {
+		t.Fatalf("unexpected name: %v != %v", want, have)
+	}
+	if Nodes.Get(name) != items[0] {
+		t.Fatal("expected Get to return the listed node")
+	}
+
+	// Pods of a listed node are looked up by its name.
+	if pods := items[0].Pods(); len(pods) != 0 {
+		t.Fatalf("unexpected pods: %v", pods)
+	}
+	if want, have := name, podsOf; want != have {
+		t.Fatalf("unexpected node name for pods: %v != %v", want, have)
+	}
+
+	// So are its resources.
+	if want, have := int64(100), items[0].Requested().MilliCPU; want != have {
+		t.Fatalf("unexpected requested cpu: %v != %v", want, have)
+	}
+	if want, have := name, resourcesOf; want != have {
+		t.Fatalf("unexpected node name for resources: %v != %v", want, have)
+	}
+}
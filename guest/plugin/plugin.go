@@ -26,8 +26,10 @@ import (
 	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/postbind"
 	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/postfilter"
 	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/prebind"
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/preenqueue"
 	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/prefilterextensions"
 	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/prescore"
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/queuesort"
 	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/reserve"
 	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/score"
 	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/scoreextensions"
@@ -53,6 +55,12 @@ func Set(plugin api.Plugin) {
 	if plugin, ok := plugin.(api.EnqueueExtensions); ok {
 		enqueue.SetPlugin(plugin)
 	}
+	if plugin, ok := plugin.(api.PreEnqueuePlugin); ok {
+		preenqueue.SetPlugin(plugin)
+	}
+	if plugin, ok := plugin.(api.QueueSortPlugin); ok {
+		queuesort.SetPlugin(plugin)
+	}
 	if plugin, ok := plugin.(api.PreFilterPlugin); ok {
 		prefilter.SetPlugin(plugin)
 	}
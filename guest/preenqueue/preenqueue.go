@@ -0,0 +1,130 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package preenqueue exports an api.PreEnqueuePlugin to the host. Only import
+// this package when setting Plugin, as doing otherwise will cause overhead.
+package preenqueue
+
+import (
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/api"
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/internal/imports"
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/internal/plugin"
+	internalproto "sigs.k8s.io/kube-scheduler-wasm-extension/guest/internal/proto"
+	protoapi "sigs.k8s.io/kube-scheduler-wasm-extension/kubernetes/proto/api"
+)
+
+// preenqueue is the current plugin assigned with SetPlugin.
+var preenqueue api.PreEnqueuePlugin
+
+// SetPlugin should be called in `main` to assign an api.PreEnqueuePlugin
+// instance.
+//
+// For example:
+//
+//	func main() {
+//		preenqueue.SetPlugin(preEnqueuePlugin{})
+//	}
+//
+//	type preEnqueuePlugin struct{}
+//
+//	func (preEnqueuePlugin) PreEnqueue(pod proto.Pod) (status *api.Status) {
+//		panic("implement me")
+//	}
+func SetPlugin(preEnqueuePlugin api.PreEnqueuePlugin) {
+	if preEnqueuePlugin == nil {
+		panic("nil preEnqueuePlugin")
+	}
+	preenqueue = preEnqueuePlugin
+	plugin.MustSet(preenqueue)
+}
+
+// prevent unused lint errors (lint is run with normal go).
+var _ func() uint32 = _preenqueue
+
+// preenqueue is only exported to the host.
+//
+//export preenqueue
+func _preenqueue() uint32 { //nolint
+	if preenqueue == nil { // Then, the user didn't define one.
+		// This is likely caused by use of plugin.Set(p), where 'p' didn't
+		// implement PreEnqueuePlugin: return success.
+		return 0
+	}
+
+	// PreEnqueue is not a part of the scheduling cycle, so the pod isn't
+	// cyclestate.Pod.
+	s := preenqueue.PreEnqueue(&pod{})
+
+	return imports.StatusToCode(s)
+}
+
+// pod lazy initializes the pod from imports.CurrentPod.
+type pod struct {
+	pod *internalproto.Pod
+}
+
+func (p *pod) GetApiVersion() string {
+	return p.lazyPod().GetApiVersion()
+}
+
+func (p *pod) GetKind() string {
+	return p.lazyPod().GetKind()
+}
+
+func (p *pod) GetName() string {
+	return p.lazyPod().GetName()
+}
+
+func (p *pod) GetNamespace() string {
+	return p.lazyPod().GetNamespace()
+}
+
+func (p *pod) GetUid() string {
+	return p.lazyPod().GetUid()
+}
+
+func (p *pod) GetResourceVersion() string {
+	return p.lazyPod().GetResourceVersion()
+}
+
+func (p *pod) GetLabels() map[string]string {
+	return p.lazyPod().GetLabels()
+}
+
+func (p *pod) GetAnnotations() map[string]string {
+	return p.lazyPod().GetAnnotations()
+}
+
+func (p *pod) Spec() *protoapi.PodSpec {
+	return p.lazyPod().Spec()
+}
+
+func (p *pod) Status() *protoapi.PodStatus {
+	return p.lazyPod().Status()
+}
+
+func (p *pod) lazyPod() *internalproto.Pod {
+	if pod := p.pod; pod != nil {
+		return pod
+	}
+
+	var msg protoapi.Pod
+	if err := imports.CurrentPod(msg.UnmarshalVT); err != nil {
+		panic(err.Error())
+	}
+	p.pod = &internalproto.Pod{Msg: &msg}
+	return p.pod
+}
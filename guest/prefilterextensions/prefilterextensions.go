@@ -26,6 +26,7 @@ import (
 	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/internal/cyclestate"
 	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/internal/imports"
 	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/internal/plugin"
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/internal/prefilter"
 	internalproto "sigs.k8s.io/kube-scheduler-wasm-extension/guest/internal/proto"
 	protoapi "sigs.k8s.io/kube-scheduler-wasm-extension/kubernetes/proto/api"
 )
@@ -63,6 +64,8 @@ func _addpod() uint32 { //nolint
 		return imports.StatusToCode(&api.Status{Code: api.StatusCodeError, Reason: "could not get current node name"})
 	}
 
+	// The scheduler added a pod to the node before calling AddPod.
+	prefilter.ResetNodeInfoPods()
 	status := prefilterextensions.AddPod(cyclestate.Values, cyclestate.Pod, &podInfo{}, sharedlister.NodeInfos().Get(nodename))
 
 	return imports.StatusToCode(status)
@@ -83,6 +86,8 @@ func _removepod() uint32 { //nolint
 		return imports.StatusToCode(&api.Status{Code: api.StatusCodeError, Reason: "could not get current node name"})
 	}
 
+	// The scheduler removed a pod from the node before calling RemovePod.
+	prefilter.ResetNodeInfoPods()
 	status := prefilterextensions.RemovePod(cyclestate.Values, cyclestate.Pod, &podInfo{}, sharedlister.NodeInfos().Get(nodename))
 
 	return imports.StatusToCode(status)
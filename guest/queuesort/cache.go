@@ -0,0 +1,61 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package queuesort
+
+import protoapi "sigs.k8s.io/kube-scheduler-wasm-extension/kubernetes/proto/api"
+
+// podCacheSize is the count of pods to keep before evicting the least
+// recently used ones. Pods are evicted in bulk, as the guest isn't told when
+// a pod leaves the scheduling queue.
+const podCacheSize = 4096
+
+// podCache caches decoded pods by key, which is their UID and resource
+// version. Less is called O(log n) times on each change to a scheduling queue
+// of n pods, so decoding the same pods again would dominate its cost.
+//
+// This keeps two generations: when the current one is full, it becomes the
+// previous one, and any pod not read from it since is dropped on the next
+// rotation.
+type podCache struct {
+	size              int
+	current, previous map[string]*protoapi.Pod
+}
+
+func newPodCache(size int) *podCache {
+	return &podCache{size: size, current: make(map[string]*protoapi.Pod, size)}
+}
+
+// get returns the cached pod with the key, if any.
+func (c *podCache) get(key string) (*protoapi.Pod, bool) {
+	if pod, ok := c.current[key]; ok {
+		return pod, true
+	}
+	if pod, ok := c.previous[key]; ok {
+		c.put(key, pod) // promote, as it is still in use.
+		return pod, true
+	}
+	return nil, false
+}
+
+// put caches the pod with the key.
+func (c *podCache) put(key string, pod *protoapi.Pod) {
+	if len(c.current) >= c.size {
+		c.previous = c.current
+		c.current = make(map[string]*protoapi.Pod, c.size)
+	}
+	c.current[key] = pod
+}
@@ -0,0 +1,53 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package queuesort
+
+import (
+	"testing"
+
+	protoapi "sigs.k8s.io/kube-scheduler-wasm-extension/kubernetes/proto/api"
+)
+
+func TestPodCache(t *testing.T) {
+	c := newPodCache(2)
+	pod1, pod2, pod3 := &protoapi.Pod{}, &protoapi.Pod{}, &protoapi.Pod{}
+
+	c.put("pod1/1", pod1)
+	c.put("pod2/1", pod2)
+	if pod, ok := c.get("pod1/1"); !ok || pod != pod1 {
+		t.Fatalf("expected pod1, have %v", pod)
+	}
+
+	// Adding a pod over the size rotates the generations. pod1 is promoted
+	// when read, so only pod2 is evicted on the next rotation.
+	c.put("pod3/1", pod3)
+	if pod, ok := c.get("pod1/1"); !ok || pod != pod1 {
+		t.Fatalf("expected pod1, have %v", pod)
+	}
+	c.put("pod1/2", pod1)
+	if _, ok := c.get("pod2/1"); ok {
+		t.Fatal("expected pod2 to be evicted")
+	}
+	for _, key := range []string{"pod1/1", "pod1/2"} {
+		if _, ok := c.get(key); !ok {
+			t.Fatalf("expected %s to be cached", key)
+		}
+	}
+	if _, ok := c.get("missing"); ok {
+		t.Fatal("expected a miss")
+	}
+}
@@ -0,0 +1,27 @@
+//go:build tinygo.wasm
+
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package queuesort
+
+import "sigs.k8s.io/kube-scheduler-wasm-extension/guest/internal/mem"
+
+//go:wasmimport k8s.io/scheduler queued_pod_key
+func k8sSchedulerQueuedPodKey(index, ptr uint32, limit mem.BufLimit) (len uint32)
+
+//go:wasmimport k8s.io/scheduler queued_pod
+func k8sSchedulerQueuedPod(index, ptr uint32, limit mem.BufLimit) (len uint32)
@@ -0,0 +1,136 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package queuesort exports an api.QueueSortPlugin to the host. Only import
+// this package when setting Plugin, as doing otherwise will cause overhead.
+package queuesort
+
+import (
+	"time"
+
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/api"
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/api/proto"
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/internal/mem"
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/internal/plugin"
+	internalproto "sigs.k8s.io/kube-scheduler-wasm-extension/guest/internal/proto"
+	protoapi "sigs.k8s.io/kube-scheduler-wasm-extension/kubernetes/proto/api"
+)
+
+// queuesort is the current plugin assigned with SetPlugin.
+var queuesort api.QueueSortPlugin
+
+// pods caches decoded pods across calls to less.
+var pods = newPodCache(podCacheSize)
+
+// SetPlugin should be called in `main` to assign an api.QueueSortPlugin
+// instance.
+//
+// For example:
+//
+//	func main() {
+//		queuesort.SetPlugin(queueSortPlugin{})
+//	}
+//
+//	type queueSortPlugin struct{}
+//
+//	func (queueSortPlugin) Less(podInfo1, podInfo2 api.QueuedPodInfo) bool {
+//		panic("implement me")
+//	}
+//
+// Note: The host doesn't allow combining this with filter, score, reserve,
+// permit or bind, as a scheduling profile has exactly one queue sort plugin.
+func SetPlugin(queueSortPlugin api.QueueSortPlugin) {
+	if queueSortPlugin == nil {
+		panic("nil queueSortPlugin")
+	}
+	queuesort = queueSortPlugin
+	plugin.MustSet(queuesort)
+}
+
+// prevent unused lint errors (lint is run with normal go).
+var _ func(int64, int64, uint32, int64, int64, uint32) uint32 = _less
+
+// less is only exported to the host.
+//
+//export less
+func _less(timestamp1, initialAttemptTimestamp1 int64, attempts1 uint32, timestamp2, initialAttemptTimestamp2 int64, attempts2 uint32) uint32 { //nolint
+	if queuesort == nil { // Then, the user didn't define one.
+		// This is likely caused by use of plugin.Set(p), where 'p' didn't
+		// implement QueueSortPlugin: order by timestamp.
+		return boolToUint32(timestamp1 < timestamp2)
+	}
+
+	podInfo1 := &queuedPodInfo{index: 0, timestamp: timestamp1, initialAttemptTimestamp: initialAttemptTimestamp1, attempts: attempts1}
+	podInfo2 := &queuedPodInfo{index: 1, timestamp: timestamp2, initialAttemptTimestamp: initialAttemptTimestamp2, attempts: attempts2}
+	return boolToUint32(queuesort.Less(podInfo1, podInfo2))
+}
+
+func boolToUint32(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+var _ api.QueuedPodInfo = (*queuedPodInfo)(nil)
+
+// queuedPodInfo is the api.QueuedPodInfo at the index in the current call to
+// less. The times are in Unix nanoseconds.
+type queuedPodInfo struct {
+	index                   uint32
+	timestamp               int64
+	initialAttemptTimestamp int64
+	attempts                uint32
+	pod                     proto.Pod
+}
+
+func (p *queuedPodInfo) Pod() proto.Pod {
+	if pod := p.pod; pod != nil {
+		return pod
+	}
+
+	// Wrap to avoid TinyGo 0.28: cannot use an exported function as value
+	key := mem.GetString(func(ptr uint32, limit mem.BufLimit) (len uint32) {
+		return k8sSchedulerQueuedPodKey(p.index, ptr, limit)
+	})
+	msg, ok := pods.get(key)
+	if !ok {
+		msg = &protoapi.Pod{}
+		if err := mem.Update(func(ptr uint32, limit mem.BufLimit) (len uint32) {
+			return k8sSchedulerQueuedPod(p.index, ptr, limit)
+		}, msg.UnmarshalVT); err != nil {
+			panic(err.Error())
+		}
+		pods.put(key, msg)
+	}
+	p.pod = &internalproto.Pod{Msg: msg}
+	return p.pod
+}
+
+func (p *queuedPodInfo) Timestamp() time.Time {
+	return time.Unix(0, p.timestamp)
+}
+
+func (p *queuedPodInfo) Attempts() int {
+	return int(p.attempts)
+}
+
+func (p *queuedPodInfo) InitialAttemptTimestamp() time.Time {
+	if p.initialAttemptTimestamp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, p.initialAttemptTimestamp)
+}
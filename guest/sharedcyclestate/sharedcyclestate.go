@@ -0,0 +1,75 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package sharedcyclestate reads and writes the framework.CycleState of the
+// host, which is shared with all plugins in the scheduling cycle. Use this to
+// reuse data computed by native plugins, or to cooperate with other wasm
+// plugins in the same profile.
+//
+// Unlike api.CycleState, values are bytes, so plugins sharing a key must agree
+// on their encoding. Values written here are readable by native plugins as
+// the type StateData of the host. Native plugins can share values with guests
+// by writing ones that implement encoding.BinaryMarshaler.
+//
+// # Notes
+//
+//   - Values can only be written or deleted during the scheduling or binding
+//     cycle, as otherwise there is no framework.CycleState.
+//   - Reading a value not implementing encoding.BinaryMarshaler fails the
+//     current guest call.
+package sharedcyclestate
+
+import (
+	"runtime"
+
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/internal/mem"
+)
+
+// Read returns a copy of the value with the given key or false.
+func Read(key string) (val []byte, ok bool) {
+	ptr, size := mem.StringToPtr(key)
+	_ = mem.Update(func(buf uint32, bufLimit mem.BufLimit) (len uint32) {
+		foundLen := readCycleState(ptr, size, buf, bufLimit)
+		ok = foundLen>>32 == 1
+		return uint32(foundLen)
+	}, func(b []byte) error {
+		if ok {
+			val = append([]byte{}, b...)
+		}
+		return nil
+	})
+	runtime.KeepAlive(key) // until ptr is no longer needed.
+	return
+}
+
+// Write stores a copy of the given value with the given key.
+func Write(key string, val []byte) {
+	ptr, size := mem.StringToPtr(key)
+	var valPtr, valSize uint32
+	if len(val) > 0 {
+		valPtr, valSize = mem.BytesToPtr(val)
+	}
+	writeCycleState(ptr, size, valPtr, valSize)
+	runtime.KeepAlive(key) // until ptr is no longer needed.
+	runtime.KeepAlive(val) // until valPtr is no longer needed.
+}
+
+// Delete deletes the value with the given key.
+func Delete(key string) {
+	ptr, size := mem.StringToPtr(key)
+	deleteCycleState(ptr, size)
+	runtime.KeepAlive(key) // until ptr is no longer needed.
+}
@@ -0,0 +1,31 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package sharedcyclestate_test
+
+import (
+	"sigs.k8s.io/kube-scheduler-wasm-extension/guest/sharedcyclestate"
+)
+
+func ExampleRead() {
+	// Reuse data written by another plugin, such as in PreFilter.
+	if val, ok := sharedcyclestate.Read("example.com/prefilter"); ok {
+		_ = val
+	}
+
+	// Output:
+	//
+}
@@ -21,6 +21,84 @@ func Test_ImageLocality(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	plugin, ninfos := newImageLocalityPlugin(ctx, t, "")
+	defer plugin.(io.Closer).Close()
+
+	t.Run("unmatch", func(t *testing.T) {
+		pod := &v1.Pod{ObjectMeta: v1meta.ObjectMeta{Name: "happy8-meta"}, Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:  "happy8",
+					Image: "test-image:v1",
+				},
+			},
+		}}
+
+		var buf bytes.Buffer
+		klog.SetOutput(&buf)
+
+		// ninfos[0] is a node that doesn't have the requested image.
+		// so we expect to score zero.
+		score := e2e.RunAll(ctx, t, plugin, pod, ninfos[0], nil)
+		if want, have := int64(0), score; want != have {
+			t.Fatalf("unexpected score: want %v, have %v", want, have)
+		}
+	})
+	t.Run("match", func(t *testing.T) {
+		pod := &v1.Pod{ObjectMeta: v1meta.ObjectMeta{Name: "happy8-meta"}, Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:  "happy8",
+					Image: "test-image:v1",
+				},
+			},
+		}}
+
+		var buf bytes.Buffer
+		klog.SetOutput(&buf)
+
+		// ninfos[1] and ninfos[2] are nodes that have the requested image.
+		// so we expect to score non-zero.
+		score := e2e.RunAll(ctx, t, plugin, pod, ninfos[1], nil)
+		if want, have := int64(4), score; want != have {
+			t.Fatalf("unexpected score: want %v, have %v", want, have)
+		}
+	})
+}
+
+func BenchmarkExample_ImageLocality(b *testing.B) {
+	klog.SetOutput(io.Discard)
+	ctx := context.Background()
+	pod := &v1.Pod{ObjectMeta: v1meta.ObjectMeta{Name: "happy8-meta"}, Spec: v1.PodSpec{
+		Containers: []v1.Container{
+			{
+				Name:  "happy8",
+				Image: "test-image:v1",
+			},
+		},
+	}}
+
+	for _, engine := range engines {
+		b.Run(engine, func(b *testing.B) {
+			plugin, ninfos := newImageLocalityPlugin(ctx, b, engine)
+			defer plugin.(io.Closer).Close()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				score := e2e.RunAll(ctx, b, plugin, pod, ninfos[1], nil)
+				if want, have := int64(4), score; want != have {
+					b.Fatalf("unexpected score: want %v, have %v", want, have)
+				}
+			}
+		})
+	}
+}
+
+// newImageLocalityPlugin returns the image locality example, and nodes where
+// only the second and third have the image "test-image:v1".
+func newImageLocalityPlugin(ctx context.Context, t e2e.Testing, engine string) (framework.Plugin, []*framework.NodeInfo) {
+	t.Helper()
+
 	nodes := []*v1.Node{
 		{
 			ObjectMeta: v1meta.ObjectMeta{Name: "no-image-node1"},
@@ -73,52 +151,12 @@ func Test_ImageLocality(t *testing.T) {
 	plugin, err := wasm.NewFromConfig(ctx, "wasm", wasm.PluginConfig{
 		GuestURL:    test.URLExampleImageLocality,
 		LogSeverity: 0,
+		Engine:      engine,
 	}, handle)
 	if err != nil {
 		t.Fatalf("failed to create plugin: %v", err)
 	}
-	defer plugin.(io.Closer).Close()
-
-	t.Run("unmatch", func(t *testing.T) {
-		pod := &v1.Pod{ObjectMeta: v1meta.ObjectMeta{Name: "happy8-meta"}, Spec: v1.PodSpec{
-			Containers: []v1.Container{
-				{
-					Name:  "happy8",
-					Image: "test-image:v1",
-				},
-			},
-		}}
-
-		var buf bytes.Buffer
-		klog.SetOutput(&buf)
-
-		// ninfos[0] is a node that doesn't have the requested image.
-		// so we expect to score zero.
-		score := e2e.RunAll(ctx, t, plugin, pod, ninfos[0], nil)
-		if want, have := int64(0), score; want != have {
-			t.Fatalf("unexpected score: want %v, have %v", want, have)
-		}
-	})
-	t.Run("match", func(t *testing.T) {
-		pod := &v1.Pod{ObjectMeta: v1meta.ObjectMeta{Name: "happy8-meta"}, Spec: v1.PodSpec{
-			Containers: []v1.Container{
-				{
-					Name:  "happy8",
-					Image: "test-image:v1",
-				},
-			},
-		}}
-
-		var buf bytes.Buffer
-		klog.SetOutput(&buf)
-
-		// ninfos[1] and ninfos[2] are nodes that have the requested image.
-		// so we expect to score non-zero.
-		score := e2e.RunAll(ctx, t, plugin, pod, ninfos[1], nil)
-		if want, have := int64(4), score; want != have {
-			t.Fatalf("unexpected score: want %v, have %v", want, have)
-		}
-	})
+	return plugin, ninfos
 }
 
 // getNodeImageStates returns the given node's image states based on the given imageExistence map.
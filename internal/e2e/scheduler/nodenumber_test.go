@@ -80,7 +80,7 @@ func testExample_NodeNumber(t *testing.T, advanced bool) {
 
 	ctx := context.Background()
 	recorder := &test.FakeRecorder{EventMsg: ""}
-	plugin := newNodeNumberPlugin(ctx, t, advanced, false, 0, recorder)
+	plugin := newNodeNumberPlugin(ctx, t, "", advanced, false, 0, recorder)
 	defer plugin.(io.Closer).Close()
 
 	pod := &v1.Pod{ObjectMeta: v1meta.ObjectMeta{Name: "happy8-meta"}, Spec: v1.PodSpec{NodeName: "happy8"}}
@@ -139,7 +139,7 @@ func testExample_NodeNumber(t *testing.T, advanced bool) {
 		var buf bytes.Buffer
 		klog.SetOutput(&buf)
 
-		reversed := newNodeNumberPlugin(ctx, t, advanced, true, 0, recorder)
+		reversed := newNodeNumberPlugin(ctx, t, "", advanced, true, 0, recorder)
 		defer reversed.(io.Closer).Close()
 
 		score := e2e.RunAll(ctx, t, reversed, pod, nodeInfoWithName("glad8"), pi)
@@ -160,22 +160,29 @@ func testExample_NodeNumber(t *testing.T, advanced bool) {
 	})
 }
 
+// engines are the wazero engines to benchmark guests with.
+var engines = []string{"compiler", "interpreter"}
+
 func BenchmarkExample_NodeNumber(b *testing.B) {
-	b.Run("Simple", func(b *testing.B) {
-		benchmarkExample_NodeNumber(b, false, 3)
-	})
-	b.Run("Simple Log", func(b *testing.B) {
-		benchmarkExample_NodeNumber(b, false, 0)
-	})
-	b.Run("Advanced", func(b *testing.B) {
-		benchmarkExample_NodeNumber(b, true, 3)
-	})
-	b.Run("Advanced Log", func(b *testing.B) {
-		benchmarkExample_NodeNumber(b, true, 0)
-	})
+	for _, engine := range engines {
+		b.Run(engine, func(b *testing.B) {
+			b.Run("Simple", func(b *testing.B) {
+				benchmarkExample_NodeNumber(b, engine, false, 3)
+			})
+			b.Run("Simple Log", func(b *testing.B) {
+				benchmarkExample_NodeNumber(b, engine, false, 0)
+			})
+			b.Run("Advanced", func(b *testing.B) {
+				benchmarkExample_NodeNumber(b, engine, true, 3)
+			})
+			b.Run("Advanced Log", func(b *testing.B) {
+				benchmarkExample_NodeNumber(b, engine, true, 0)
+			})
+		})
+	}
 }
 
-func benchmarkExample_NodeNumber(b *testing.B, advanced bool, logSeverity int32) {
+func benchmarkExample_NodeNumber(b *testing.B, engine string, advanced bool, logSeverity int32) {
 	b.Helper()
 	// Reinit klog for tests.
 	fs := k8stest.InitKlog(b)
@@ -190,11 +197,11 @@ func benchmarkExample_NodeNumber(b *testing.B, advanced bool, logSeverity int32)
 	b.Run("New", func(b *testing.B) {
 		b.ResetTimer()
 		for i := 0; i < b.N; i++ {
-			newNodeNumberPlugin(ctx, b, advanced, false, logSeverity, recorder).(io.Closer).Close()
+			newNodeNumberPlugin(ctx, b, engine, advanced, false, logSeverity, recorder).(io.Closer).Close()
 		}
 	})
 
-	plugin := newNodeNumberPlugin(ctx, b, advanced, false, logSeverity, recorder)
+	plugin := newNodeNumberPlugin(ctx, b, engine, advanced, false, logSeverity, recorder)
 	defer plugin.(io.Closer).Close()
 
 	pod := *test.PodReal // copy
@@ -217,7 +224,7 @@ func benchmarkExample_NodeNumber(b *testing.B, advanced bool, logSeverity int32)
 	})
 }
 
-func newNodeNumberPlugin(ctx context.Context, t e2e.Testing, advanced, reverse bool, logSeverity int32, recorder events.EventRecorder) framework.Plugin {
+func newNodeNumberPlugin(ctx context.Context, t e2e.Testing, engine string, advanced, reverse bool, logSeverity int32, recorder events.EventRecorder) framework.Plugin {
 	t.Helper()
 	guestURL := test.URLExampleNodeNumber
 	if advanced {
@@ -229,6 +236,7 @@ func newNodeNumberPlugin(ctx context.Context, t e2e.Testing, advanced, reverse b
 		GuestURL:    guestURL,
 		LogSeverity: logSeverity,
 		GuestConfig: fmt.Sprintf(`{"reverse": %v}`, reverse),
+		Engine:      engine,
 	}, handle)
 	if err != nil {
 		t.Fatalf("failed to create plugin: %v", err)
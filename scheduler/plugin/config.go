@@ -16,15 +16,75 @@
 
 package wasm
 
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
 type PluginConfig struct {
 	// GuestURL is the URL to the guest wasm.
-	// Valid schemes are file:// for a local file or http[s]:// for one
-	// retrieved via HTTP.
+	// Valid schemes are file:// for a local file, http[s]:// for one
+	// retrieved via HTTP, configmap:// or secret:// for one in a ConfigMap or
+	// Secret, or oci:// for one pulled from an OCI registry.
+	//
+	// A configmap:// or secret:// URL is formatted like
+	// configmap://namespace/name/key, and is read via the API server, so the
+	// scheduler needs RBAC permission to get it. The key is read from
+	// binaryData, or data, of a ConfigMap.
+	//
+	// An oci:// URL is formatted like oci://registry/repository:tag, where
+	// the tag may be followed by or replaced with @sha256:digest of the
+	// manifest, to pin it. The manifest must have one layer of a wasm media
+	// type, such as application/vnd.wasm.content.layer.v1+wasm.
+	// Registries are accessed via HTTPS, anonymously or with credentials in
+	// the docker config.json, which is located in $DOCKER_CONFIG or ~/.docker.
 	GuestURL string `json:"guestURL"`
 
+	// GuestSHA256 is the optional hex-encoded SHA-256 digest of the guest.
+	// When set, a guest read from GuestURL with a different digest is not
+	// compiled, so that it cannot be swapped without changing this config.
+	GuestSHA256 string `json:"guestSHA256"`
+
+	// GuestPublicKey is an optional PEM-encoded public key which must verify
+	// the detached signature at GuestSignatureURL, before the guest is
+	// compiled. Ed25519 keys verify a signature of the guest, and ECDSA keys
+	// one of its SHA-256 digest, as made by `cosign sign-blob`.
+	GuestPublicKey string `json:"guestPublicKey"`
+
+	// GuestSignatureURL is the URL to the detached signature of the guest,
+	// required with GuestPublicKey. It has the same schemes as GuestURL. The
+	// signature may be raw or base64 encoded.
+	//
+	// When ReloadInterval is set, the signature is read again for each
+	// changed guest. A guest which doesn't verify is retried until it does,
+	// for example when its signature is updated after it.
+	GuestSignatureURL string `json:"guestSignatureURL"`
+
+	// GuestCacheDir is an optional directory to cache guests into, so that
+	// the scheduler can restart while the server at GuestURL is down.
+	//
+	// Guests pulled from an oci:// GuestURL are cached by digest. When
+	// GuestURL is pinned to a digest which is cached, the scheduler starts
+	// without the registry. Guests read from an http[s]:// GuestURL are cached
+	// by URL, and the last one read is used when the server is unreachable,
+	// or fails with a 5xx status code, at startup.
+	GuestCacheDir string `json:"guestCacheDir"`
+
+	// GuestFetch configures how a guest is read from an http[s]:// or oci://
+	// GuestURL, or GuestSignatureURL.
+	GuestFetch FetchConfig `json:"guestFetch"`
+
 	// GuestConfig is any configuration to give to the guest.
 	GuestConfig string `json:"guestConfig"`
 
+	// GuestConfigURL is an optional URL to read GuestConfig from, instead.
+	// It has the same schemes as GuestURL. For example, a guest can be tuned
+	// via configmap://kube-system/my-plugin/config.yaml, without changing
+	// the scheduler configuration.
+	//
+	// When ReloadInterval is set, the content is also checked for changes at
+	// that interval. Guests usually read their configuration once, so a
+	// changed one is given to new guest instances, which replace the current
+	// ones like a reloaded guest does.
+	GuestConfigURL string `json:"guestConfigURL"`
+
 	// LogSeverity has the following values:
 	//
 	//   - 0: info (default)
@@ -33,6 +93,166 @@ type PluginConfig struct {
 	//   - 3: fatal
 	LogSeverity int32 `json:"logSeverity"`
 
+	// GuestOutput limits how the stdout and stderr of the guest are logged.
+	GuestOutput GuestOutputConfig `json:"guestOutput"`
+
+	// Timeouts optionally bound the execution time of guest functions, keyed
+	// by extension point. For example, `filter: 5ms` or `bind: 2s`.
+	//
+	// Keys are the lowercase names of the guest exports: queueinghint,
+	// preenqueue, less, prefilter, filter, postfilter, prescore, score,
+	// normalizescore, reserve, unreserve, permit, prebind, bind, postbind,
+	// addpod and removepod. enqueue cannot be bounded, as EventsToRegister has no way to
+	// return an error.
+	//
+	// When a guest function exceeds its timeout, the guest instance is closed
+	// and the extension point returns an error status. A new instance is
+	// created on demand.
+	//
+	// Note: setting any timeout affects all guest calls, not only those with
+	// a timeout. Each call has some overhead, as the guest has to check for
+	// cancellation, and an instance is also closed when the scheduler cancels
+	// the context of any call to it.
+	Timeouts map[string]metav1.Duration `json:"timeouts"`
+
+	// MemoryLimitPages optionally limits the linear memory of each guest
+	// instance, in WebAssembly pages of 64KiB. For example, 512 limits each
+	// instance to 32MiB. Zero defaults to the maximum of 65536 pages (4GiB).
+	//
+	// A guest whose declared minimum memory is over this limit fails to
+	// compile. A declared maximum over this limit is allowed, but the guest
+	// cannot grow its memory past the limit. When it tries, the current call
+	// fails with an error status noting the limit, instead of consuming more
+	// scheduler memory.
+	MemoryLimitPages uint32 `json:"memoryLimitPages"`
+
+	// Engine selects how the guest is run, and has the following values:
+	//
+	//   - auto: the compiler, if supported on this platform, or the
+	//     interpreter otherwise (default)
+	//   - compiler: compile the guest to machine code, failing if the
+	//     platform doesn't support it. Only amd64 and arm64 are supported.
+	//   - interpreter: interpret the guest, which is slower, but starts
+	//     faster and works on any platform. This can help when debugging.
+	Engine string `json:"engine"`
+
+	// CompilationCacheDir is an optional directory to persist compiled guests
+	// into. When set, restarting the scheduler, or using the same guest in
+	// multiple profiles, reuses the compiled code instead of compiling again.
+	//
+	// Entries are keyed by a hash of the guest binary and the runtime
+	// settings that affect compilation, and are stored in a subdirectory
+	// specific to the wazero version. An upgraded scheduler never loads code
+	// compiled by a different version. The directory is created if missing.
+	// The interpreter doesn't use it.
+	CompilationCacheDir string `json:"compilationCacheDir"`
+
+	// PoolMinSize is the number of guest instances to create up front, and to
+	// keep when idle. Zero defaults to one.
+	PoolMinSize int `json:"poolMinSize"`
+
+	// PoolMaxSize optionally limits the number of guest instances. Each pod
+	// in a binding cycle, such as one waiting in Permit, uses an instance
+	// separate from the one used to schedule. Zero means no limit.
+	PoolMaxSize int `json:"poolMaxSize"`
+
+	// PoolMaxWait is how long a call waits for an instance when there are
+	// PoolMaxSize in use, before failing with an error status. Zero fails
+	// fast.
+	PoolMaxWait metav1.Duration `json:"poolMaxWait"`
+
+	// PoolIdleTTL optionally closes instances that weren't used for this
+	// duration, while there are more than PoolMinSize. For example, this
+	// releases memory after a burst of binding cycles. Zero keeps them.
+	PoolIdleTTL metav1.Duration `json:"poolIdleTTL"`
+
+	// ReloadInterval optionally enables reloading the guest when the content
+	// at GuestURL, or GuestConfigURL, changes, checking at this interval. A
+	// file:// URL is checked by its modification time and size. An
+	// http[s]:// URL is polled with its ETag, if the server returns one, or
+	// its content otherwise. A configmap:// or secret:// URL is read from the
+	// API server each time.
+	//
+	// A changed guest is compiled in the background and replaces the current
+	// one for new scheduling cycles. Cycles in progress, such as pods waiting
+	// in Permit, finish with the guest they started with. A guest which fails
+	// to compile, or exports different plugin functions than the current one,
+	// is rejected with an error log, and the current guest is kept. Changing
+	// which extension points a guest implements requires a restart.
+	//
+	// Zero, the default, reads GuestURL and GuestConfigURL once.
+	ReloadInterval metav1.Duration `json:"reloadInterval"`
+
+	// Informers optionally allows the guest to get and list objects of these
+	// resources, via the guest/handle/informer package. Resources not listed
+	// aren't visible to the guest.
+	//
+	// Each entry is formatted like group/version/resource, or
+	// version/resource for the core group. For example, v1/namespaces,
+	// v1/configmaps or example.com/v1alpha1/tenants. Built-in resources are
+	// read from the scheduler's informers. Others, such as custom resources,
+	// are read from a dynamic informer, which needs RBAC permission to list
+	// and watch them. Until a dynamic informer syncs, its resource appears to
+	// have no objects.
+	Informers []string `json:"informers"`
+
 	// Args are the os.Args the guest will receive, exposed for tests.
 	Args []string
 }
+
+// FetchConfig configures HTTP requests for guests. The zero value reads a
+// guest with one request, without a timeout, using the system certificates.
+type FetchConfig struct {
+	// Timeout optionally bounds each request, including reading the body.
+	Timeout metav1.Duration `json:"timeout"`
+
+	// Retries is the number of times to retry a request which failed to
+	// connect, timed out, or received a 429 or 5xx status code.
+	Retries int `json:"retries"`
+
+	// RetryBackoff is how long to wait before the first retry, doubling for
+	// each one after it. Zero defaults to one second.
+	RetryBackoff metav1.Duration `json:"retryBackoff"`
+
+	// BearerTokenFile is an optional file containing a token to send in the
+	// Authorization header of http[s]:// requests. The file is read for each
+	// request, so that a rotated token is used without a restart.
+	BearerTokenFile string `json:"bearerTokenFile"`
+
+	// BasicAuthFile is an optional file containing "username:password" to
+	// send as basic authorization of http[s]:// requests, instead of
+	// BearerTokenFile. The file is read for each request.
+	BasicAuthFile string `json:"basicAuthFile"`
+
+	// CAFile is an optional PEM-encoded bundle of certificates to trust in
+	// addition to the system ones.
+	CAFile string `json:"caFile"`
+
+	// CertFile and KeyFile are an optional PEM-encoded client certificate and
+	// its key, sent to servers which request one.
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+}
+
+// GuestOutputConfig limits logging of guest stdout and stderr. Each line is
+// logged at info level, tagged with the plugin, extension point, and any pod
+// and node. The tail of the output of a call is also added to the error
+// status of a call which fails, such as one which panics.
+type GuestOutputConfig struct {
+	// LineLimit truncates longer lines to this many bytes. Zero defaults to
+	// 4096.
+	LineLimit int `json:"lineLimit"`
+
+	// TailLimit is the number of bytes at the end of the output of a call to
+	// add to its error. Zero defaults to 4096.
+	TailLimit int `json:"tailLimit"`
+
+	// LinesPerSecond limits the rate of lines logged by all instances of the
+	// guest. Lines over the limit are dropped, and their count is logged
+	// with the next line. Zero defaults to 100.
+	LinesPerSecond float64 `json:"linesPerSecond"`
+
+	// Burst is the number of lines which may be logged at once, over
+	// LinesPerSecond. Zero defaults to LinesPerSecond.
+	Burst int `json:"burst"`
+}
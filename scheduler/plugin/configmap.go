@@ -0,0 +1,85 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package wasm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// objectKeyReference is a key of a ConfigMap or Secret.
+type objectKeyReference struct {
+	namespace, name, key string
+}
+
+// parseObjectKeyReference parses a URL like configmap://namespace/name/key.
+func parseObjectKeyReference(url string) (ref objectKeyReference, err error) {
+	_, rest, _ := strings.Cut(url, "://")
+	parts := strings.Split(rest, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return ref, fmt.Errorf("invalid URL %s: expected namespace/name/key", url)
+	}
+	return objectKeyReference{namespace: parts[0], name: parts[1], key: parts[2]}, nil
+}
+
+// kubeGetIfChanged reads the key of the ConfigMap or Secret at the
+// configmap:// or secret:// URL, unless its content hash is the version.
+//
+// The object is read from the API server, instead of an informer, as plugins
+// are created before informers are started.
+func (f *guestFetcher) kubeGetIfChanged(ctx context.Context, scheme, url, version string) ([]byte, string, error) {
+	ref, err := parseObjectKeyReference(url)
+	if err != nil {
+		return nil, "", err
+	}
+	if f.handle == nil || f.handle.ClientSet() == nil {
+		return nil, "", errors.New("a Kubernetes client is required")
+	}
+	client := f.handle.ClientSet().CoreV1()
+
+	var bin []byte
+	var ok bool
+	switch scheme {
+	case "configmap":
+		cm, err := client.ConfigMaps(ref.namespace).Get(ctx, ref.name, metav1.GetOptions{})
+		if err != nil {
+			return nil, "", err
+		}
+		if bin, ok = cm.BinaryData[ref.key]; !ok {
+			var data string
+			data, ok = cm.Data[ref.key]
+			bin = []byte(data)
+		}
+	case "secret":
+		secret, err := client.Secrets(ref.namespace).Get(ctx, ref.name, metav1.GetOptions{})
+		if err != nil {
+			return nil, "", err
+		}
+		bin, ok = secret.Data[ref.key]
+	}
+	if !ok {
+		return nil, "", fmt.Errorf("key %q not found in %s %s/%s", ref.key, scheme, ref.namespace, ref.name)
+	}
+
+	// Use a hash of the content as the version, so that changes to other
+	// keys in the same object are ignored.
+	return bin, contentHash(bin), nil
+}
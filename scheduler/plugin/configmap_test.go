@@ -0,0 +1,211 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package wasm
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"sigs.k8s.io/kube-scheduler-wasm-extension/scheduler/test"
+)
+
+func Test_parseObjectKeyReference(t *testing.T) {
+	tests := []struct {
+		url           string
+		expected      objectKeyReference
+		expectedError string
+	}{
+		{
+			url:      "configmap://kube-system/guest/plugin.wasm",
+			expected: objectKeyReference{namespace: "kube-system", name: "guest", key: "plugin.wasm"},
+		},
+		{
+			url:           "configmap://kube-system/guest",
+			expectedError: "invalid URL configmap://kube-system/guest: expected namespace/name/key",
+		},
+		{
+			url:           "secret://kube-system//plugin.wasm",
+			expectedError: "invalid URL secret://kube-system//plugin.wasm: expected namespace/name/key",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.url, func(t *testing.T) {
+			ref, err := parseObjectKeyReference(tc.url)
+			if tc.expectedError != "" {
+				if err == nil || err.Error() != tc.expectedError {
+					t.Fatalf("unexpected error: want %s, have %v", tc.expectedError, err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if want, have := tc.expected, ref; want != have {
+				t.Fatalf("unexpected reference: want %v, have %v", want, have)
+			}
+		})
+	}
+}
+
+func Test_guestFetcher_kube(t *testing.T) {
+	clientSet := fake.NewSimpleClientset(
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "cm"},
+			BinaryData: map[string][]byte{"guest.wasm": wasmMagicNumber},
+			Data:       map[string]string{"config.json": `{"reverse":true}`},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "secret"},
+			Data:       map[string][]byte{"config.json": []byte(`{"token":"s3cr3t"}`)},
+		},
+	)
+	f := &guestFetcher{handle: &test.FakeHandle{ClientSetValue: clientSet}}
+
+	tests := []struct {
+		name          string
+		url           string
+		expected      string
+		expectedError string
+	}{
+		{
+			name:     "configmap binaryData",
+			url:      "configmap://ns/cm/guest.wasm",
+			expected: string(wasmMagicNumber),
+		},
+		{
+			name:     "configmap data",
+			url:      "configmap://ns/cm/config.json",
+			expected: `{"reverse":true}`,
+		},
+		{
+			name:     "secret",
+			url:      "secret://ns/secret/config.json",
+			expected: `{"token":"s3cr3t"}`,
+		},
+		{
+			name:          "missing key",
+			url:           "configmap://ns/cm/missing",
+			expectedError: `key "missing" not found in configmap ns/cm`,
+		},
+		{
+			name:          "missing object",
+			url:           "secret://ns/missing/config.json",
+			expectedError: `secrets "missing" not found`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			bin, version, err := f.getIfChanged(ctx, tc.url, "")
+			if tc.expectedError != "" {
+				if err == nil || err.Error() != tc.expectedError {
+					t.Fatalf("unexpected error: want %s, have %v", tc.expectedError, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			} else if want, have := tc.expected, string(bin); want != have {
+				t.Fatalf("unexpected content: want %s, have %s", want, have)
+			}
+
+			// Nothing is returned when the key is unchanged.
+			if bin, _, err = f.getIfChanged(ctx, tc.url, version); err != nil {
+				t.Fatal(err)
+			} else if bin != nil {
+				t.Fatalf("expected no change, have %s", bin)
+			}
+		})
+	}
+}
+
+func TestNewFromConfig_configMap(t *testing.T) {
+	guestBin, err := os.ReadFile(strings.TrimPrefix(test.URLErrorPanicOnGetConfig, "file://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "guest"},
+		BinaryData: map[string][]byte{"guest.wasm": guestBin},
+		Data:       map[string]string{"config": "hello"},
+	}
+	clientSet := fake.NewSimpleClientset(cm)
+
+	// Use a long interval, so that the test controls when to reload.
+	p, err := NewFromConfig(ctx, "configmap", PluginConfig{
+		GuestURL:       "configmap://kube-system/guest/guest.wasm",
+		GuestConfigURL: "configmap://kube-system/guest/config",
+		ReloadInterval: metav1.Duration{Duration: time.Hour},
+	}, &test.FakeHandle{ClientSetValue: clientSet})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.(io.Closer).Close()
+	pl := p.(ProfilerSupport).plugin()
+
+	// The guest panics with its config, which shows the one it read. Each
+	// call is a new scheduling cycle, so that it uses the current guest.
+	requireConfig := func(want string) {
+		t.Helper()
+		pod := test.PodSmall.DeepCopy()
+		pod.UID = uuid.NewUUID()
+		_, s := pl.PreFilter(ctx, nil, pod)
+		if have := s.Message(); !strings.HasPrefix(have, "wasm: prefilter error: "+want+"\n") {
+			t.Fatalf("unexpected status message: want config %s, have %s", want, have)
+		}
+	}
+	requireConfig("hello")
+
+	fetcher, err := newGuestFetcher(pl.config, pl.handle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, version, err := fetcher.getIfChanged(ctx, pl.config.GuestConfigURL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A changed config is given to new guests.
+	cm.Data["config"] = "goodbye"
+	if _, err = clientSet.CoreV1().ConfigMaps(cm.Namespace).Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if have := pl.reloadConfigIfChanged(ctx, fetcher, version); have == version {
+		t.Fatal("expected the version to change")
+	}
+	requireConfig("goodbye")
+
+	// A changed guest keeps the current config.
+	_ = pl.reload(ctx, guestBin, pl.guestConfig)
+	requireConfig("goodbye")
+}
+
+func TestNewFromConfig_guestConfigExclusive(t *testing.T) {
+	_, err := NewFromConfig(ctx, "configmap", PluginConfig{
+		GuestURL:       test.URLErrorPanicOnGetConfig,
+		GuestConfig:    "hello",
+		GuestConfigURL: test.URLErrorPanicOnGetConfig,
+	}, nil)
+	if want := "wasm: guestConfig and guestConfigURL are mutually exclusive"; err == nil || err.Error() != want {
+		t.Fatalf("unexpected error: want %s, have %v", want, err)
+	}
+}
@@ -0,0 +1,228 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package wasm
+
+import (
+	"context"
+	"encoding"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	wazeroapi "github.com/tetratelabs/wazero/api"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+)
+
+// StateData is a framework.StateData written by a guest. Its value is the
+// bytes the guest wrote, so other plugins must agree on their encoding.
+//
+// Guests can read any value that implements encoding.BinaryMarshaler, which
+// StateData does. This allows native plugins to share data with guests, by
+// writing values that implement it, or reading values of type StateData.
+type StateData []byte
+
+// Clone implements the same method as documented on framework.StateData.
+//
+// StateData is never changed after it is written, so it isn't copied.
+func (d StateData) Clone() framework.StateData {
+	return d
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (d StateData) MarshalBinary() ([]byte, error) {
+	return d, nil
+}
+
+// marshalStateData returns the encoding of a framework.CycleState value read
+// by a guest.
+func marshalStateData(data framework.StateData) ([]byte, error) {
+	m, ok := data.(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("wasm: cycle state value %T doesn't implement encoding.BinaryMarshaler", data)
+	}
+	return m.MarshalBinary()
+}
+
+// cycleStateData identifies a framework.CycleState, so that the guest can
+// keep a copy of its cycle state per framework.CycleState.
+//
+// The guest holds the cycle state, so the host only sees the key written in
+// PreFilter. The scheduler clones framework.CycleState when it filters with
+// nominated pods or selects victims for preemption, then calls AddPod,
+// RemovePod and Filter with the clone. Such calls can interleave with calls
+// using the original, so the host has the guest snapshot its cycle state
+// before switching to another, and restore it when switching back.
+//
+// Preemption clones the state for each node, so the host has the guest drop
+// the snapshot of a clone once the garbage collector finds the scheduler
+// released it. Clones reference their parent, so a snapshot isn't dropped
+// while it may be restored for a clone of it.
+type cycleStateData struct {
+	// id is unique for the plugin, and never zero.
+	id uint32
+	// parent is the state this was cloned from, or nil.
+	parent *cycleStateData
+	// nextID is shared by all states of the plugin.
+	nextID *atomic.Uint32
+}
+
+// Clone implements the same method as documented on framework.StateData.
+func (d *cycleStateData) Clone() framework.StateData {
+	return &cycleStateData{id: d.nextID.Add(1), parent: d, nextID: d.nextID}
+}
+
+// cycleStateKey returns the key of cycleStateData in framework.CycleState.
+func (pl *wasmPlugin) cycleStateKey() framework.StateKey {
+	return framework.StateKey("wasm/" + pl.pluginName)
+}
+
+// newCycleState writes a new cycleStateData to the state, when it isn't nil.
+func (pl *wasmPlugin) newCycleState(state *framework.CycleState) *cycleStateData {
+	if state == nil {
+		return nil
+	}
+	data := &cycleStateData{id: pl.cycleStateIDs.Add(1), nextID: &pl.cycleStateIDs}
+	state.Write(pl.cycleStateKey(), data)
+	return data
+}
+
+// cycleStateData returns the cycleStateData in the state, or nil if there is
+// none. For example, the guest doesn't export prefilter.
+func (pl *wasmPlugin) cycleStateData(state *framework.CycleState) *cycleStateData {
+	if state == nil {
+		return nil
+	}
+	if data, err := state.Read(pl.cycleStateKey()); err == nil {
+		return data.(*cycleStateData)
+	}
+	return nil
+}
+
+// doWithCycleState runs the function with the scheduling guest of the pod,
+// after switching the guest to the cycle state of the call.
+func (pl *wasmPlugin) doWithCycleState(ctx context.Context, state *framework.CycleState, podUID types.UID, fn func(*guest)) (err error) {
+	data := pl.cycleStateData(state)
+	if poolErr := pl.schedulingPool(podUID).doWithSchedulingGuest(ctx, podUID, func(g *guest) {
+		if err = g.useCycleState(ctx, data); err == nil {
+			fn(g)
+		}
+	}); poolErr != nil {
+		return poolErr
+	}
+	return
+}
+
+// resetCycleState records that the guest started a new cycle state in
+// PreFilter, so any snapshots are gone.
+func (g *guest) resetCycleState(data *cycleStateData) {
+	g.cycleState = data
+	clear(g.cycleStateSnapshots)
+}
+
+// releasedCycleStates are the IDs of cycle states released by the scheduler.
+// The garbage collector adds to them, so they are guarded by a lock.
+type releasedCycleStates struct {
+	mux sync.Mutex
+	ids []uint32
+}
+
+// add is called by the garbage collector when the cycle state is unreachable.
+func (r *releasedCycleStates) add(id uint32) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.ids = append(r.ids, id)
+}
+
+// take returns the IDs added since the last call.
+func (r *releasedCycleStates) take() (ids []uint32) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	ids, r.ids = r.ids, nil
+	return
+}
+
+// useCycleState switches the guest to the cycle state identified by data,
+// when it is not the last used. This is a no-op for guests that don't export
+// guestExportSnapshotCycleState.
+func (g *guest) useCycleState(ctx context.Context, data *cycleStateData) error {
+	if data == nil || g.snapshotcyclestateFn == nil || data == g.cycleState {
+		return nil
+	}
+
+	if err := g.dropReleasedCycleStates(ctx); err != nil {
+		return err
+	}
+
+	// Save the current state, so that calls using it can switch back. Its
+	// snapshot is dropped once the scheduler releases it.
+	current := g.cycleState
+	if current != nil {
+		if err := g.callWithCycleStateID(ctx, guestExportSnapshotCycleState, g.snapshotcyclestateFn, current.id); err != nil {
+			return err
+		}
+		if _, ok := g.cycleStateSnapshots[current.id]; !ok {
+			g.cycleStateSnapshots[current.id] = struct{}{}
+			runtime.AddCleanup(current, g.releasedCycleStates.add, current.id)
+		}
+	}
+
+	// A clone not used before has the state of the closest ancestor that was.
+	// Scheduler doesn't change a state after cloning it, so its last snapshot
+	// is the same as at the time of cloning.
+	from := data
+	for from != nil {
+		if _, ok := g.cycleStateSnapshots[from.id]; ok {
+			break
+		}
+		from = from.parent
+	}
+	if from != nil && from != current {
+		if err := g.callWithCycleStateID(ctx, guestExportRestoreCycleState, g.restorecyclestateFn, from.id); err != nil {
+			return err
+		}
+	}
+	g.cycleState = data
+	return nil
+}
+
+// dropReleasedCycleStates forgets snapshots of cycle states the scheduler
+// released, calling guestExportDropCycleState if the guest exports it.
+func (g *guest) dropReleasedCycleStates(ctx context.Context) error {
+	for _, id := range g.releasedCycleStates.take() {
+		if _, ok := g.cycleStateSnapshots[id]; !ok {
+			continue // already cleared by PreFilter
+		}
+		delete(g.cycleStateSnapshots, id)
+		if g.dropcyclestateFn != nil {
+			if err := g.callWithCycleStateID(ctx, guestExportDropCycleState, g.dropcyclestateFn, id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// callWithCycleStateID calls a guest function which accepts a cycle state ID.
+func (g *guest) callWithCycleStateID(ctx context.Context, name string, fn wazeroapi.Function, id uint32) error {
+	defer g.out.Reset()
+	g.callStack[0] = uint64(id)
+	return g.call(ctx, name, fn)
+}
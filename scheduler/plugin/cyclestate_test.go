@@ -0,0 +1,83 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package wasm
+
+import (
+	"io"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+
+	"sigs.k8s.io/kube-scheduler-wasm-extension/scheduler/test"
+)
+
+func Test_dropReleasedCycleStates(t *testing.T) {
+	p, err := NewFromConfig(ctx, "wasm", PluginConfig{GuestURL: test.URLTestCycleStateFromGlobal}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.(io.Closer).Close()
+	pl := p.(ProfilerSupport).plugin()
+
+	pod := test.PodSmall
+	ni := framework.NewNodeInfo()
+	ni.SetNode(test.NodeSmall)
+	state := framework.NewCycleState()
+	if _, status := pl.PreFilter(ctx, state, pod); !status.IsSuccess() {
+		t.Fatal(status)
+	}
+
+	// Filter with a clone, like preemption does for a node, then switch back
+	// to the original. This saves a snapshot of the clone.
+	cloneID := func() uint32 {
+		clone := state.Clone()
+		_ = pl.Filter(ctx, clone, pod, ni)
+		return pl.cycleStateData(clone).id
+	}()
+	_ = pl.Filter(ctx, state, pod, ni)
+
+	g := pl.gen.pool.scheduled
+	if _, ok := g.cycleStateSnapshots[cloneID]; !ok {
+		t.Fatal("expected a snapshot of the clone")
+	}
+
+	// The clone is no longer referenced, so it is released once collected.
+	released := func() bool {
+		g.releasedCycleStates.mux.Lock()
+		defer g.releasedCycleStates.mux.Unlock()
+		return slices.Contains(g.releasedCycleStates.ids, cloneID)
+	}
+	for deadline := time.Now().Add(10 * time.Second); !released(); {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the clone to be released")
+		}
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The next switch drops the snapshot, in the host and the guest.
+	_ = pl.Filter(ctx, state.Clone(), pod, ni)
+	if _, ok := g.cycleStateSnapshots[cloneID]; ok {
+		t.Fatal("expected the snapshot of the clone to be dropped")
+	}
+	if want, have := cloneID, uint32(g.guest.ExportedGlobal("dropped_global").Get()); want != have {
+		t.Fatalf("unexpected dropped cycle state: want %d, have %d", want, have)
+	}
+}
@@ -0,0 +1,48 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package wasm
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/tetratelabs/wazero"
+)
+
+// Values of PluginConfig.Engine.
+const (
+	engineAuto        = "auto"
+	engineCompiler    = "compiler"
+	engineInterpreter = "interpreter"
+)
+
+// newRuntimeConfig returns the runtime config of the engine. This returns an
+// error instead of letting wazero panic when the compiler isn't supported.
+func newRuntimeConfig(engine string) (wazero.RuntimeConfig, error) {
+	switch engine {
+	case "", engineAuto:
+		return wazero.NewRuntimeConfig(), nil
+	case engineCompiler:
+		if !compilerSupported {
+			return nil, fmt.Errorf("wasm: engine %s is not supported on %s/%s", engine, runtime.GOOS, runtime.GOARCH)
+		}
+		return wazero.NewRuntimeConfigCompiler(), nil
+	case engineInterpreter:
+		return wazero.NewRuntimeConfigInterpreter(), nil
+	}
+	return nil, fmt.Errorf("wasm: invalid engine %q, expected %s, %s or %s", engine, engineCompiler, engineInterpreter, engineAuto)
+}
@@ -0,0 +1,24 @@
+//go:build (amd64 || arm64) && (darwin || linux || freebsd || windows)
+
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package wasm
+
+// compilerSupported is true when the wazero compiler supports the platform.
+// The build constraints must match those of wazero.NewRuntimeConfig, which
+// uses the compiler when supported.
+const compilerSupported = true
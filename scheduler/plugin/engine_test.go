@@ -0,0 +1,70 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package wasm
+
+import (
+	"io"
+	"testing"
+
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+
+	"sigs.k8s.io/kube-scheduler-wasm-extension/scheduler/test"
+)
+
+func TestNewFromConfig_engine(t *testing.T) {
+	tests := []struct {
+		engine        string
+		expectedError string
+	}{
+		{engine: ""},
+		{engine: engineAuto},
+		{engine: engineCompiler},
+		{engine: engineInterpreter},
+		{
+			engine:        "jit",
+			expectedError: `wasm: invalid engine "jit", expected compiler, interpreter or auto`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.engine, func(t *testing.T) {
+			if tc.engine == engineCompiler && !compilerSupported {
+				t.Skip("compiler not supported on this platform")
+			}
+			p, err := NewFromConfig(ctx, "engine", PluginConfig{
+				GuestURL: test.URLTestFilterFromGlobal,
+				Engine:   tc.engine,
+			}, nil)
+			if tc.expectedError != "" {
+				if err == nil || err.Error() != tc.expectedError {
+					t.Fatalf("unexpected error: want %s, have %v", tc.expectedError, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			defer p.(io.Closer).Close()
+
+			// The guest runs the same in any engine.
+			ni := framework.NewNodeInfo()
+			ni.SetNode(test.NodeSmall)
+			if s := p.(framework.FilterPlugin).Filter(ctx, nil, test.PodSmall, ni); !s.IsSuccess() {
+				t.Fatalf("unexpected status: %v", s)
+			}
+		})
+	}
+}
@@ -34,6 +34,7 @@ type (
 	ReservePlugin = reservePlugin
 	PermitPlugin  = permitPlugin
 	BindPlugin    = bindPlugin
+	Guest         = guest
 )
 
 type WasmPlugin struct{ *wasmPlugin }
@@ -46,7 +47,7 @@ func NewTestWasmPlugin(p framework.Plugin) *WasmPlugin {
 }
 
 func (w *WasmPlugin) SetGlobals(globals map[string]int32) {
-	if err := w.pool.doWithSchedulingGuest(ctx, uuid.NewUUID(), func(g *guest) {
+	if err := w.gen.pool.doWithSchedulingGuest(ctx, uuid.NewUUID(), func(g *guest) {
 		// Use test conventions to set a global used to test value range.
 		for n, v := range globals {
 			g.guest.ExportedGlobal(n + "_global").(wazeroapi.MutableGlobal).Set(uint64(v))
@@ -56,25 +57,88 @@ func (w *WasmPlugin) SetGlobals(globals map[string]int32) {
 	}
 }
 
+// SetFreeGlobals is like SetGlobals, except for a guest not in any cycle.
+func (w *WasmPlugin) SetFreeGlobals(globals map[string]int32) {
+	if err := w.gen.pool.doWithFreeGuest(ctx, func(g *guest) {
+		for n, v := range globals {
+			g.guest.ExportedGlobal(n + "_global").(wazeroapi.MutableGlobal).Set(uint64(v))
+		}
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// GetGlobals returns the value of globals set by a guest not in any cycle.
+func (w *WasmPlugin) GetGlobals(names ...string) map[string]int32 {
+	globals := make(map[string]int32, len(names))
+	if err := w.gen.pool.doWithFreeGuest(ctx, func(g *guest) {
+		// Use test conventions to get a global set by the guest.
+		for _, n := range names {
+			globals[n] = int32(g.guest.ExportedGlobal(n + "_global").Get())
+		}
+	}); err != nil {
+		panic(err)
+	}
+	return globals
+}
+
+// SetSortGlobals is like SetGlobals, except for the guest held for queue sort.
+func (w *WasmPlugin) SetSortGlobals(globals map[string]int32) {
+	if err := w.doWithSortGuest(ctx, w.gen, func(g *guest) {
+		for n, v := range globals {
+			g.guest.ExportedGlobal(n + "_global").(wazeroapi.MutableGlobal).Set(uint64(v))
+		}
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// GetSortGlobals is like GetGlobals, except for the guest held for queue sort.
+func (w *WasmPlugin) GetSortGlobals(names ...string) map[string]int32 {
+	globals := make(map[string]int32, len(names))
+	if err := w.doWithSortGuest(ctx, w.gen, func(g *guest) {
+		for _, n := range names {
+			globals[n] = int32(g.guest.ExportedGlobal(n + "_global").Get())
+		}
+	}); err != nil {
+		panic(err)
+	}
+	return globals
+}
+
 // CreateGuestInBindingGuestPool creates a guest for the pod in the binding guest pool.
 func (w *WasmPlugin) CreateGuestInBindingGuestPool(podUID types.UID) {
 	// In an actual scheduling, the guest is put in the binding pool when Permit is executed at the end of the scheduling cycle.
-	_ = w.pool.doWithSchedulingGuest(context.Background(), podUID, func(*guest) {})
-	_ = w.pool.getForBinding(podUID)
+	_ = w.gen.pool.doWithSchedulingGuest(context.Background(), podUID, func(*guest) {})
+	_, _ = w.gen.pool.getForBinding(context.Background(), podUID)
 }
 
 func (w *WasmPlugin) ClearGuestModule() {
-	w.guestModule = nil
+	w.gen.guestModule = nil
 }
 
 func (w *WasmPlugin) GetScheduledPodUID() types.UID {
-	return w.pool.scheduledPodUID
+	return w.gen.pool.scheduledPodUID
+}
+
+func (w *WasmPlugin) GetScheduledGuest() *guest {
+	return w.gen.pool.scheduled
+}
+
+func (g *guest) IsClosed() bool {
+	return g.isClosed()
 }
 
 func (w *WasmPlugin) GetBindingCycles() map[types.UID]*guest {
-	return w.pool.binding
+	return w.gen.pool.binding
+}
+
+func (w *WasmPlugin) ClearBindingCycles() {
+	for podUID := range w.gen.pool.binding {
+		w.gen.pool.freeFromBinding(podUID)
+	}
 }
 
 func (w *WasmPlugin) GetFreePool() []*guest {
-	return w.pool.free
+	return w.gen.pool.free
 }
@@ -19,12 +19,17 @@ package wasm
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"strconv"
 	"time"
 
 	"github.com/tetratelabs/wazero"
 	wazeroapi "github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/sys"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/klog/v2"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
 )
@@ -32,6 +37,9 @@ import (
 const (
 	guestExportMemory         = "memory"
 	guestExportEnqueue        = "enqueue"
+	guestExportQueueingHint   = "queueinghint"
+	guestExportPreEnqueue     = "preenqueue"
+	guestExportLess           = "less"
 	guestExportPreFilter      = "prefilter"
 	guestExportFilter         = "filter"
 	guestExportPostFilter     = "postfilter"
@@ -46,12 +54,19 @@ const (
 	guestExportPostBind       = "postbind"
 	guestExportAddPod         = "addpod"
 	guestExportRemovePod      = "removepod"
+
+	guestExportSnapshotCycleState = "snapshotcyclestate"
+	guestExportRestoreCycleState  = "restorecyclestate"
+	guestExportDropCycleState     = "dropcyclestate"
 )
 
 type guest struct {
 	guest            wazeroapi.Module
-	out              *bytes.Buffer
+	out              *guestOutput
 	enqueueFn        wazeroapi.Function
+	queueinghintFn   wazeroapi.Function
+	preenqueueFn     wazeroapi.Function
+	lessFn           wazeroapi.Function
 	prefilterFn      wazeroapi.Function
 	filterFn         wazeroapi.Function
 	postfilterFn     wazeroapi.Function
@@ -67,6 +82,28 @@ type guest struct {
 	addpodFn         wazeroapi.Function
 	removepodFn      wazeroapi.Function
 	callStack        []uint64
+
+	snapshotcyclestateFn wazeroapi.Function
+	restorecyclestateFn  wazeroapi.Function
+	dropcyclestateFn     wazeroapi.Function
+
+	// cycleState is the cycle state last used by the guest, or nil if
+	// unknown.
+	cycleState *cycleStateData
+
+	// cycleStateSnapshots are the IDs of cycle states the guest saved since
+	// the last PreFilter.
+	cycleStateSnapshots map[uint32]struct{}
+
+	// releasedCycleStates are the IDs of saved cycle states the scheduler no
+	// longer references, so that the guest can drop them.
+	releasedCycleStates *releasedCycleStates
+
+	// pluginName labels metrics about calls to this guest.
+	pluginName string
+
+	// timeouts are any execution deadlines, keyed by guest export name.
+	timeouts map[string]time.Duration
 }
 
 func compileGuest(ctx context.Context, runtime wazero.Runtime, guestBin []byte) (guest wazero.CompiledModule, err error) {
@@ -78,35 +115,48 @@ func compileGuest(ctx context.Context, runtime wazero.Runtime, guestBin []byte)
 	return
 }
 
-func (pl *wasmPlugin) newGuest(ctx context.Context) (*guest, error) {
+// newGuest instantiates the guest compiled in the generation.
+func (pl *wasmPlugin) newGuest(ctx context.Context, gen *generation) (*guest, error) {
 	// The name isn't important, but it needs to be unique.
 	instanceNum := pl.instanceCounter.Add(1)
 	moduleConfig := pl.guestModuleConfig.WithName(strconv.FormatUint(instanceNum, 10))
 
-	// A guest may have an instantiation error, which writes to stdout or stderr.
-	// Capture stdout and stderr during instantiation.
-	var out bytes.Buffer
-	moduleConfig = moduleConfig.WithStdout(&out).WithStderr(&out)
+	// Log stdout and stderr, keeping the tail of each call for any error. A
+	// guest may have an instantiation error, which writes to them.
+	out := newGuestOutput(pl.pluginName, pl.outputConfig)
+	out.begin(ctx, "instantiate")
+	moduleConfig = moduleConfig.WithStdout(&out.stdout).WithStderr(&out.stderr)
 
 	// Set any args used for testing
 	moduleConfig = moduleConfig.WithArgs(pl.guestArgs...)
 
-	g, err := pl.runtime.InstantiateModule(ctx, pl.guestModule, moduleConfig)
+	// Enforce any memory limit, on memory allocated by this instance.
+	if limit := pl.guestMemoryLimit; limit > 0 {
+		ctx = experimental.WithMemoryAllocator(ctx, memoryLimiter(limit))
+	}
+
+	// Guests are re-created after they trap, so an error here must not close
+	// the runtime. wazero cleans up the failed module.
+	g, err := gen.runtime.InstantiateModule(ctx, gen.guestModule, moduleConfig)
+	out.flush()
 	if err != nil {
-		_ = pl.runtime.Close(ctx)
-		return nil, decorateError(&out, "instantiate", err)
+		return nil, decorateError(out, "instantiate", err)
 	} else {
 		out.Reset()
 	}
+	guestInstantiations.WithLabelValues(pl.pluginName).Inc()
 
 	// Allocate a call stack sized to max of params / return values of any
-	// guest function.
-	callStack := make([]uint64, 1)
+	// guest function, which is currently guestExportLess.
+	callStack := make([]uint64, 6)
 
 	return &guest{
 		guest:            g,
-		out:              &out,
+		out:              out,
 		enqueueFn:        g.ExportedFunction(guestExportEnqueue),
+		queueinghintFn:   g.ExportedFunction(guestExportQueueingHint),
+		preenqueueFn:     g.ExportedFunction(guestExportPreEnqueue),
+		lessFn:           g.ExportedFunction(guestExportLess),
 		prefilterFn:      g.ExportedFunction(guestExportPreFilter),
 		filterFn:         g.ExportedFunction(guestExportFilter),
 		postfilterFn:     g.ExportedFunction(guestExportPostFilter),
@@ -122,18 +172,166 @@ func (pl *wasmPlugin) newGuest(ctx context.Context) (*guest, error) {
 		addpodFn:         g.ExportedFunction(guestExportAddPod),
 		removepodFn:      g.ExportedFunction(guestExportRemovePod),
 		callStack:        callStack,
+		pluginName:       pl.pluginName,
+		timeouts:         pl.guestTimeouts,
+
+		snapshotcyclestateFn: g.ExportedFunction(guestExportSnapshotCycleState),
+		restorecyclestateFn:  g.ExportedFunction(guestExportRestoreCycleState),
+		dropcyclestateFn:     g.ExportedFunction(guestExportDropCycleState),
+		cycleStateSnapshots:  map[uint32]struct{}{},
+		releasedCycleStates:  &releasedCycleStates{},
 	}, nil
 }
 
-// eventsToRegister calls guestExportEnqueue.
-func (g *guest) eventsToRegister(ctx context.Context) []framework.ClusterEvent {
+// call invokes the guest function with the current call stack, bounded by
+// any timeout configured for its extension point.
+//
+// When the call fails for any reason, such as a trap or exceeding its
+// timeout, the guest module is closed, as its memory and globals may be
+// corrupt. In that case, isClosed returns true and the guest must not be
+// returned to the pool.
+//
+// When ctx is traced, the call and any host functions it calls are recorded as
+// spans.
+func (g *guest) call(ctx context.Context, name string, fn wazeroapi.Function) (err error) {
+	ctx, span := startGuestSpan(ctx, g.pluginName, name)
+	defer func() { endSpan(span, err) }()
+
+	parent := ctx
+	timeout := g.timeouts[name]
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	g.out.begin(ctx, name)
+	start := time.Now()
+	err = fn.CallWithStack(ctx, g.callStack)
+	g.out.flush()
+	guestCallDuration.WithLabelValues(g.pluginName, name).Observe(time.Since(start).Seconds())
+	if err == nil {
+		return nil
+	}
+
+	// Poison the guest, unless wazero already closed it.
+	g.close()
+
+	reason := errorReasonTrap
+	var exitErr *sys.ExitError
+	var limitErr *memoryLimitError
+	if errors.As(err, &exitErr) {
+		switch exitErr.ExitCode() {
+		case sys.ExitCodeDeadlineExceeded, sys.ExitCodeContextCanceled:
+			// Only blame the timeout if the caller's context wasn't also done.
+			if parentErr := parent.Err(); parentErr != nil {
+				err = fmt.Errorf("guest closed: %w", parentErr)
+			} else if timeout > 0 {
+				err = fmt.Errorf("timeout of %v exceeded", timeout)
+				reason = errorReasonTimeout
+			}
+		}
+	} else if errors.As(err, &limitErr) {
+		// The guest tried to grow its memory past the limit. Use the error
+		// as-is, as the rest is noise from wazero recovering the panic.
+		err = limitErr
+		reason = errorReasonMemoryLimit
+	}
+	guestCallErrors.WithLabelValues(g.pluginName, name, reason).Inc()
+	return decorateError(g.out, name, err)
+}
+
+// isClosed returns true when the guest can no longer be used. For example,
+// it trapped or its execution deadline was exceeded.
+func (g *guest) isClosed() bool {
+	return g.guest.IsClosed()
+}
+
+// close closes the guest module, so that it can no longer be used.
+func (g *guest) close() {
+	_ = g.guest.Close(context.Background())
+}
+
+// registeredEvents are the cluster events returned by a guest, and whether it
+// exports queueing hints for them.
+type registeredEvents struct {
+	events          []framework.ClusterEvent
+	hasQueueingHint bool
+}
+
+// equal returns true if both guests register the same events, in the same
+// order, and either both or neither have queueing hints.
+func (e *registeredEvents) equal(other *registeredEvents) bool {
+	return e.hasQueueingHint == other.hasQueueingHint && slices.Equal(e.events, other.events)
+}
+
+// registeredEvents calls guestExportEnqueue.
+func (g *guest) registeredEvents(ctx context.Context) (*registeredEvents, error) {
+	defer g.out.Reset()
+	if err := g.call(ctx, guestExportEnqueue, g.enqueueFn); err != nil {
+		return nil, err
+	}
+	return &registeredEvents{
+		events:          paramsFromContext(ctx).resultClusterEvents,
+		hasQueueingHint: g.queueinghintFn != nil,
+	}, nil
+}
+
+// queueingHint calls guestExportQueueingHint with the index of the event in
+// the result of eventsToRegister.
+func (g *guest) queueingHint(ctx context.Context, eventIndex int) (framework.QueueingHint, error) {
 	defer g.out.Reset()
 	callStack := g.callStack
-	if err := g.enqueueFn.CallWithStack(ctx, callStack); err != nil {
-		// framework.EnqueueExtensions.EventsToRegister() does not return an error
-		panic(err)
+	callStack[0] = uint64(eventIndex)
+
+	if err := g.call(ctx, guestExportQueueingHint, g.queueinghintFn); err != nil {
+		return framework.Queue, err
+	}
+	switch hint := framework.QueueingHint(int32(callStack[0])); hint {
+	case framework.QueueSkip, framework.Queue:
+		return hint, nil
+	default:
+		return framework.Queue, fmt.Errorf("wasm: guest returned invalid queueing hint %d", hint)
 	}
-	return paramsFromContext(ctx).resultClusterEvents
+}
+
+// preEnqueue calls guestExportPreEnqueue.
+func (g *guest) preEnqueue(ctx context.Context) *framework.Status {
+	defer g.out.Reset()
+	callStack := g.callStack
+
+	if err := g.call(ctx, guestExportPreEnqueue, g.preenqueueFn); err != nil {
+		return framework.AsStatus(err)
+	}
+	statusCode := int32(callStack[0])
+	statusReason := paramsFromContext(ctx).resultStatusReason
+	return framework.NewStatus(framework.Code(statusCode), statusReason)
+}
+
+// less calls guestExportLess with the scheduling queue fields of each pod.
+// The pods themselves are read with host functions, if needed.
+func (g *guest) less(ctx context.Context, podInfo1, podInfo2 *framework.QueuedPodInfo) (bool, error) {
+	defer g.out.Reset()
+	callStack := g.callStack
+	callStack[0], callStack[1], callStack[2] = encodeQueuedPodInfo(podInfo1)
+	callStack[3], callStack[4], callStack[5] = encodeQueuedPodInfo(podInfo2)
+
+	if err := g.call(ctx, guestExportLess, g.lessFn); err != nil {
+		return false, err
+	}
+	return uint32(callStack[0]) != 0, nil
+}
+
+// encodeQueuedPodInfo returns the timestamp and initial attempt timestamp in
+// Unix nanoseconds, and the attempts. The initial attempt timestamp is zero
+// when unset.
+func encodeQueuedPodInfo(podInfo *framework.QueuedPodInfo) (timestamp, initialAttemptTimestamp, attempts uint64) {
+	timestamp = uint64(podInfo.Timestamp.UnixNano())
+	if t := podInfo.InitialAttemptTimestamp; t != nil {
+		initialAttemptTimestamp = uint64(t.UnixNano())
+	}
+	attempts = uint64(uint32(podInfo.Attempts))
+	return
 }
 
 // preFilter calls guestExportPreFilter.
@@ -141,8 +339,8 @@ func (g *guest) preFilter(ctx context.Context) ([]string, *framework.Status) {
 	defer g.out.Reset()
 	callStack := g.callStack
 
-	if err := g.prefilterFn.CallWithStack(ctx, callStack); err != nil {
-		return nil, framework.AsStatus(decorateError(g.out, guestExportPreFilter, err))
+	if err := g.call(ctx, guestExportPreFilter, g.prefilterFn); err != nil {
+		return nil, framework.AsStatus(err)
 	}
 	nodeNames := paramsFromContext(ctx).resultNodeNames
 	statusCode := int32(callStack[0])
@@ -155,8 +353,8 @@ func (g *guest) filter(ctx context.Context) *framework.Status {
 	defer g.out.Reset()
 	callStack := g.callStack
 
-	if err := g.filterFn.CallWithStack(ctx, callStack); err != nil {
-		return framework.AsStatus(decorateError(g.out, guestExportFilter, err))
+	if err := g.call(ctx, guestExportFilter, g.filterFn); err != nil {
+		return framework.AsStatus(err)
 	}
 	statusCode := int32(callStack[0])
 	statusReason := paramsFromContext(ctx).resultStatusReason
@@ -167,8 +365,8 @@ func (g *guest) filter(ctx context.Context) *framework.Status {
 func (g *guest) postFilter(ctx context.Context) (*framework.PostFilterResult, *framework.Status) {
 	defer g.out.Reset()
 	callStack := g.callStack
-	if err := g.postfilterFn.CallWithStack(ctx, callStack); err != nil {
-		return nil, framework.AsStatus(decorateError(g.out, guestExportPostFilter, err))
+	if err := g.call(ctx, guestExportPostFilter, g.postfilterFn); err != nil {
+		return nil, framework.AsStatus(err)
 	}
 	nominatedNodeName := paramsFromContext(ctx).resultNominatedNodeName
 	nominatingMode := framework.NominatingMode(int32(callStack[0] >> 32))
@@ -185,8 +383,8 @@ func (g *guest) preScore(ctx context.Context) *framework.Status {
 	defer g.out.Reset()
 	callStack := g.callStack
 
-	if err := g.prescoreFn.CallWithStack(ctx, callStack); err != nil {
-		return framework.AsStatus(decorateError(g.out, guestExportPreScore, err))
+	if err := g.call(ctx, guestExportPreScore, g.prescoreFn); err != nil {
+		return framework.AsStatus(err)
 	}
 	statusCode := int32(callStack[0])
 	statusReason := paramsFromContext(ctx).resultStatusReason
@@ -198,8 +396,8 @@ func (g *guest) score(ctx context.Context) (int64, *framework.Status) {
 	defer g.out.Reset()
 	callStack := g.callStack
 
-	if err := g.scoreFn.CallWithStack(ctx, callStack); err != nil {
-		return 0, framework.AsStatus(decorateError(g.out, guestExportScore, err))
+	if err := g.call(ctx, guestExportScore, g.scoreFn); err != nil {
+		return 0, framework.AsStatus(err)
 	}
 
 	score := int32(callStack[0] >> 32)
@@ -213,8 +411,8 @@ func (g *guest) normalizeScore(ctx context.Context) (framework.NodeScoreList, *f
 	defer g.out.Reset()
 	callStack := g.callStack
 
-	if err := g.normalizescoreFn.CallWithStack(ctx, callStack); err != nil {
-		return nil, framework.AsStatus(decorateError(g.out, guestExportNormalizeScore, err))
+	if err := g.call(ctx, guestExportNormalizeScore, g.normalizescoreFn); err != nil {
+		return nil, framework.AsStatus(err)
 	}
 
 	statusCode := int32(callStack[0])
@@ -233,8 +431,8 @@ func (g *guest) reserve(ctx context.Context) *framework.Status {
 	defer g.out.Reset()
 	callStack := g.callStack
 
-	if err := g.reserveFn.CallWithStack(ctx, callStack); err != nil {
-		return framework.AsStatus(decorateError(g.out, guestExportReserve, err))
+	if err := g.call(ctx, guestExportReserve, g.reserveFn); err != nil {
+		return framework.AsStatus(err)
 	}
 
 	statusCode := int32(callStack[0])
@@ -245,11 +443,9 @@ func (g *guest) reserve(ctx context.Context) *framework.Status {
 // unreserve calls guestExportUnreserve.
 func (g *guest) unreserve(ctx context.Context) {
 	defer g.out.Reset()
-	callStack := g.callStack
-
 	logger := klog.FromContext(ctx)
-	if err := g.unreserveFn.CallWithStack(ctx, callStack); err != nil {
-		logger.Error(decorateError(g.out, guestExportUnreserve, err), "failed unreserve")
+	if err := g.call(ctx, guestExportUnreserve, g.unreserveFn); err != nil {
+		logger.Error(err, "failed unreserve")
 	}
 }
 
@@ -258,8 +454,8 @@ func (g *guest) permit(ctx context.Context) (*framework.Status, time.Duration) {
 	defer g.out.Reset()
 	callStack := g.callStack
 
-	if err := g.permitFn.CallWithStack(ctx, callStack); err != nil {
-		return framework.AsStatus(decorateError(g.out, guestExportPermit, err)), 0
+	if err := g.call(ctx, guestExportPermit, g.permitFn); err != nil {
+		return framework.AsStatus(err), 0
 	}
 
 	statusCode := int32(callStack[0] >> 32)
@@ -273,8 +469,8 @@ func (g *guest) preBind(ctx context.Context) *framework.Status {
 	defer g.out.Reset()
 	callStack := g.callStack
 
-	if err := g.prebindFn.CallWithStack(ctx, callStack); err != nil {
-		return framework.AsStatus(decorateError(g.out, guestExportPreBind, err))
+	if err := g.call(ctx, guestExportPreBind, g.prebindFn); err != nil {
+		return framework.AsStatus(err)
 	}
 
 	statusCode := int32(callStack[0])
@@ -287,8 +483,8 @@ func (g *guest) bind(ctx context.Context) *framework.Status {
 	defer g.out.Reset()
 	callStack := g.callStack
 
-	if err := g.bindFn.CallWithStack(ctx, callStack); err != nil {
-		return framework.AsStatus(decorateError(g.out, guestExportBind, err))
+	if err := g.call(ctx, guestExportBind, g.bindFn); err != nil {
+		return framework.AsStatus(err)
 	}
 
 	statusCode := int32(callStack[0])
@@ -299,10 +495,9 @@ func (g *guest) bind(ctx context.Context) *framework.Status {
 // postBind calls guestExportPostBind.
 func (g *guest) postBind(ctx context.Context) {
 	defer g.out.Reset()
-	callStack := g.callStack
 	logger := klog.FromContext(ctx)
-	if err := g.postbindFn.CallWithStack(ctx, callStack); err != nil {
-		logger.Error(decorateError(g.out, guestExportPostBind, err), "failed postbind")
+	if err := g.call(ctx, guestExportPostBind, g.postbindFn); err != nil {
+		logger.Error(err, "failed postbind")
 	}
 }
 
@@ -311,8 +506,8 @@ func (g *guest) addPod(ctx context.Context) *framework.Status {
 	defer g.out.Reset()
 	callStack := g.callStack
 
-	if err := g.addpodFn.CallWithStack(ctx, callStack); err != nil {
-		return framework.AsStatus(decorateError(g.out, guestExportAddPod, err))
+	if err := g.call(ctx, guestExportAddPod, g.addpodFn); err != nil {
+		return framework.AsStatus(err)
 	}
 
 	statusCode := int32(callStack[0])
@@ -325,8 +520,8 @@ func (g *guest) removePod(ctx context.Context) *framework.Status {
 	defer g.out.Reset()
 	callStack := g.callStack
 
-	if err := g.removepodFn.CallWithStack(ctx, callStack); err != nil {
-		return framework.AsStatus(decorateError(g.out, guestExportRemovePod, err))
+	if err := g.call(ctx, guestExportRemovePod, g.removepodFn); err != nil {
+		return framework.AsStatus(err)
 	}
 
 	statusCode := int32(callStack[0])
@@ -344,6 +539,58 @@ func decorateError(out fmt.Stringer, fn string, err error) error {
 	return err
 }
 
+// parseTimeouts validates the timeouts in PluginConfig, keyed by guest export
+// name, and converts them to durations.
+func parseTimeouts(timeouts map[string]metav1.Duration) (map[string]time.Duration, error) {
+	if len(timeouts) == 0 {
+		return nil, nil
+	}
+	result := make(map[string]time.Duration, len(timeouts))
+	for name, timeout := range timeouts {
+		switch name {
+		case guestExportEnqueue:
+			// framework.EnqueueExtensions has no error result, so a timeout
+			// could only be surfaced as a panic.
+			return nil, fmt.Errorf("wasm: invalid timeout for %s: extension point cannot be bounded", name)
+		case guestExportQueueingHint, guestExportPreEnqueue, guestExportLess, guestExportPreFilter, guestExportFilter,
+			guestExportPostFilter, guestExportPreScore, guestExportScore,
+			guestExportNormalizeScore, guestExportReserve, guestExportUnreserve,
+			guestExportPermit, guestExportPreBind, guestExportBind,
+			guestExportPostBind, guestExportAddPod, guestExportRemovePod:
+		default:
+			return nil, fmt.Errorf("wasm: invalid timeout for unknown extension point %q", name)
+		}
+		if timeout.Duration <= 0 {
+			return nil, fmt.Errorf("wasm: invalid timeout for %s: %v must be positive", name, timeout.Duration)
+		}
+		result[name] = timeout.Duration
+	}
+	return result, nil
+}
+
+// parsePoolConfig validates the guest pool settings in PluginConfig.
+func parsePoolConfig(config PluginConfig) (guestPoolConfig, error) {
+	c := guestPoolConfig{
+		minSize: config.PoolMinSize,
+		maxSize: config.PoolMaxSize,
+		maxWait: config.PoolMaxWait.Duration,
+		idleTTL: config.PoolIdleTTL.Duration,
+	}
+	switch {
+	case c.minSize < 0:
+		return c, fmt.Errorf("wasm: invalid poolMinSize %d: must not be negative", c.minSize)
+	case c.maxSize < 0:
+		return c, fmt.Errorf("wasm: invalid poolMaxSize %d: must not be negative", c.maxSize)
+	case c.maxSize > 0 && max(c.minSize, 1) > c.maxSize:
+		return c, fmt.Errorf("wasm: invalid poolMaxSize %d: must not be less than poolMinSize %d", c.maxSize, max(c.minSize, 1))
+	case c.maxWait < 0:
+		return c, fmt.Errorf("wasm: invalid poolMaxWait %v: must not be negative", c.maxWait)
+	case c.idleTTL < 0:
+		return c, fmt.Errorf("wasm: invalid poolIdleTTL %v: must not be negative", c.idleTTL)
+	}
+	return c, nil
+}
+
 func detectInterfaces(exportedFns map[string]wazeroapi.FunctionDefinition) (interfaces, error) {
 	var e interfaces
 	for name, f := range exportedFns {
@@ -353,6 +600,28 @@ func detectInterfaces(exportedFns map[string]wazeroapi.FunctionDefinition) (inte
 				return 0, fmt.Errorf("wasm: guest exports the wrong signature for func[%s]. should be () -> ()", name)
 			}
 			e |= iEnqueueExtensions
+		case guestExportQueueingHint:
+			// This is only called for events returned by guestExportEnqueue,
+			// so it doesn't add an interface on its own.
+			if !bytes.Equal(f.ParamTypes(), []wazeroapi.ValueType{i32}) || !bytes.Equal(f.ResultTypes(), []wazeroapi.ValueType{i32}) {
+				return 0, fmt.Errorf("wasm: guest exports the wrong signature for func[%s]. should be (i32) -> (i32)", name)
+			}
+		case guestExportSnapshotCycleState, guestExportRestoreCycleState, guestExportDropCycleState:
+			// These are called by the host to handle cloned cycle state, so
+			// they don't add an interface on their own.
+			if !bytes.Equal(f.ParamTypes(), []wazeroapi.ValueType{i32}) || len(f.ResultTypes()) != 0 {
+				return 0, fmt.Errorf("wasm: guest exports the wrong signature for func[%s]. should be (i32) -> ()", name)
+			}
+		case guestExportPreEnqueue:
+			if len(f.ParamTypes()) != 0 || !bytes.Equal(f.ResultTypes(), []wazeroapi.ValueType{i32}) {
+				return 0, fmt.Errorf("wasm: guest exports the wrong signature for func[%s]. should be () -> (i32)", name)
+			}
+			e |= iPreEnqueuePlugin
+		case guestExportLess:
+			if !bytes.Equal(f.ParamTypes(), []wazeroapi.ValueType{i64, i64, i32, i64, i64, i32}) || !bytes.Equal(f.ResultTypes(), []wazeroapi.ValueType{i32}) {
+				return 0, fmt.Errorf("wasm: guest exports the wrong signature for func[%s]. should be (i64, i64, i32, i64, i64, i32) -> (i32)", name)
+			}
+			e |= iQueueSortPlugin
 		case guestExportPreFilter:
 			if len(f.ParamTypes()) != 0 || !bytes.Equal(f.ResultTypes(), []wazeroapi.ValueType{i32}) {
 				return 0, fmt.Errorf("wasm: guest exports the wrong signature for func[%s]. should be () -> (i32)", name)
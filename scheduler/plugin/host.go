@@ -17,14 +17,23 @@
 package wasm
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
+	"strconv"
 
 	"github.com/tetratelabs/wazero"
 	wazeroapi "github.com/tetratelabs/wazero/api"
+	"google.golang.org/protobuf/encoding/protowire"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
 )
@@ -45,7 +54,14 @@ const (
 	k8sSchedulerTargetPod                 = "targetPod"
 	k8sSchedulerFilteredNodeList          = "filteredNodeList"
 	k8sSchedulerCurrentPod                = "currentPod"
+	k8sSchedulerEventOldObject            = "event.old_object"
+	k8sSchedulerEventNewObject            = "event.new_object"
+	k8sSchedulerQueuedPodKey              = "queued_pod_key"
+	k8sSchedulerQueuedPod                 = "queued_pod"
 	k8sSchedulerGetConfig                 = "get_config"
+	k8sSchedulerCycleStateRead            = "cyclestate.read"
+	k8sSchedulerCycleStateWrite           = "cyclestate.write"
+	k8sSchedulerCycleStateDelete          = "cyclestate.delete"
 	k8sSchedulerNodeScoreList             = "nodeScoreList"
 	k8sSchedulerNodeImageStates           = "nodeImageStates"
 	k8sSchedulerResultClusterEvents       = "result.cluster_events"
@@ -56,16 +72,33 @@ const (
 	k8sSchedulerHandleEventRecorderEventf = "handle.eventrecorder.eventf"
 	k8sSchedulerHandleRejectWaitingPod    = "handle.reject_waiting_pod"
 	k8sSchedulerHandleGetWaitingPod       = "handle.get_waiting_pod"
+
+	k8sSchedulerNodeInfoPods                         = "node_info.pods"
+	k8sSchedulerNodeInfoPodsWithAffinity             = "node_info.pods_with_affinity"
+	k8sSchedulerNodeInfoPodsWithRequiredAntiAffinity = "node_info.pods_with_required_anti_affinity"
+	k8sSchedulerNodeInfoResources                    = "node_info.resources"
+	k8sSchedulerHandleNominatedPodsForNode           = "handle.nominated_pods_for_node"
+	k8sSchedulerHandleActivate                       = "handle.activate"
+
+	k8sSchedulerStorageInfosIsPVCUsedByPods = "storage_infos.is_pvc_used_by_pods"
+	k8sSchedulerStoragePrefix               = "storage."
+	k8sSchedulerStoragePVC                  = "storage.persistent_volume_claim"
+	k8sSchedulerStoragePV                   = "storage.persistent_volume"
+	k8sSchedulerStorageClass                = "storage.storage_class"
+	k8sSchedulerStorageCSINode              = "storage.csi_node"
+
+	k8sSchedulerInformerGet  = "informer.get"
+	k8sSchedulerInformerList = "informer.list"
 )
 
 func instantiateHostApi(ctx context.Context, runtime wazero.Runtime, handle framework.Handle) (wazeroapi.Module, error) {
 	host := &host{handle: handle}
 	return runtime.NewHostModuleBuilder(k8sApi).
 		NewFunctionBuilder().
-		WithGoModuleFunction(wazeroapi.GoModuleFunc(host.k8sApiNodeFn), []wazeroapi.ValueType{i32, i32, i32, i32}, []wazeroapi.ValueType{i32}).
+		WithGoModuleFunction(traceHostFunction(k8sApi, k8sApiNode, host.k8sApiNodeFn), []wazeroapi.ValueType{i32, i32, i32, i32}, []wazeroapi.ValueType{i32}).
 		WithParameterNames("nodename", "nodename_len", "buf", "buf_limit").Export(k8sApiNode).
 		NewFunctionBuilder().
-		WithGoModuleFunction(wazeroapi.GoModuleFunc(host.k8sApiNodeListFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{i32}).
+		WithGoModuleFunction(traceHostFunction(k8sApi, k8sApiNodeList, host.k8sApiNodeListFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{i32}).
 		WithParameterNames("buf", "buf_limit").Export(k8sApiNodeList).
 		Instantiate(ctx)
 }
@@ -74,68 +107,137 @@ func instantiateHostKlog(ctx context.Context, runtime wazero.Runtime, logSeverit
 	host := &host{logSeverity: logSeverity}
 	return runtime.NewHostModuleBuilder(k8sKlog).
 		NewFunctionBuilder().
-		WithGoModuleFunction(wazeroapi.GoModuleFunc(host.k8sKlogLogFn), []wazeroapi.ValueType{i32, i32, i32}, []wazeroapi.ValueType{}).
+		WithGoModuleFunction(traceHostFunction(k8sKlog, k8sKlogLog, host.k8sKlogLogFn), []wazeroapi.ValueType{i32, i32, i32}, []wazeroapi.ValueType{}).
 		WithParameterNames("severity", "msg", "msg_len").Export(k8sKlogLog).
 		NewFunctionBuilder().
-		WithGoModuleFunction(wazeroapi.GoModuleFunc(host.k8sKlogLogsFn), []wazeroapi.ValueType{i32, i32, i32, i32, i32}, []wazeroapi.ValueType{}).
+		WithGoModuleFunction(traceHostFunction(k8sKlog, k8sKlogLogs, host.k8sKlogLogsFn), []wazeroapi.ValueType{i32, i32, i32, i32, i32}, []wazeroapi.ValueType{}).
 		WithParameterNames("severity", "msg", "msg_len", "kvs", "kvs_len").Export(k8sKlogLogs).
 		NewFunctionBuilder().
-		WithGoModuleFunction(wazeroapi.GoModuleFunc(host.k8sKlogSeverityFn), []wazeroapi.ValueType{}, []wazeroapi.ValueType{i32}).
+		WithGoModuleFunction(traceHostFunction(k8sKlog, k8sKlogSeverity, host.k8sKlogSeverityFn), []wazeroapi.ValueType{}, []wazeroapi.ValueType{i32}).
 		WithResultNames("severity").Export(k8sKlogSeverity).
 		Instantiate(ctx)
 }
 
-func instantiateHostScheduler(ctx context.Context, runtime wazero.Runtime, guestConfig string, handle framework.Handle) (wazeroapi.Module, error) {
-	host := &host{guestConfig: guestConfig, handle: handle}
+func instantiateHostScheduler(ctx context.Context, runtime wazero.Runtime, guestConfig string, handle framework.Handle, storage *storageListers, informers *informerListers) (wazeroapi.Module, error) {
+	host := &host{guestConfig: guestConfig, handle: handle, storage: storage, informers: informers}
 	return runtime.NewHostModuleBuilder(k8sScheduler).
 		NewFunctionBuilder().
-		WithGoModuleFunction(wazeroapi.GoModuleFunc(host.k8sSchedulerGetConfigFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{i32}).
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerGetConfig, host.k8sSchedulerGetConfigFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{i32}).
 		WithParameterNames("buf", "buf_limit").Export(k8sSchedulerGetConfig).
 		NewFunctionBuilder().
-		WithGoModuleFunction(wazeroapi.GoModuleFunc(k8sSchedulerApiFilteredNodeListFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{i32}).
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerFilteredNodeList, k8sSchedulerApiFilteredNodeListFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{i32}).
 		WithParameterNames("buf", "buf_limit").Export(k8sSchedulerFilteredNodeList).
 		NewFunctionBuilder().
-		WithGoModuleFunction(wazeroapi.GoModuleFunc(k8sSchedulerTargetPodFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{i32}).
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerTargetPod, k8sSchedulerTargetPodFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{i32}).
 		WithParameterNames("buf", "buf_limit").Export(k8sSchedulerTargetPod).
 		NewFunctionBuilder().
-		WithGoModuleFunction(wazeroapi.GoModuleFunc(k8sSchedulerCurrentNodeNameFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{i32}).
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerCurrentNodeName, k8sSchedulerCurrentNodeNameFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{i32}).
 		WithParameterNames("buf", "buf_limit").Export(k8sSchedulerCurrentNodeName).
 		NewFunctionBuilder().
-		WithGoModuleFunction(wazeroapi.GoModuleFunc(host.k8sSchedulerNodeImageStatesFn), []wazeroapi.ValueType{i32, i32, i32, i32}, []wazeroapi.ValueType{i32}).
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerNodeImageStates, host.k8sSchedulerNodeImageStatesFn), []wazeroapi.ValueType{i32, i32, i32, i32}, []wazeroapi.ValueType{i32}).
 		WithParameterNames("nodename", "nodename_len", "buf", "buf_limit").Export(k8sSchedulerNodeImageStates).
 		NewFunctionBuilder().
-		WithGoModuleFunction(wazeroapi.GoModuleFunc(k8sSchedulerCurrentPodFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{i32}).
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerNodeInfoPods, host.nodeInfoPodsFn(k8sSchedulerNodeInfoPods, func(ni *framework.NodeInfo) []*framework.PodInfo {
+			return ni.Pods
+		})), []wazeroapi.ValueType{i32, i32, i32, i32}, []wazeroapi.ValueType{i32}).
+		WithParameterNames("nodename", "nodename_len", "buf", "buf_limit").Export(k8sSchedulerNodeInfoPods).
+		NewFunctionBuilder().
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerNodeInfoPodsWithAffinity, host.nodeInfoPodsFn(k8sSchedulerNodeInfoPodsWithAffinity, func(ni *framework.NodeInfo) []*framework.PodInfo {
+			return ni.PodsWithAffinity
+		})), []wazeroapi.ValueType{i32, i32, i32, i32}, []wazeroapi.ValueType{i32}).
+		WithParameterNames("nodename", "nodename_len", "buf", "buf_limit").Export(k8sSchedulerNodeInfoPodsWithAffinity).
+		NewFunctionBuilder().
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerNodeInfoPodsWithRequiredAntiAffinity, host.nodeInfoPodsFn(k8sSchedulerNodeInfoPodsWithRequiredAntiAffinity, func(ni *framework.NodeInfo) []*framework.PodInfo {
+			return ni.PodsWithRequiredAntiAffinity
+		})), []wazeroapi.ValueType{i32, i32, i32, i32}, []wazeroapi.ValueType{i32}).
+		WithParameterNames("nodename", "nodename_len", "buf", "buf_limit").Export(k8sSchedulerNodeInfoPodsWithRequiredAntiAffinity).
+		NewFunctionBuilder().
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerNodeInfoResources, host.k8sSchedulerNodeInfoResourcesFn), []wazeroapi.ValueType{i32, i32, i32, i32}, []wazeroapi.ValueType{i32}).
+		WithParameterNames("nodename", "nodename_len", "buf", "buf_limit").Export(k8sSchedulerNodeInfoResources).
+		NewFunctionBuilder().
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerStorageInfosIsPVCUsedByPods, host.k8sSchedulerStorageInfosIsPVCUsedByPodsFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{i32}).
+		WithParameterNames("key", "key_len").
+		WithResultNames("used").Export(k8sSchedulerStorageInfosIsPVCUsedByPods).
+		NewFunctionBuilder().
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerStoragePVC, host.k8sSchedulerStoragePVCFn), []wazeroapi.ValueType{i32, i32, i32, i32, i32, i32}, []wazeroapi.ValueType{i32}).
+		WithParameterNames("namespace", "namespace_len", "name", "name_len", "buf", "buf_limit").Export(k8sSchedulerStoragePVC).
+		NewFunctionBuilder().
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerStoragePV, host.k8sSchedulerStoragePVFn), []wazeroapi.ValueType{i32, i32, i32, i32}, []wazeroapi.ValueType{i32}).
+		WithParameterNames("name", "name_len", "buf", "buf_limit").Export(k8sSchedulerStoragePV).
+		NewFunctionBuilder().
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerStorageClass, host.k8sSchedulerStorageClassFn), []wazeroapi.ValueType{i32, i32, i32, i32}, []wazeroapi.ValueType{i32}).
+		WithParameterNames("name", "name_len", "buf", "buf_limit").Export(k8sSchedulerStorageClass).
+		NewFunctionBuilder().
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerStorageCSINode, host.k8sSchedulerStorageCSINodeFn), []wazeroapi.ValueType{i32, i32, i32, i32}, []wazeroapi.ValueType{i32}).
+		WithParameterNames("name", "name_len", "buf", "buf_limit").Export(k8sSchedulerStorageCSINode).
+		NewFunctionBuilder().
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerInformerGet, host.k8sSchedulerInformerGetFn), []wazeroapi.ValueType{i32, i32, i32, i32, i32, i32, i32, i32, i32}, []wazeroapi.ValueType{i32}).
+		WithParameterNames("resource", "resource_len", "namespace", "namespace_len", "name", "name_len", "encoding", "buf", "buf_limit").Export(k8sSchedulerInformerGet).
+		NewFunctionBuilder().
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerInformerList, host.k8sSchedulerInformerListFn), []wazeroapi.ValueType{i32, i32, i32, i32, i32, i32, i32, i32, i32}, []wazeroapi.ValueType{i64}).
+		WithParameterNames("resource", "resource_len", "namespace", "namespace_len", "selector", "selector_len", "encoding", "buf", "buf_limit").
+		WithResultNames("err_len").Export(k8sSchedulerInformerList).
+		NewFunctionBuilder().
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerCurrentPod, k8sSchedulerCurrentPodFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{i32}).
 		WithParameterNames("buf", "buf_limit").Export(k8sSchedulerCurrentPod).
 		NewFunctionBuilder().
-		WithGoModuleFunction(wazeroapi.GoModuleFunc(k8sSchedulerResultClusterEventsFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{}).
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerEventOldObject, k8sSchedulerEventOldObjectFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{i32}).
+		WithParameterNames("buf", "buf_limit").Export(k8sSchedulerEventOldObject).
+		NewFunctionBuilder().
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerEventNewObject, k8sSchedulerEventNewObjectFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{i32}).
+		WithParameterNames("buf", "buf_limit").Export(k8sSchedulerEventNewObject).
+		NewFunctionBuilder().
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerQueuedPodKey, k8sSchedulerQueuedPodKeyFn), []wazeroapi.ValueType{i32, i32, i32}, []wazeroapi.ValueType{i32}).
+		WithParameterNames("index", "buf", "buf_limit").Export(k8sSchedulerQueuedPodKey).
+		NewFunctionBuilder().
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerQueuedPod, k8sSchedulerQueuedPodFn), []wazeroapi.ValueType{i32, i32, i32}, []wazeroapi.ValueType{i32}).
+		WithParameterNames("index", "buf", "buf_limit").Export(k8sSchedulerQueuedPod).
+		NewFunctionBuilder().
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerCycleStateRead, k8sSchedulerCycleStateReadFn), []wazeroapi.ValueType{i32, i32, i32, i32}, []wazeroapi.ValueType{i64}).
+		WithParameterNames("key", "key_len", "buf", "buf_limit").
+		WithResultNames("found_len").Export(k8sSchedulerCycleStateRead).
+		NewFunctionBuilder().
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerCycleStateWrite, k8sSchedulerCycleStateWriteFn), []wazeroapi.ValueType{i32, i32, i32, i32}, []wazeroapi.ValueType{}).
+		WithParameterNames("key", "key_len", "buf", "buf_len").Export(k8sSchedulerCycleStateWrite).
+		NewFunctionBuilder().
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerCycleStateDelete, k8sSchedulerCycleStateDeleteFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{}).
+		WithParameterNames("key", "key_len").Export(k8sSchedulerCycleStateDelete).
+		NewFunctionBuilder().
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerResultClusterEvents, k8sSchedulerResultClusterEventsFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{}).
 		WithParameterNames("buf", "buf_len").Export(k8sSchedulerResultClusterEvents).
 		NewFunctionBuilder().
-		WithGoModuleFunction(wazeroapi.GoModuleFunc(k8sSchedulerResultNodeNamesFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{}).
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerResultNodeNames, k8sSchedulerResultNodeNamesFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{}).
 		WithParameterNames("buf", "buf_len").Export(k8sSchedulerResultNodeNames).
 		NewFunctionBuilder().
-		WithGoModuleFunction(wazeroapi.GoModuleFunc(k8sSchedulerResultNominatedNodeNameFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{}).
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerResultNominatedNodeName, k8sSchedulerResultNominatedNodeNameFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{}).
 		WithParameterNames("buf", "buf_len").Export(k8sSchedulerResultNominatedNodeName).
 		NewFunctionBuilder().
-		WithGoModuleFunction(wazeroapi.GoModuleFunc(k8sSchedulerResultStatusReasonFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{}).
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerResultStatusReason, k8sSchedulerResultStatusReasonFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{}).
 		WithParameterNames("buf", "buf_len").Export(k8sSchedulerResultStatusReason).
 		NewFunctionBuilder().
-		WithGoModuleFunction(wazeroapi.GoModuleFunc(k8sSchedulerNodeToStatusMapFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{i32}).
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sApiNodeToStatusMap, k8sSchedulerNodeToStatusMapFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{i32}).
 		WithParameterNames("buf", "buf_limit").Export(k8sApiNodeToStatusMap).
 		NewFunctionBuilder().
-		WithGoModuleFunction(wazeroapi.GoModuleFunc(k8sSchedulerResultNormalizedScoreListFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{}).
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerResultNormalizedScoreList, k8sSchedulerResultNormalizedScoreListFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{}).
 		WithParameterNames("buf", "buf_len").Export(k8sSchedulerResultNormalizedScoreList).
 		NewFunctionBuilder().
-		WithGoModuleFunction(wazeroapi.GoModuleFunc(k8sSchedulerNodeScoreListFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{i32}).
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerNodeScoreList, k8sSchedulerNodeScoreListFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{i32}).
 		WithParameterNames("buf", "buf_len").Export(k8sSchedulerNodeScoreList).
 		NewFunctionBuilder().
-		WithGoModuleFunction(wazeroapi.GoModuleFunc(host.k8sHandleEventRecorderEventfFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{}).
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerHandleEventRecorderEventf, host.k8sHandleEventRecorderEventfFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{}).
 		WithParameterNames("buf", "buf_len").Export(k8sSchedulerHandleEventRecorderEventf).
 		NewFunctionBuilder().
-		WithGoModuleFunction(wazeroapi.GoModuleFunc(host.k8sHandleRejectWaitingPodFn), []wazeroapi.ValueType{i32, i32, i32, i32}, []wazeroapi.ValueType{}).
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerHandleRejectWaitingPod, host.k8sHandleRejectWaitingPodFn), []wazeroapi.ValueType{i32, i32, i32, i32}, []wazeroapi.ValueType{}).
 		WithParameterNames("buf", "buf_len").Export(k8sSchedulerHandleRejectWaitingPod).
 		NewFunctionBuilder().
-		WithGoModuleFunction(wazeroapi.GoModuleFunc(host.k8sHandleGetWaitingPodFn), []wazeroapi.ValueType{i32, i32, i32, i32}, []wazeroapi.ValueType{}).
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerHandleGetWaitingPod, host.k8sHandleGetWaitingPodFn), []wazeroapi.ValueType{i32, i32, i32, i32}, []wazeroapi.ValueType{}).
 		WithParameterNames("buf", "buf_len").Export(k8sSchedulerHandleGetWaitingPod).
+		NewFunctionBuilder().
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerHandleNominatedPodsForNode, host.k8sHandleNominatedPodsForNodeFn), []wazeroapi.ValueType{i32, i32, i32, i32}, []wazeroapi.ValueType{i32}).
+		WithParameterNames("nodename", "nodename_len", "buf", "buf_limit").Export(k8sSchedulerHandleNominatedPodsForNode).
+		NewFunctionBuilder().
+		WithGoModuleFunction(traceHostFunction(k8sScheduler, k8sSchedulerHandleActivate, host.k8sHandleActivateFn), []wazeroapi.ValueType{i32, i32}, []wazeroapi.ValueType{}).
+		WithParameterNames("buf", "buf_len").Export(k8sSchedulerHandleActivate).
 		Instantiate(ctx)
 }
 
@@ -163,6 +265,23 @@ type stack struct {
 	// currentPod is used by guest.filterFn and guest.scoreFn
 	currentPod *v1.Pod
 
+	// currentNodeInfo is the NodeInfo of currentNodeName, when passed to the
+	// guest function. This can differ from the snapshot, such as when pods
+	// are removed from a copy of it during preemption.
+	currentNodeInfo *framework.NodeInfo
+
+	// cycleState is the framework.CycleState of the scheduling cycle, or nil
+	// when the guest function isn't part of one.
+	cycleState *framework.CycleState
+
+	// eventOldObject and eventNewObject are used by guest.queueinghintFn.
+	// They are nil when the event has no such object, or it isn't a type the
+	// guest can decode.
+	eventOldObject, eventNewObject valueType
+
+	// queuedPods are the pods compared by guest.lessFn
+	queuedPods [2]*v1.Pod
+
 	// nodeToStatusMap is used by guest.postfilterFn
 	nodeToStatusMap framework.NodeToStatusMap
 
@@ -217,7 +336,7 @@ func (h host) k8sApiNodeFn(ctx context.Context, mod wazeroapi.Module, stack []ui
 		node = nodeinfo.Node()
 	}
 
-	stack[0] = uint64(marshalIfUnderLimit(mod.Memory(), node, buf, bufLimit))
+	stack[0] = uint64(marshalIfUnderLimit(mod.Memory(), k8sApiNode, node, buf, bufLimit))
 }
 
 func (h host) k8sApiNodeListFn(ctx context.Context, mod wazeroapi.Module, stack []uint64) {
@@ -234,7 +353,7 @@ func (h host) k8sApiNodeListFn(ctx context.Context, mod wazeroapi.Module, stack
 		nodes = append(nodes, *ni.Node())
 	}
 
-	stack[0] = uint64(marshalIfUnderLimit(mod.Memory(), &v1.NodeList{Items: nodes}, buf, bufLimit))
+	stack[0] = uint64(marshalIfUnderLimit(mod.Memory(), k8sApiNodeList, &v1.NodeList{Items: nodes}, buf, bufLimit))
 }
 
 func k8sSchedulerApiFilteredNodeListFn(ctx context.Context, mod wazeroapi.Module, stack []uint64) {
@@ -252,7 +371,7 @@ func k8sSchedulerApiFilteredNodeListFn(ctx context.Context, mod wazeroapi.Module
 	if err != nil {
 		panic(err)
 	}
-	stack[0] = uint64(writeStringIfUnderLimit(mod.Memory(), string(b), buf, bufLimit))
+	stack[0] = uint64(writeStringIfUnderLimit(mod.Memory(), k8sSchedulerFilteredNodeList, string(b), buf, bufLimit))
 }
 
 // k8sSchedulerCurrentNodeNameFn returns the node name that is being evaluated.
@@ -263,7 +382,7 @@ func k8sSchedulerCurrentNodeNameFn(ctx context.Context, mod wazeroapi.Module, st
 
 	nodeName := paramsFromContext(ctx).currentNodeName
 
-	stack[0] = uint64(writeStringIfUnderLimit(mod.Memory(), nodeName, buf, bufLimit))
+	stack[0] = uint64(writeStringIfUnderLimit(mod.Memory(), k8sSchedulerCurrentNodeName, nodeName, buf, bufLimit))
 }
 
 func k8sSchedulerCurrentPodFn(ctx context.Context, mod wazeroapi.Module, stack []uint64) {
@@ -271,7 +390,138 @@ func k8sSchedulerCurrentPodFn(ctx context.Context, mod wazeroapi.Module, stack [
 	bufLimit := bufLimit(stack[1])
 
 	podInfo := paramsFromContext(ctx).currentPod
-	stack[0] = uint64(marshalIfUnderLimit(mod.Memory(), podInfo, buf, bufLimit))
+	stack[0] = uint64(marshalIfUnderLimit(mod.Memory(), k8sSchedulerCurrentPod, podInfo, buf, bufLimit))
+}
+
+// k8sSchedulerEventOldObjectFn returns the object before the event, such as
+// the node before an update.
+func k8sSchedulerEventOldObjectFn(ctx context.Context, mod wazeroapi.Module, stack []uint64) {
+	buf := uint32(stack[0])
+	bufLimit := bufLimit(stack[1])
+
+	obj := paramsFromContext(ctx).eventOldObject
+	stack[0] = uint64(marshalEventObjectIfUnderLimit(mod.Memory(), k8sSchedulerEventOldObject, obj, buf, bufLimit))
+}
+
+// k8sSchedulerEventNewObjectFn returns the object after the event, such as
+// the pod added.
+func k8sSchedulerEventNewObjectFn(ctx context.Context, mod wazeroapi.Module, stack []uint64) {
+	buf := uint32(stack[0])
+	bufLimit := bufLimit(stack[1])
+
+	obj := paramsFromContext(ctx).eventNewObject
+	stack[0] = uint64(marshalEventObjectIfUnderLimit(mod.Memory(), k8sSchedulerEventNewObject, obj, buf, bufLimit))
+}
+
+// marshalEventObjectIfUnderLimit is like marshalIfUnderLimit, except a nil
+// object, such as the old object of an add event, has zero size.
+func marshalEventObjectIfUnderLimit(mem wazeroapi.Memory, hostFn string, obj valueType, buf uint32, bufLimit bufLimit) int {
+	if obj == nil {
+		return 0
+	}
+	return marshalIfUnderLimit(mem, hostFn, obj, buf, bufLimit)
+}
+
+// k8sSchedulerQueuedPodKeyFn returns a key which changes when the queued pod
+// at the index does: its UID and resource version. This allows the guest to
+// cache decoded pods, as Less is called many times for the same pods.
+func k8sSchedulerQueuedPodKeyFn(ctx context.Context, mod wazeroapi.Module, stack []uint64) {
+	index := uint32(stack[0])
+	buf := uint32(stack[1])
+	bufLimit := bufLimit(stack[2])
+
+	pod := queuedPod(ctx, index)
+	key := string(pod.UID) + "/" + pod.ResourceVersion
+	stack[0] = uint64(writeStringIfUnderLimit(mod.Memory(), k8sSchedulerQueuedPodKey, key, buf, bufLimit))
+}
+
+// k8sSchedulerQueuedPodFn returns the queued pod at the index.
+func k8sSchedulerQueuedPodFn(ctx context.Context, mod wazeroapi.Module, stack []uint64) {
+	index := uint32(stack[0])
+	buf := uint32(stack[1])
+	bufLimit := bufLimit(stack[2])
+
+	pod := queuedPod(ctx, index)
+	stack[0] = uint64(marshalIfUnderLimit(mod.Memory(), k8sSchedulerQueuedPod, pod, buf, bufLimit))
+}
+
+// queuedPod returns the pod compared by guest.lessFn at the index, which is
+// zero or one.
+func queuedPod(ctx context.Context, index uint32) *v1.Pod {
+	pods := paramsFromContext(ctx).queuedPods
+	if index >= uint32(len(pods)) {
+		panic(fmt.Errorf("invalid queued pod index %d", index))
+	}
+	return pods[index]
+}
+
+// k8sSchedulerCycleStateReadFn is a function used by the guest to read a value
+// from framework.CycleState, encoded as documented on StateData. The result
+// is the length of the value, with the high 32 bits set to one when found.
+func k8sSchedulerCycleStateReadFn(ctx context.Context, mod wazeroapi.Module, stack []uint64) {
+	key := uint32(stack[0])
+	keyLen := uint32(stack[1])
+	buf := uint32(stack[2])
+	bufLimit := bufLimit(stack[3])
+
+	state := paramsFromContext(ctx).cycleState
+	if state == nil {
+		stack[0] = 0 // not found
+		return
+	}
+	data, err := state.Read(readCycleStateKey(mod, key, keyLen))
+	if err != nil {
+		stack[0] = 0 // not found
+		return
+	}
+	v, err := marshalStateData(data)
+	if err != nil {
+		panic(err)
+	}
+	vLen := writeStringIfUnderLimit(mod.Memory(), k8sSchedulerCycleStateRead, string(v), buf, bufLimit)
+	stack[0] = 1<<32 | uint64(vLen)
+}
+
+// k8sSchedulerCycleStateWriteFn is a function used by the guest to write a
+// StateData to framework.CycleState.
+func k8sSchedulerCycleStateWriteFn(ctx context.Context, mod wazeroapi.Module, stack []uint64) {
+	key := uint32(stack[0])
+	keyLen := uint32(stack[1])
+	buf := uint32(stack[2])
+	bufLen := uint32(stack[3])
+
+	state := paramsFromContext(ctx).cycleState
+	if state == nil {
+		panic("cycle state is not available outside the scheduling cycle")
+	}
+	k := readCycleStateKey(mod, key, keyLen)
+	b, ok := mod.Memory().Read(buf, bufLen)
+	if !ok {
+		panic("out of memory reading cycle state value")
+	}
+	// Copy the value, as the guest can change its memory after this returns.
+	state.Write(k, StateData(bytes.Clone(b)))
+}
+
+// k8sSchedulerCycleStateDeleteFn is a function used by the guest to delete a
+// value from framework.CycleState.
+func k8sSchedulerCycleStateDeleteFn(ctx context.Context, mod wazeroapi.Module, stack []uint64) {
+	key := uint32(stack[0])
+	keyLen := uint32(stack[1])
+
+	state := paramsFromContext(ctx).cycleState
+	if state == nil {
+		panic("cycle state is not available outside the scheduling cycle")
+	}
+	state.Delete(readCycleStateKey(mod, key, keyLen))
+}
+
+func readCycleStateKey(mod wazeroapi.Module, key, keyLen uint32) framework.StateKey {
+	b, ok := mod.Memory().Read(key, keyLen)
+	if !ok {
+		panic("out of memory reading cycle state key")
+	}
+	return framework.StateKey(b)
 }
 
 // k8sSchedulerTargetPodFn is a function used by the host to send the podInfo.
@@ -280,7 +530,7 @@ func k8sSchedulerTargetPodFn(ctx context.Context, mod wazeroapi.Module, stack []
 	bufLimit := bufLimit(stack[1])
 
 	podInfo := paramsFromContext(ctx).targetPod
-	stack[0] = uint64(marshalIfUnderLimit(mod.Memory(), podInfo, buf, bufLimit))
+	stack[0] = uint64(marshalIfUnderLimit(mod.Memory(), k8sSchedulerTargetPod, podInfo, buf, bufLimit))
 }
 
 // k8sSchedulerNodeToStatusMapFn is a function used by the host to send the nodeStatusMap.
@@ -294,13 +544,15 @@ func k8sSchedulerNodeToStatusMapFn(ctx context.Context, mod wazeroapi.Module, st
 	if err != nil {
 		panic(err)
 	}
-	stack[0] = uint64(writeStringIfUnderLimit(mod.Memory(), string(mapByte), buf, bufLimit))
+	stack[0] = uint64(writeStringIfUnderLimit(mod.Memory(), k8sApiNodeToStatusMap, string(mapByte), buf, bufLimit))
 }
 
 type host struct {
 	guestConfig string
 	logSeverity int32
 	handle      framework.Handle
+	storage     *storageListers
+	informers   *informerListers
 }
 
 func (h host) k8sSchedulerGetConfigFn(_ context.Context, mod wazeroapi.Module, stack []uint64) {
@@ -309,7 +561,7 @@ func (h host) k8sSchedulerGetConfigFn(_ context.Context, mod wazeroapi.Module, s
 
 	config := h.guestConfig
 
-	stack[0] = uint64(writeStringIfUnderLimit(mod.Memory(), config, buf, bufLimit))
+	stack[0] = uint64(writeStringIfUnderLimit(mod.Memory(), k8sSchedulerGetConfig, config, buf, bufLimit))
 }
 
 func (h host) k8sSchedulerNodeImageStatesFn(_ context.Context, mod wazeroapi.Module, stack []uint64) {
@@ -335,7 +587,335 @@ func (h host) k8sSchedulerNodeImageStatesFn(_ context.Context, mod wazeroapi.Mod
 	if err != nil {
 		panic(err)
 	}
-	stack[0] = uint64(writeStringIfUnderLimit(mod.Memory(), string(b), buf, bufLimit))
+	stack[0] = uint64(writeStringIfUnderLimit(mod.Memory(), k8sSchedulerNodeImageStates, string(b), buf, bufLimit))
+}
+
+// nodeInfoPodsFn returns a function used by the guest to read a list of pods
+// on a node, as a v1.PodList.
+func (h host) nodeInfoPodsFn(hostFn string, pods func(*framework.NodeInfo) []*framework.PodInfo) wazeroapi.GoModuleFunc {
+	return func(ctx context.Context, mod wazeroapi.Module, stack []uint64) {
+		nodename := uint32(stack[0])
+		nodenameLen := uint32(stack[1])
+		buf := uint32(stack[2])
+		bufLimit := bufLimit(stack[3])
+
+		var nodeName string
+		if b, ok := mod.Memory().Read(nodename, nodenameLen); !ok {
+			panic("out of memory reading nodeName")
+		} else {
+			nodeName = string(b)
+		}
+
+		podList := &v1.PodList{}
+		if ni := h.nodeInfo(ctx, nodeName); ni != nil {
+			podInfos := pods(ni)
+			podList.Items = make([]v1.Pod, 0, len(podInfos))
+			for _, pi := range podInfos {
+				podList.Items = append(podList.Items, *pi.Pod)
+			}
+		}
+
+		stack[0] = uint64(marshalIfUnderLimit(mod.Memory(), hostFn, podList, buf, bufLimit))
+	}
+}
+
+// Field numbers of the resource accounting of a framework.NodeInfo, sent to
+// the guest as the below protobuf message. There's no generated code for it,
+// but its fields are messages the guest has generated code for.
+//
+//	message NodeInfoResources {
+//	  map<string, k8s.io.apimachinery.pkg.api.resource.Quantity> requested = 1;
+//	  map<string, k8s.io.apimachinery.pkg.api.resource.Quantity> nonZeroRequested = 2;
+//	  map<string, k8s.io.apimachinery.pkg.api.resource.Quantity> allocatable = 3;
+//	  repeated k8s.io.api.core.v1.ContainerPort usedPorts = 4;
+//	}
+//
+// Quantities are integers, with the suffix "m" for cpu, and zero values are
+// omitted. Only hostIP, protocol and hostPort of ContainerPort are set.
+const (
+	nodeInfoResourcesRequested        protowire.Number = 1
+	nodeInfoResourcesNonZeroRequested protowire.Number = 2
+	nodeInfoResourcesAllocatable      protowire.Number = 3
+	nodeInfoResourcesUsedPorts        protowire.Number = 4
+)
+
+// marshalNodeInfoResources encodes the resource accounting of the node as
+// documented on nodeInfoResourcesRequested.
+func marshalNodeInfoResources(ni *framework.NodeInfo) []byte {
+	var b []byte
+	b = appendResource(b, nodeInfoResourcesRequested, ni.Requested)
+	b = appendResource(b, nodeInfoResourcesNonZeroRequested, ni.NonZeroRequested)
+	b = appendResource(b, nodeInfoResourcesAllocatable, ni.Allocatable)
+	for ip, pps := range ni.UsedPorts {
+		for pp := range pps {
+			port := v1.ContainerPort{HostIP: ip, Protocol: v1.Protocol(pp.Protocol), HostPort: pp.Port}
+			p, err := port.Marshal()
+			if err != nil {
+				panic(err)
+			}
+			b = protowire.AppendTag(b, nodeInfoResourcesUsedPorts, protowire.BytesType)
+			b = protowire.AppendBytes(b, p)
+		}
+	}
+	return b
+}
+
+// appendResource appends the resource as map entries of the field.
+func appendResource(b []byte, num protowire.Number, r *framework.Resource) []byte {
+	if r == nil {
+		return b
+	}
+	add := func(name string, value string) {
+		// The value is a resource.Quantity, whose only field is its string.
+		var quantity []byte
+		quantity = protowire.AppendTag(quantity, 1, protowire.BytesType)
+		quantity = protowire.AppendString(quantity, value)
+
+		var entry []byte
+		entry = protowire.AppendTag(entry, 1, protowire.BytesType)
+		entry = protowire.AppendString(entry, name)
+		entry = protowire.AppendTag(entry, 2, protowire.BytesType)
+		entry = protowire.AppendBytes(entry, quantity)
+
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	if r.MilliCPU != 0 {
+		add(string(v1.ResourceCPU), strconv.FormatInt(r.MilliCPU, 10)+"m")
+	}
+	if r.Memory != 0 {
+		add(string(v1.ResourceMemory), strconv.FormatInt(r.Memory, 10))
+	}
+	if r.EphemeralStorage != 0 {
+		add(string(v1.ResourceEphemeralStorage), strconv.FormatInt(r.EphemeralStorage, 10))
+	}
+	if r.AllowedPodNumber != 0 {
+		add(string(v1.ResourcePods), strconv.Itoa(r.AllowedPodNumber))
+	}
+	for _, name := range slices.Sorted(maps.Keys(r.ScalarResources)) {
+		if v := r.ScalarResources[name]; v != 0 {
+			add(string(name), strconv.FormatInt(v, 10))
+		}
+	}
+	return b
+}
+
+// k8sSchedulerNodeInfoResourcesFn is a function used by the guest to read the
+// requested and allocatable resources, and used ports of a node. Nothing is
+// written when the node isn't found.
+func (h host) k8sSchedulerNodeInfoResourcesFn(ctx context.Context, mod wazeroapi.Module, stack []uint64) {
+	nodename := uint32(stack[0])
+	nodenameLen := uint32(stack[1])
+	buf := uint32(stack[2])
+	bufLimit := bufLimit(stack[3])
+
+	var nodeName string
+	if b, ok := mod.Memory().Read(nodename, nodenameLen); !ok {
+		panic("out of memory reading nodeName")
+	} else {
+		nodeName = string(b)
+	}
+
+	ni := h.nodeInfo(ctx, nodeName)
+	if ni == nil {
+		stack[0] = 0
+		return
+	}
+
+	b := marshalNodeInfoResources(ni)
+	stack[0] = uint64(writeStringIfUnderLimit(mod.Memory(), k8sSchedulerNodeInfoResources, string(b), buf, bufLimit))
+}
+
+// nodeInfo returns the NodeInfo passed to the guest function if it has the
+// given name, or the one in the snapshot. This returns nil if not found.
+func (h host) nodeInfo(ctx context.Context, nodeName string) *framework.NodeInfo {
+	if ni := paramsFromContext(ctx).currentNodeInfo; ni != nil && ni.Node() != nil && ni.Node().Name == nodeName {
+		return ni
+	}
+	if h.handle == nil {
+		return nil
+	}
+	ni, err := h.handle.SnapshotSharedLister().NodeInfos().Get(nodeName)
+	if err != nil {
+		return nil
+	}
+	return ni
+}
+
+// storageListers look up storage objects from the scheduler's informers.
+type storageListers struct {
+	pvcs           corelisters.PersistentVolumeClaimLister
+	pvs            corelisters.PersistentVolumeLister
+	storageClasses storagelisters.StorageClassLister
+	csiNodes       storagelisters.CSINodeLister
+}
+
+// newStorageListers registers the informers needed by storageListers. This
+// must be called before the informer factory is started.
+func newStorageListers(factory informers.SharedInformerFactory) *storageListers {
+	return &storageListers{
+		pvcs:           factory.Core().V1().PersistentVolumeClaims().Lister(),
+		pvs:            factory.Core().V1().PersistentVolumes().Lister(),
+		storageClasses: factory.Storage().V1().StorageClasses().Lister(),
+		csiNodes:       factory.Storage().V1().CSINodes().Lister(),
+	}
+}
+
+// k8sSchedulerStorageInfosIsPVCUsedByPodsFn is a function used by the guest to
+// call StorageInfoLister.IsPVCUsedByPods with a "namespace/name" key.
+func (h host) k8sSchedulerStorageInfosIsPVCUsedByPodsFn(_ context.Context, mod wazeroapi.Module, stack []uint64) {
+	key := uint32(stack[0])
+	keyLen := uint32(stack[1])
+
+	b, ok := mod.Memory().Read(key, keyLen)
+	if !ok {
+		panic("out of memory reading pvc key")
+	}
+
+	var used uint64
+	if h.handle != nil && h.handle.SnapshotSharedLister().StorageInfos().IsPVCUsedByPods(string(b)) {
+		used = 1
+	}
+	stack[0] = used
+}
+
+// k8sSchedulerStoragePVCFn is a function used by the guest to look up a
+// v1.PersistentVolumeClaim. Nothing is written when it isn't found.
+func (h host) k8sSchedulerStoragePVCFn(_ context.Context, mod wazeroapi.Module, stack []uint64) {
+	namespace := readString(mod, uint32(stack[0]), uint32(stack[1]), "namespace")
+	name := readString(mod, uint32(stack[2]), uint32(stack[3]), "name")
+	buf := uint32(stack[4])
+	bufLimit := bufLimit(stack[5])
+
+	if h.storage == nil {
+		stack[0] = 0
+		return
+	}
+	pvc, err := h.storage.pvcs.PersistentVolumeClaims(namespace).Get(name)
+	if err != nil {
+		stack[0] = 0
+		return
+	}
+	stack[0] = uint64(marshalIfUnderLimit(mod.Memory(), k8sSchedulerStoragePVC, pvc, buf, bufLimit))
+}
+
+// k8sSchedulerStoragePVFn is a function used by the guest to look up a
+// v1.PersistentVolume. Nothing is written when it isn't found.
+func (h host) k8sSchedulerStoragePVFn(_ context.Context, mod wazeroapi.Module, stack []uint64) {
+	name := readString(mod, uint32(stack[0]), uint32(stack[1]), "name")
+	buf := uint32(stack[2])
+	bufLimit := bufLimit(stack[3])
+
+	if h.storage == nil {
+		stack[0] = 0
+		return
+	}
+	pv, err := h.storage.pvs.Get(name)
+	if err != nil {
+		stack[0] = 0
+		return
+	}
+	stack[0] = uint64(marshalIfUnderLimit(mod.Memory(), k8sSchedulerStoragePV, pv, buf, bufLimit))
+}
+
+// k8sSchedulerStorageClassFn is a function used by the guest to look up a
+// storagev1.StorageClass. Nothing is written when it isn't found.
+//
+// Note: This is JSON, as the guest has no generated code for storage.k8s.io
+// types. See the package documentation of guest/handle/storagelister/api.
+func (h host) k8sSchedulerStorageClassFn(_ context.Context, mod wazeroapi.Module, stack []uint64) {
+	name := readString(mod, uint32(stack[0]), uint32(stack[1]), "name")
+	buf := uint32(stack[2])
+	bufLimit := bufLimit(stack[3])
+
+	if h.storage == nil {
+		stack[0] = 0
+		return
+	}
+	sc, err := h.storage.storageClasses.Get(name)
+	if err != nil {
+		stack[0] = 0
+		return
+	}
+	b, err := json.Marshal(sc)
+	if err != nil {
+		panic(err)
+	}
+	stack[0] = uint64(writeStringIfUnderLimit(mod.Memory(), k8sSchedulerStorageClass, string(b), buf, bufLimit))
+}
+
+// k8sSchedulerStorageCSINodeFn is a function used by the guest to look up a
+// storagev1.CSINode. Nothing is written when it isn't found.
+//
+// Note: This is JSON, as the guest has no generated code for storage.k8s.io
+// types. See the package documentation of guest/handle/storagelister/api.
+func (h host) k8sSchedulerStorageCSINodeFn(_ context.Context, mod wazeroapi.Module, stack []uint64) {
+	name := readString(mod, uint32(stack[0]), uint32(stack[1]), "name")
+	buf := uint32(stack[2])
+	bufLimit := bufLimit(stack[3])
+
+	if h.storage == nil {
+		stack[0] = 0
+		return
+	}
+	csiNode, err := h.storage.csiNodes.Get(name)
+	if err != nil {
+		stack[0] = 0
+		return
+	}
+	b, err := json.Marshal(csiNode)
+	if err != nil {
+		panic(err)
+	}
+	stack[0] = uint64(writeStringIfUnderLimit(mod.Memory(), k8sSchedulerStorageCSINode, string(b), buf, bufLimit))
+}
+
+// k8sSchedulerInformerGetFn is a function used by the guest to get an object
+// of a resource in PluginConfig.Informers. Nothing is written when it isn't
+// found.
+func (h host) k8sSchedulerInformerGetFn(_ context.Context, mod wazeroapi.Module, stack []uint64) {
+	resource := readString(mod, uint32(stack[0]), uint32(stack[1]), "resource")
+	namespace := readString(mod, uint32(stack[2]), uint32(stack[3]), "namespace")
+	name := readString(mod, uint32(stack[4]), uint32(stack[5]), "name")
+	encoding := uint32(stack[6])
+	buf := uint32(stack[7])
+	bufLimit := bufLimit(stack[8])
+
+	b := h.informers.get(resource, namespace, name, encoding)
+	stack[0] = uint64(writeStringIfUnderLimit(mod.Memory(), k8sSchedulerInformerGet, string(b), buf, bufLimit))
+}
+
+// k8sSchedulerInformerListFn is a function used by the guest to list objects
+// of a resource in PluginConfig.Informers, by namespace and label selector.
+// An empty namespace lists all namespaces.
+//
+// The upper 32 bits of the result are one when the selector is invalid, in
+// which case the error message is written instead of the objects.
+func (h host) k8sSchedulerInformerListFn(_ context.Context, mod wazeroapi.Module, stack []uint64) {
+	resource := readString(mod, uint32(stack[0]), uint32(stack[1]), "resource")
+	namespace := readString(mod, uint32(stack[2]), uint32(stack[3]), "namespace")
+	selector := readString(mod, uint32(stack[4]), uint32(stack[5]), "selector")
+	encoding := uint32(stack[6])
+	buf := uint32(stack[7])
+	bufLimit := bufLimit(stack[8])
+
+	b, err := h.informers.list(resource, namespace, selector, encoding)
+	if err != nil {
+		errLen := writeStringIfUnderLimit(mod.Memory(), k8sSchedulerInformerList, err.Error(), buf, bufLimit)
+		stack[0] = 1<<32 | uint64(errLen)
+		return
+	}
+	stack[0] = uint64(writeStringIfUnderLimit(mod.Memory(), k8sSchedulerInformerList, string(b), buf, bufLimit))
+}
+
+// readString reads a string parameter from the guest, panicking if it is out
+// of memory.
+func readString(mod wazeroapi.Module, ptr, size uint32, param string) string {
+	b, ok := mod.Memory().Read(ptr, size)
+	if !ok {
+		panic("out of memory reading " + param)
+	}
+	return string(b)
 }
 
 const (
@@ -513,7 +1093,7 @@ func k8sSchedulerNodeScoreListFn(ctx context.Context, mod wazeroapi.Module, stac
 	if err != nil {
 		panic(err)
 	}
-	stack[0] = uint64(writeStringIfUnderLimit(mod.Memory(), string(mapByte), buf, bufLimit))
+	stack[0] = uint64(writeStringIfUnderLimit(mod.Memory(), k8sSchedulerNodeScoreList, string(mapByte), buf, bufLimit))
 }
 
 // k8sSchedulerResultNormalizedScoreListFn is a function used by the wasm guest to set the
@@ -642,10 +1222,73 @@ func (h host) k8sHandleGetWaitingPodFn(ctx context.Context, mod wazeroapi.Module
 	}
 
 	// Marshal the found pod and ensure no out-of-bounds memory writes
-	vLen := marshalIfUnderLimit(mod.Memory(), waitingPod.GetPod(), oBuf, oBufLimit)
+	vLen := marshalIfUnderLimit(mod.Memory(), k8sSchedulerHandleGetWaitingPod, waitingPod.GetPod(), oBuf, oBufLimit)
 	if vLen == 0 {
 		panic("out of memory writing getWaitingPod result")
 	}
 
 	stack[0] = uint64(1)
 }
+
+// k8sHandleNominatedPodsForNodeFn is a function used by the wasm guest to call
+// NominatedPodsForNode. The pods are written as a v1.PodList.
+func (h host) k8sHandleNominatedPodsForNodeFn(_ context.Context, mod wazeroapi.Module, stack []uint64) {
+	nodename := uint32(stack[0])
+	nodenameLen := uint32(stack[1])
+	buf := uint32(stack[2])
+	bufLimit := bufLimit(stack[3])
+
+	var nodeName string
+	if b, ok := mod.Memory().Read(nodename, nodenameLen); !ok {
+		panic("out of memory reading nodeName")
+	} else {
+		nodeName = string(b)
+	}
+
+	podList := &v1.PodList{}
+	if h.handle != nil {
+		podInfos := h.handle.NominatedPodsForNode(nodeName)
+		podList.Items = make([]v1.Pod, 0, len(podInfos))
+		for _, pi := range podInfos {
+			podList.Items = append(podList.Items, *pi.Pod)
+		}
+	}
+
+	stack[0] = uint64(marshalIfUnderLimit(mod.Memory(), k8sSchedulerHandleNominatedPodsForNode, podList, buf, bufLimit))
+}
+
+// k8sHandleActivateFn is a function used by the wasm guest to call Activate
+// with NUL-terminated pod keys, formatted like "namespace/name". Pods not in
+// the scheduler's informer are skipped, as they can't be in a queue, as are
+// invalid keys.
+func (h host) k8sHandleActivateFn(ctx context.Context, mod wazeroapi.Module, stack []uint64) {
+	if h.handle == nil {
+		return // no queue to activate pods in
+	}
+	buf := uint32(stack[0])
+	bufLen := uint32(stack[1])
+
+	var podKeys []string
+	if b, ok := mod.Memory().Read(buf, bufLen); !ok {
+		panic("out of memory reading activate pod keys")
+	} else {
+		podKeys = fromNULTerminated(b)
+	}
+
+	logger := klog.FromContext(ctx)
+	lister := h.handle.SharedInformerFactory().Core().V1().Pods().Lister()
+	pods := make(map[string]*v1.Pod, len(podKeys))
+	for _, key := range podKeys {
+		namespace, name, err := cache.SplitMetaNamespaceKey(key)
+		if err != nil {
+			logger.Error(err, "Skipping invalid pod key to activate", "key", key)
+			continue
+		}
+		if pod, err := lister.Pods(namespace).Get(name); err == nil {
+			pods[key] = pod
+		}
+	}
+	if len(pods) > 0 {
+		h.handle.Activate(logger, pods)
+	}
+}
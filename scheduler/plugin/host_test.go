@@ -20,12 +20,21 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"reflect"
 	"testing"
 
 	"github.com/tetratelabs/wazero/experimental/wazerotest"
+	"google.golang.org/protobuf/encoding/protowire"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
 	"k8s.io/klog/v2"
 	k8stest "k8s.io/klog/v2/test"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	st "k8s.io/kubernetes/pkg/scheduler/testing"
 
 	"sigs.k8s.io/kube-scheduler-wasm-extension/scheduler/test"
 )
@@ -187,3 +196,347 @@ func Test_k8sHandleRejectWaitingPodFn(t *testing.T) {
 		t.Fatalf("unexpected uid: %v != %v", want, have)
 	}
 }
+
+func Test_k8sSchedulerNodeInfoResourcesFn(t *testing.T) {
+	h := host{}
+
+	pod := st.MakePod().Name("pod").Req(map[v1.ResourceName]string{v1.ResourceCPU: "100m"}).
+		ContainerPort([]v1.ContainerPort{{HostPort: 8080, Protocol: v1.ProtocolTCP}}).Obj()
+	node := st.MakeNode().Name("node").Capacity(map[v1.ResourceName]string{v1.ResourceCPU: "4", v1.ResourcePods: "110"}).Obj()
+	ni := framework.NewNodeInfo(pod)
+	ni.SetNode(node)
+	ctx := context.WithValue(context.Background(), stackKey{}, &stack{currentNodeInfo: ni})
+
+	// Create a fake wasm module, which has data the guest should write.
+	mem := wazerotest.NewMemory(wazerotest.PageSize)
+	mod := wazerotest.NewModule(mem)
+	copy(mem.Bytes, node.Name)
+
+	t.Run("found", func(t *testing.T) {
+		// Invoke the host function in the same way the guest would have.
+		stack := []uint64{0, uint64(len(node.Name)), 256, 4096}
+		h.k8sSchedulerNodeInfoResourcesFn(ctx, mod, stack)
+
+		quantities, ports := unmarshalNodeInfoResources(t, mem.Bytes[256:256+stack[0]])
+		// The ports are in a second container, which counts with the default
+		// requests of the scheduler.
+		want := map[protowire.Number]map[string]string{
+			nodeInfoResourcesRequested:        {"cpu": "100m"},
+			nodeInfoResourcesNonZeroRequested: {"cpu": "200m", "memory": "419430400"},
+			nodeInfoResourcesAllocatable:      {"cpu": "4000m", "pods": "110"},
+		}
+		if !reflect.DeepEqual(want, quantities) {
+			t.Fatalf("unexpected resources: %v != %v", want, quantities)
+		}
+		wantPorts := []v1.ContainerPort{{HostIP: "0.0.0.0", Protocol: v1.ProtocolTCP, HostPort: 8080}}
+		if !reflect.DeepEqual(wantPorts, ports) {
+			t.Fatalf("unexpected used ports: %v != %v", wantPorts, ports)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		copy(mem.Bytes, "none")
+		stack := []uint64{0, 4, 256, 4096}
+		h.k8sSchedulerNodeInfoResourcesFn(ctx, mod, stack)
+		if stack[0] != 0 {
+			t.Fatalf("unexpected length: %d", stack[0])
+		}
+	})
+}
+
+// unmarshalNodeInfoResources decodes the message documented on
+// nodeInfoResourcesRequested into quantities of each resource field, and used
+// ports.
+func unmarshalNodeInfoResources(t *testing.T, b []byte) (quantities map[protowire.Number]map[string]string, ports []v1.ContainerPort) {
+	t.Helper()
+	quantities = map[protowire.Number]map[string]string{}
+	for len(b) > 0 {
+		num, _, n := protowire.ConsumeTag(b)
+		b = b[n:]
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		if num == nodeInfoResourcesUsedPorts {
+			var port v1.ContainerPort
+			if err := port.Unmarshal(v); err != nil {
+				t.Fatal(err)
+			}
+			ports = append(ports, port)
+			continue
+		}
+
+		// Each map entry is a name, and a resource.Quantity with its string.
+		var name, quantity string
+		for len(v) > 0 {
+			entryNum, _, n := protowire.ConsumeTag(v)
+			v = v[n:]
+			field, n := protowire.ConsumeBytes(v)
+			v = v[n:]
+			if entryNum == 1 {
+				name = string(field)
+			} else {
+				_, _, n = protowire.ConsumeTag(field)
+				quantity, _ = protowire.ConsumeString(field[n:])
+			}
+		}
+		if quantities[num] == nil {
+			quantities[num] = map[string]string{}
+		}
+		quantities[num][name] = quantity
+	}
+	return
+}
+
+func Test_k8sSchedulerStorageInfosIsPVCUsedByPodsFn(t *testing.T) {
+	h := host{handle: &test.FakeHandle{
+		SharedLister: &test.FakeSharedLister{
+			StorageInfoLister: &test.FakeStorageInfoLister{UsedPVCs: []string{"ns/used"}},
+		},
+	}}
+
+	mem := wazerotest.NewMemory(wazerotest.PageSize)
+	mod := wazerotest.NewModule(mem)
+
+	tests := []struct {
+		key  string
+		want uint64
+	}{
+		{key: "ns/used", want: 1},
+		{key: "ns/unused", want: 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.key, func(t *testing.T) {
+			copy(mem.Bytes, tc.key)
+			stack := []uint64{0, uint64(len(tc.key))}
+			h.k8sSchedulerStorageInfosIsPVCUsedByPodsFn(context.Background(), mod, stack)
+			if want, have := tc.want, stack[0]; want != have {
+				t.Fatalf("unexpected result: %v != %v", want, have)
+			}
+		})
+	}
+}
+
+func Test_k8sSchedulerStorageFns(t *testing.T) {
+	factory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	h := host{storage: newStorageListers(factory)}
+
+	pvc := &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "pvc"}}
+	pv := &v1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "pv"}}
+	sc := &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "sc"}, Provisioner: "csi.example.com"}
+	csiNode := &storagev1.CSINode{ObjectMeta: metav1.ObjectMeta{Name: "node"}}
+	for _, err := range []error{
+		factory.Core().V1().PersistentVolumeClaims().Informer().GetIndexer().Add(pvc),
+		factory.Core().V1().PersistentVolumes().Informer().GetIndexer().Add(pv),
+		factory.Storage().V1().StorageClasses().Informer().GetIndexer().Add(sc),
+		factory.Storage().V1().CSINodes().Informer().GetIndexer().Add(csiNode),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Create a fake wasm module, which has data the guest should write.
+	mem := wazerotest.NewMemory(wazerotest.PageSize)
+	mod := wazerotest.NewModule(mem)
+
+	t.Run("persistent volume claim", func(t *testing.T) {
+		copy(mem.Bytes, "nspvc")
+		stack := []uint64{0, 2, 2, 3, 256, 4096}
+		h.k8sSchedulerStoragePVCFn(context.Background(), mod, stack)
+
+		var have v1.PersistentVolumeClaim
+		if err := have.Unmarshal(mem.Bytes[256 : 256+stack[0]]); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(pvc, &have) {
+			t.Fatalf("unexpected pvc: %v != %v", pvc, &have)
+		}
+
+		copy(mem.Bytes, "nsnone")
+		stack = []uint64{0, 2, 2, 4, 256, 4096}
+		h.k8sSchedulerStoragePVCFn(context.Background(), mod, stack)
+		if stack[0] != 0 {
+			t.Fatalf("unexpected length: %d", stack[0])
+		}
+	})
+
+	t.Run("persistent volume", func(t *testing.T) {
+		copy(mem.Bytes, pv.Name)
+		stack := []uint64{0, uint64(len(pv.Name)), 256, 4096}
+		h.k8sSchedulerStoragePVFn(context.Background(), mod, stack)
+
+		var have v1.PersistentVolume
+		if err := have.Unmarshal(mem.Bytes[256 : 256+stack[0]]); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(pv, &have) {
+			t.Fatalf("unexpected pv: %v != %v", pv, &have)
+		}
+	})
+
+	t.Run("storage class", func(t *testing.T) {
+		copy(mem.Bytes, sc.Name)
+		stack := []uint64{0, uint64(len(sc.Name)), 256, 4096}
+		h.k8sSchedulerStorageClassFn(context.Background(), mod, stack)
+
+		var have storagev1.StorageClass
+		if err := json.Unmarshal(mem.Bytes[256:256+stack[0]], &have); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(sc, &have) {
+			t.Fatalf("unexpected storage class: %v != %v", sc, &have)
+		}
+	})
+
+	t.Run("csi node", func(t *testing.T) {
+		copy(mem.Bytes, csiNode.Name)
+		stack := []uint64{0, uint64(len(csiNode.Name)), 256, 4096}
+		h.k8sSchedulerStorageCSINodeFn(context.Background(), mod, stack)
+
+		var have storagev1.CSINode
+		if err := json.Unmarshal(mem.Bytes[256:256+stack[0]], &have); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(csiNode, &have) {
+			t.Fatalf("unexpected csi node: %v != %v", csiNode, &have)
+		}
+
+		copy(mem.Bytes, "none")
+		stack = []uint64{0, 4, 256, 4096}
+		h.k8sSchedulerStorageCSINodeFn(context.Background(), mod, stack)
+		if stack[0] != 0 {
+			t.Fatalf("unexpected length: %d", stack[0])
+		}
+	})
+}
+
+func Test_k8sHandleNominatedPodsForNodeFn(t *testing.T) {
+	pod, err := framework.NewPodInfo(st.MakePod().Name("nominated").Namespace("ns").UID("nominated").Obj())
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle := &test.FakeHandle{NominatedPods: map[string][]*framework.PodInfo{"node": {pod}}}
+	h := host{handle: handle}
+
+	// Create a fake wasm module, which has data the guest should write.
+	mem := wazerotest.NewMemory(wazerotest.PageSize)
+	mod := wazerotest.NewModule(mem)
+
+	t.Run("nominated", func(t *testing.T) {
+		copy(mem.Bytes, "node")
+		stack := []uint64{0, 4, 256, 4096}
+		h.k8sHandleNominatedPodsForNodeFn(context.Background(), mod, stack)
+
+		var have v1.PodList
+		if err := have.Unmarshal(mem.Bytes[256 : 256+stack[0]]); err != nil {
+			t.Fatal(err)
+		}
+		if want, have := []v1.Pod{*pod.Pod}, have.Items; !reflect.DeepEqual(want, have) {
+			t.Fatalf("unexpected pods: %v != %v", want, have)
+		}
+	})
+
+	t.Run("none", func(t *testing.T) {
+		copy(mem.Bytes, "none")
+		stack := []uint64{0, 4, 256, 4096}
+		h.k8sHandleNominatedPodsForNodeFn(context.Background(), mod, stack)
+
+		var have v1.PodList
+		if err := have.Unmarshal(mem.Bytes[256 : 256+stack[0]]); err != nil {
+			t.Fatal(err)
+		}
+		if len(have.Items) != 0 {
+			t.Fatalf("unexpected pods: %v", have.Items)
+		}
+	})
+}
+
+func Test_k8sSchedulerInformerFns(t *testing.T) {
+	factory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	l, err := newInformerListers(context.Background(), []string{"v1/namespaces"}, factory, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns", Labels: map[string]string{"tenant": "a"}}}
+	if err = factory.Core().V1().Namespaces().Informer().GetIndexer().Add(ns); err != nil {
+		t.Fatal(err)
+	}
+	h := host{informers: l}
+
+	// Create a fake wasm module, which has data the guest should write.
+	mem := wazerotest.NewMemory(wazerotest.PageSize)
+	mod := wazerotest.NewModule(mem)
+	copy(mem.Bytes, "v1/namespacesnstenant=a")
+
+	t.Run("get", func(t *testing.T) {
+		stack := []uint64{0, 13, 0, 0, 13, 2, uint64(informerEncodingProtobuf), 256, 4096}
+		h.k8sSchedulerInformerGetFn(context.Background(), mod, stack)
+
+		var have v1.Namespace
+		if err := have.Unmarshal(mem.Bytes[256 : 256+stack[0]]); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ns, &have) {
+			t.Fatalf("unexpected namespace: %v != %v", ns, &have)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		stack := []uint64{0, 13, 0, 0, 15, 8, uint64(informerEncodingJSON), 256, 4096}
+		h.k8sSchedulerInformerListFn(context.Background(), mod, stack)
+
+		var have []*v1.Namespace
+		if err := json.Unmarshal(mem.Bytes[256:256+stack[0]], &have); err != nil {
+			t.Fatal(err)
+		}
+		if want := []*v1.Namespace{ns}; !reflect.DeepEqual(want, have) {
+			t.Fatalf("unexpected namespaces: %v != %v", want, have)
+		}
+	})
+
+	t.Run("list invalid selector", func(t *testing.T) {
+		// The selector "tenant in" is missing its set of values.
+		copy(mem.Bytes[32:], "tenant in")
+		stack := []uint64{0, 13, 0, 0, 32, 9, uint64(informerEncodingJSON), 256, 4096}
+		h.k8sSchedulerInformerListFn(context.Background(), mod, stack)
+
+		if want, have := uint64(1), stack[0]>>32; want != have {
+			t.Fatalf("unexpected error flag: want %d, have %d", want, have)
+		}
+		if errMsg := string(mem.Bytes[256 : 256+uint32(stack[0])]); errMsg == "" {
+			t.Fatal("expected an error message")
+		}
+	})
+}
+
+func Test_k8sHandleActivateFn(t *testing.T) {
+	factory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	pod := st.MakePod().Name("gang-member").Namespace("ns").UID("gang-member").Obj()
+	if err := factory.Core().V1().Pods().Informer().GetIndexer().Add(pod); err != nil {
+		t.Fatal(err)
+	}
+	handle := &test.FakeHandle{SharedInformerFactoryValue: factory}
+	h := host{handle: handle}
+
+	// Create a fake wasm module, which has data the guest should write.
+	mem := wazerotest.NewMemory(wazerotest.PageSize)
+	mod := wazerotest.NewModule(mem)
+	podKeys := "ns/gang-member\x00ns/deleted\x00ns/invalid/key\x00"
+	copy(mem.Bytes, podKeys)
+
+	// Invoke the host function in the same way the guest would have.
+	h.k8sHandleActivateFn(context.Background(), mod, []uint64{0, uint64(len(podKeys))})
+
+	// The deleted pod isn't activated, as it isn't in the informer, and the
+	// invalid key is skipped.
+	want := map[string]*v1.Pod{"ns/gang-member": pod}
+	if have := handle.ActivatedPods; !reflect.DeepEqual(want, have) {
+		t.Fatalf("unexpected pods: %v != %v", want, have)
+	}
+
+	// Without a handle, such as in tests, nothing happens.
+	host{}.k8sHandleActivateFn(context.Background(), mod, []uint64{0, uint64(len(podKeys))})
+}
@@ -0,0 +1,206 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package wasm
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// informerEncodingProtobuf encodes objects as protobuf, which is only
+	// supported for built-in resources.
+	informerEncodingProtobuf uint32 = iota
+	// informerEncodingJSON encodes objects as JSON.
+	informerEncodingJSON
+)
+
+// informerListers are the listers of the resources in PluginConfig.Informers.
+// Only these resources are visible to the guest.
+type informerListers struct {
+	// listers are keyed by the resource, as written in PluginConfig.Informers.
+	listers map[string]cache.GenericLister
+
+	// dynamicFactory, if set, has informers of resources which aren't in the
+	// scheduler's informer factory, such as custom resources.
+	dynamicFactory dynamicinformer.DynamicSharedInformerFactory
+	// stop stops the dynamicFactory.
+	stop context.CancelFunc
+}
+
+// newInformerListers registers informers for each resource, formatted like
+// "group/version/resource", or "version/resource" for the core group. Built-in
+// resources use the scheduler's factory, which must not have started yet.
+// Others use a dynamic informer, which is started here.
+func newInformerListers(ctx context.Context, resources []string, factory informers.SharedInformerFactory, newDynamicClient func() (dynamic.Interface, error)) (*informerListers, error) {
+	l := &informerListers{listers: make(map[string]cache.GenericLister, len(resources))}
+	for _, resource := range resources {
+		gvr, err := parseGroupVersionResource(resource)
+		if err != nil {
+			return nil, err
+		}
+
+		if informer, err := factory.ForResource(gvr); err == nil {
+			l.listers[resource] = informer.Lister()
+			continue
+		}
+
+		if l.dynamicFactory == nil {
+			client, err := newDynamicClient()
+			if err != nil {
+				return nil, fmt.Errorf("wasm: error creating dynamic client for informers: %w", err)
+			}
+			l.dynamicFactory = dynamicinformer.NewDynamicSharedInformerFactory(client, 0)
+		}
+		l.listers[resource] = l.dynamicFactory.ForResource(gvr).Lister()
+	}
+
+	if l.dynamicFactory != nil {
+		// Don't wait for the informers to sync, as a missing custom resource
+		// definition would block the scheduler from starting.
+		ctx, l.stop = context.WithCancel(context.WithoutCancel(ctx))
+		l.dynamicFactory.Start(ctx.Done())
+	}
+	return l, nil
+}
+
+// parseGroupVersionResource parses a resource in PluginConfig.Informers.
+func parseGroupVersionResource(resource string) (gvr schema.GroupVersionResource, err error) {
+	parts := strings.Split(resource, "/")
+	switch {
+	case len(parts) == 2:
+		gvr = schema.GroupVersionResource{Version: parts[0], Resource: parts[1]}
+	case len(parts) == 3 && parts[0] != "":
+		gvr = schema.GroupVersionResource{Group: parts[0], Version: parts[1], Resource: parts[2]}
+	}
+	if gvr.Version == "" || gvr.Resource == "" {
+		err = fmt.Errorf("wasm: invalid informers resource %q, expected group/version/resource or version/resource", resource)
+	}
+	return
+}
+
+// close stops any dynamic informers.
+func (l *informerListers) close() {
+	if l != nil && l.stop != nil {
+		l.stop()
+	}
+}
+
+// lister returns the lister of the resource, panicking if it isn't allowed.
+func (l *informerListers) lister(resource string) cache.GenericLister {
+	if l != nil {
+		if lister, ok := l.listers[resource]; ok {
+			return lister
+		}
+	}
+	panic(fmt.Sprintf("resource %q isn't in informers", resource))
+}
+
+// get returns the encoded object, or nil if it isn't found.
+func (l *informerListers) get(resource, namespace, name string, encoding uint32) []byte {
+	lister := l.lister(resource)
+
+	var obj runtime.Object
+	var err error
+	if namespace != "" {
+		obj, err = lister.ByNamespace(namespace).Get(name)
+	} else {
+		obj, err = lister.Get(name)
+	}
+	if err != nil {
+		return nil
+	}
+	return encodeObject(resource, obj, encoding)
+}
+
+// list returns the encoded objects matching the label selector, or an error if
+// the selector is invalid. When the encoding is protobuf, each object is a
+// bytes field 1, like a repeated field in a message. Otherwise, the objects
+// are a JSON array.
+func (l *informerListers) list(resource, namespace, selector string, encoding uint32) ([]byte, error) {
+	lister := l.lister(resource)
+
+	s, err := labels.Parse(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var objs []runtime.Object
+	if namespace != "" {
+		objs, err = lister.ByNamespace(namespace).List(s)
+	} else {
+		objs, err = lister.List(s)
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	if encoding == informerEncodingJSON {
+		if objs == nil {
+			objs = []runtime.Object{}
+		}
+		b, err := json.Marshal(objs)
+		if err != nil {
+			panic(err)
+		}
+		return b, nil
+	}
+
+	var b []byte
+	for _, obj := range objs {
+		item := encodeObject(resource, obj, encoding)
+		b = append(b, 0x0a) // field 1, wire type bytes
+		b = binary.AppendUvarint(b, uint64(len(item)))
+		b = append(b, item...)
+	}
+	return b, nil
+}
+
+// encodeObject encodes the object, panicking if the encoding isn't supported.
+func encodeObject(resource string, obj runtime.Object, encoding uint32) []byte {
+	switch encoding {
+	case informerEncodingProtobuf:
+		m, ok := obj.(interface{ Marshal() ([]byte, error) })
+		if !ok {
+			panic(fmt.Sprintf("resource %q doesn't support protobuf", resource))
+		}
+		b, err := m.Marshal()
+		if err != nil {
+			panic(err)
+		}
+		return b
+	case informerEncodingJSON:
+		b, err := json.Marshal(obj)
+		if err != nil {
+			panic(err)
+		}
+		return b
+	default:
+		panic(fmt.Sprintf("unknown encoding %d", encoding))
+	}
+}
@@ -0,0 +1,215 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package wasm
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestParseGroupVersionResource(t *testing.T) {
+	tests := []struct {
+		resource    string
+		want        schema.GroupVersionResource
+		expectedErr string
+	}{
+		{
+			resource: "v1/namespaces",
+			want:     schema.GroupVersionResource{Version: "v1", Resource: "namespaces"},
+		},
+		{
+			resource: "example.com/v1alpha1/tenants",
+			want:     schema.GroupVersionResource{Group: "example.com", Version: "v1alpha1", Resource: "tenants"},
+		},
+		{
+			resource:    "namespaces",
+			expectedErr: `wasm: invalid informers resource "namespaces", expected group/version/resource or version/resource`,
+		},
+		{
+			resource:    "/v1/namespaces",
+			expectedErr: `wasm: invalid informers resource "/v1/namespaces", expected group/version/resource or version/resource`,
+		},
+		{
+			resource:    "v1/",
+			expectedErr: `wasm: invalid informers resource "v1/", expected group/version/resource or version/resource`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.resource, func(t *testing.T) {
+			have, err := parseGroupVersionResource(tc.resource)
+			if tc.expectedErr != "" {
+				if err == nil || err.Error() != tc.expectedErr {
+					t.Fatalf("unexpected error: want %v, have %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := tc.want; want != have {
+				t.Fatalf("unexpected resource: %v != %v", want, have)
+			}
+		})
+	}
+}
+
+func TestInformerListers(t *testing.T) {
+	ctx := context.Background()
+
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant-a", Labels: map[string]string{"tenant": "a"}}}
+	cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-a", Name: "policy"}, Data: map[string]string{"k": "v"}}
+	tenantGVR := schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "tenants"}
+	tenant := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Tenant",
+		"metadata":   map[string]interface{}{"name": "a", "namespace": "tenant-a"},
+	}}
+
+	factory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{tenantGVR: "TenantList"}, tenant)
+	l, err := newInformerListers(ctx, []string{"v1/namespaces", "v1/configmaps", "example.com/v1/tenants"}, factory, func() (dynamic.Interface, error) {
+		return client, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.close()
+
+	for _, obj := range []runtime.Object{ns, cm} {
+		var informer cache.SharedIndexInformer
+		switch obj.(type) {
+		case *v1.Namespace:
+			informer = factory.Core().V1().Namespaces().Informer()
+		case *v1.ConfigMap:
+			informer = factory.Core().V1().ConfigMaps().Informer()
+		}
+		if err = informer.GetIndexer().Add(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.dynamicFactory.WaitForCacheSync(ctx.Done())
+
+	t.Run("get protobuf", func(t *testing.T) {
+		var have v1.ConfigMap
+		if err := have.Unmarshal(l.get("v1/configmaps", "tenant-a", "policy", informerEncodingProtobuf)); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(cm, &have) {
+			t.Fatalf("unexpected config map: %v != %v", cm, &have)
+		}
+	})
+
+	t.Run("get json", func(t *testing.T) {
+		var have unstructured.Unstructured
+		if err := json.Unmarshal(l.get("example.com/v1/tenants", "tenant-a", "a", informerEncodingJSON), &have.Object); err != nil {
+			t.Fatal(err)
+		}
+		if want, have := "Tenant", have.GetKind(); want != have {
+			t.Fatalf("unexpected kind: %v != %v", want, have)
+		}
+	})
+
+	t.Run("get not found", func(t *testing.T) {
+		if b := l.get("v1/namespaces", "", "none", informerEncodingProtobuf); b != nil {
+			t.Fatalf("unexpected object: %s", b)
+		}
+	})
+
+	t.Run("list protobuf", func(t *testing.T) {
+		b, err := l.list("v1/namespaces", "", "tenant=a", informerEncodingProtobuf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Decode the only item, which is field 1 with a one byte length.
+		var have v1.Namespace
+		if b[0] != 0x0a || int(b[1]) != len(b)-2 {
+			t.Fatalf("unexpected encoding: %x", b)
+		}
+		if err := have.Unmarshal(b[2:]); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ns, &have) {
+			t.Fatalf("unexpected namespace: %v != %v", ns, &have)
+		}
+
+		if b, err := l.list("v1/namespaces", "", "tenant=b", informerEncodingProtobuf); err != nil {
+			t.Fatal(err)
+		} else if len(b) != 0 {
+			t.Fatalf("unexpected objects: %x", b)
+		}
+	})
+
+	t.Run("list json", func(t *testing.T) {
+		b, err := l.list("v1/configmaps", "other", "", informerEncodingJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, have := "[]", string(b); want != have {
+			t.Fatalf("unexpected objects: %v != %v", want, have)
+		}
+
+		if b, err = l.list("v1/configmaps", "tenant-a", "", informerEncodingJSON); err != nil {
+			t.Fatal(err)
+		}
+		var have []v1.ConfigMap
+		if err := json.Unmarshal(b, &have); err != nil {
+			t.Fatal(err)
+		}
+		if want := []v1.ConfigMap{*cm}; !reflect.DeepEqual(want, have) {
+			t.Fatalf("unexpected config maps: %v != %v", want, have)
+		}
+	})
+
+	t.Run("list invalid selector", func(t *testing.T) {
+		if _, err := l.list("v1/namespaces", "", "tenant in a", informerEncodingJSON); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("not allowed", func(t *testing.T) {
+		defer func() {
+			if want, have := `resource "v1/secrets" isn't in informers`, recover(); want != have {
+				t.Fatalf("unexpected panic: %v != %v", want, have)
+			}
+		}()
+		l.get("v1/secrets", "tenant-a", "token", informerEncodingJSON)
+	})
+
+	t.Run("protobuf not supported", func(t *testing.T) {
+		defer func() {
+			if want, have := `resource "example.com/v1/tenants" doesn't support protobuf`, recover(); want != have {
+				t.Fatalf("unexpected panic: %v != %v", want, have)
+			}
+		}()
+		l.get("example.com/v1/tenants", "tenant-a", "a", informerEncodingProtobuf)
+	})
+}
@@ -23,6 +23,8 @@ const (
 	iPreBindPlugin
 	iBindPlugin
 	iPostBindPlugin
+	iPreEnqueuePlugin
+	iQueueSortPlugin
 )
 
 // maskInterfaces ensures the caller can do type checking to detect what the
@@ -38,9 +40,17 @@ const (
 //
 //   - framework.PreFilterPlugin is always implemented, because this is used to
 //     reset cycle state.
+//   - framework.PreEnqueuePlugin is always implemented, because it isn't
+//     coupled to any other extension point. It allows all pods unless the
+//     guest exports preenqueue.
+//   - framework.QueueSortPlugin can't be combined with filter, score, reserve,
+//     permit or bind. A profile has exactly one queue sort plugin, and it
+//     must be the same in all profiles, so combining them would prevent
+//     using the other extension points in multiple profiles.
 func maskInterfaces(plugin *wasmPlugin) (framework.Plugin, error) {
 	// First, mask all interfaces that are coupled together
 	i := plugin.guestInterfaces & ^(iEnqueueExtensions |
+		iPreEnqueuePlugin |
 		iPreFilterExtensions |
 		iPreFilterPlugin |
 		iPostFilterPlugin |
@@ -49,6 +59,17 @@ func maskInterfaces(plugin *wasmPlugin) (framework.Plugin, error) {
 		iPreBindPlugin |
 		iPostBindPlugin)
 
+	if i&iQueueSortPlugin != 0 {
+		if i != iQueueSortPlugin {
+			return nil, errors.New("wasm: less cannot be exported with filter, score, reserve, permit or bind")
+		}
+		type queueSort interface {
+			basePlugin
+			queueSortPlugin
+		}
+		return struct{ queueSort }{plugin}, nil
+	}
+
 	switch i {
 	case iFilterPlugin:
 		type filter interface {
@@ -225,18 +246,25 @@ func maskInterfaces(plugin *wasmPlugin) (framework.Plugin, error) {
 	switch plugin.guestInterfaces {
 	case iPreFilterPlugin: // Special-cased form of filter.
 		return struct{ basePlugin }{plugin}, nil
+	case iPreEnqueuePlugin, iPreEnqueuePlugin | iEnqueueExtensions: // Only gates pods.
+		return struct{ basePlugin }{plugin}, nil
 	default:
-		return nil, errors.New("wasm: filter, score, reserve, permit or bind must be exported")
+		return nil, errors.New("wasm: preenqueue, less, filter, score, reserve, permit or bind must be exported")
 	}
 }
 
 type basePlugin interface {
 	framework.EnqueueExtensions
+	framework.PreEnqueuePlugin
 	framework.PreFilterPlugin // to implement cycle state reset
 	io.Closer
 	ProfilerSupport
 }
 
+type queueSortPlugin interface {
+	framework.QueueSortPlugin
+}
+
 type filterPlugin interface {
 	framework.PreFilterExtensions
 	framework.PreFilterPlugin
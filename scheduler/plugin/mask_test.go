@@ -16,6 +16,7 @@ func Test_maskInterfaces(t *testing.T) {
 		expectReserve bool
 		expectPermit  bool
 		expectBind    bool
+		expectSort    bool
 	}{
 		{
 			name:        "prescore",
@@ -26,6 +27,25 @@ func Test_maskInterfaces(t *testing.T) {
 			name:   "prefilter", // special case of filter
 			plugin: &wasmPlugin{guestInterfaces: iPreFilterPlugin},
 		},
+		{
+			name:       "less",
+			plugin:     &wasmPlugin{guestInterfaces: iQueueSortPlugin},
+			expectSort: true,
+		},
+		{
+			name:        "less|filter",
+			plugin:      &wasmPlugin{guestInterfaces: iQueueSortPlugin | iFilterPlugin},
+			expectError: true, // queue sort must be exported alone
+		},
+		{
+			name:   "preenqueue", // only gates pods
+			plugin: &wasmPlugin{guestInterfaces: iPreEnqueuePlugin},
+		},
+		{
+			name:         "preenqueue|filter",
+			plugin:       &wasmPlugin{guestInterfaces: iPreEnqueuePlugin | iFilterPlugin},
+			expectFilter: true,
+		},
 		{
 			name:         "prefilter|filter",
 			plugin:       &wasmPlugin{guestInterfaces: iPreFilterPlugin | iFilterPlugin},
@@ -101,6 +121,9 @@ func Test_maskInterfaces(t *testing.T) {
 			if _, ok := p.(bindPlugin); tc.expectBind != ok {
 				t.Fatalf("didn't expect bindPlugin %v", p)
 			}
+			if _, ok := p.(queueSortPlugin); tc.expectSort != ok {
+				t.Fatalf("didn't expect queueSortPlugin %v", p)
+			}
 		})
 	}
 }
@@ -16,7 +16,15 @@
 
 package wasm
 
-import wazeroapi "github.com/tetratelabs/wazero/api"
+import (
+	"fmt"
+
+	wazeroapi "github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+)
+
+// wasmPageSize is the size of a WebAssembly memory page: 64KiB.
+const wasmPageSize = 65536
 
 // bufLimit is the possibly zero maximum length of a result value to write in
 // bytes. If the actual value is larger than this, nothing is written to
@@ -28,7 +36,9 @@ type valueType interface {
 	MarshalToSizedBuffer(dAtA []byte) (int, error)
 }
 
-func marshalIfUnderLimit(mem wazeroapi.Memory, vt valueType, buf uint32, bufLimit bufLimit) int {
+// marshalIfUnderLimit writes vt to buf when it fits in bufLimit, counting the
+// bytes written for hostFn. It returns the size of vt.
+func marshalIfUnderLimit(mem wazeroapi.Memory, hostFn string, vt valueType, buf uint32, bufLimit bufLimit) int {
 	// First, see if the caller passed enough memory to serialize the object.
 	vLen := vt.Size()
 	if vLen == 0 {
@@ -49,13 +59,16 @@ func marshalIfUnderLimit(mem wazeroapi.Memory, vt valueType, buf uint32, bufLimi
 	} else if _, err := vt.MarshalToSizedBuffer(wasmMem); err != nil {
 		panic(err) // Bug: in marshaller.
 	}
+	hostFunctionBytes.WithLabelValues(hostFn).Add(float64(vLen))
 
 	// Success: return the bytes written, so that the caller can unmarshal from
 	// a sized buffer.
 	return vLen
 }
 
-func writeStringIfUnderLimit(mem wazeroapi.Memory, v string, buf uint32, bufLimit bufLimit) int {
+// writeStringIfUnderLimit writes v to buf when it fits in bufLimit, counting
+// the bytes written for hostFn. It returns the length of v.
+func writeStringIfUnderLimit(mem wazeroapi.Memory, hostFn string, v string, buf uint32, bufLimit bufLimit) int {
 	vLen := len(v)
 	if vLen == 0 {
 		return 0 // nothing to write
@@ -71,9 +84,61 @@ func writeStringIfUnderLimit(mem wazeroapi.Memory, v string, buf uint32, bufLimi
 	// Success: return the bytes written, so that the caller knows how to read
 	// from buf.
 	mem.WriteString(buf, v)
+	hostFunctionBytes.WithLabelValues(hostFn).Add(float64(vLen))
 	return vLen
 }
 
 func writeUint64(mem wazeroapi.Memory, v uint64, buf uint32, bufLimit bufLimit) {
 	mem.WriteUint64Le(buf, v)
 }
+
+// memoryLimitError is raised when a guest tries to grow its memory past the
+// configured limit.
+type memoryLimitError struct {
+	limitPages, requestedPages uint64
+}
+
+// Error implements error.
+func (e *memoryLimitError) Error() string {
+	return fmt.Sprintf("memory limit of %d pages exceeded: requested %d pages", e.limitPages, e.requestedPages)
+}
+
+// memoryLimiter is an experimental.MemoryAllocator which enforces a limit of
+// pages on each guest memory.
+//
+// Unlike wazero.RuntimeConfig WithMemoryLimitPages, exceeding the limit is
+// known precisely, and guests that declare a larger maximum still compile.
+type memoryLimiter uint32
+
+// Allocate implements experimental.MemoryAllocator.
+func (l memoryLimiter) Allocate(cap, _ uint64) experimental.LinearMemory {
+	return &limitedMemory{buf: make([]byte, 0, cap), limit: uint64(l) * wasmPageSize}
+}
+
+// limitedMemory is a experimental.LinearMemory that panics on growth past its
+// limit. wazero recovers the panic, failing the current guest call with it.
+type limitedMemory struct {
+	buf   []byte
+	limit uint64
+}
+
+// Reallocate implements experimental.LinearMemory.
+func (m *limitedMemory) Reallocate(size uint64) []byte {
+	if size > m.limit {
+		panic(&memoryLimitError{limitPages: m.limit / wasmPageSize, requestedPages: size / wasmPageSize})
+	}
+	if size > uint64(cap(m.buf)) {
+		buf := make([]byte, size)
+		copy(buf, m.buf)
+		m.buf = buf
+	} else {
+		// Memory never shrinks, so the capacity beyond the length is zero.
+		m.buf = m.buf[:size]
+	}
+	return m.buf
+}
+
+// Free implements experimental.LinearMemory.
+func (m *limitedMemory) Free() {
+	m.buf = nil
+}
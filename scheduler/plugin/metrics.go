@@ -0,0 +1,149 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package wasm
+
+import (
+	"sync"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+const (
+	metricsNamespace = "scheduler"
+	metricsSubsystem = "wasm"
+)
+
+// Values of the "reason" label of guestCallErrors.
+const (
+	errorReasonTrap        = "trap"
+	errorReasonTimeout     = "timeout"
+	errorReasonMemoryLimit = "memory_limit"
+)
+
+// Values of the "result" label of guestReloads.
+const (
+	reloadResultSuccess  = "success"
+	reloadResultRejected = "rejected"
+	reloadResultError    = "error"
+)
+
+// Values of the "state" label of guestPoolSize.
+const (
+	guestStateFree       = "free"
+	guestStateBinding    = "binding"
+	guestStateScheduling = "scheduling"
+)
+
+var (
+	// guestCallDuration is the latency of guest calls, including any host
+	// functions they call.
+	guestCallDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "guest_call_duration_seconds",
+			Help:           "Duration of calls to a wasm guest, by plugin and extension point.",
+			Buckets:        metrics.ExponentialBuckets(0.00001, 1.5, 20),
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"plugin", "extension_point"})
+
+	// guestCallErrors counts guest calls that failed, for example traps.
+	guestCallErrors = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "guest_call_errors_total",
+			Help:           "Number of calls to a wasm guest that failed, by plugin, extension point and reason.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"plugin", "extension_point", "reason"})
+
+	// guestPoolSize is the count of guests in the pool of each plugin.
+	guestPoolSize = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "guest_pool_size",
+			Help:           "Number of wasm guests in the pool, by plugin and state: free, binding or scheduling.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"plugin", "state"})
+
+	// guestInstantiations counts guests created, including replacements of
+	// guests which were closed.
+	guestInstantiations = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "guest_instantiations_total",
+			Help:           "Number of wasm guests instantiated, by plugin.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"plugin"})
+
+	// hostFunctionBytes counts bytes written into guest memory by host
+	// functions.
+	hostFunctionBytes = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "host_function_bytes_total",
+			Help:           "Number of bytes written to wasm guest memory, by host function.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"function"})
+
+	// guestReloads counts attempts to reload a changed guest.
+	guestReloads = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "guest_reloads_total",
+			Help:           "Number of attempts to reload a changed wasm guest, by plugin and result: success, rejected or error.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"plugin", "result"})
+
+	registerMetrics sync.Once
+)
+
+// RegisterMetrics registers the metrics of wasm plugins with the legacy
+// registry, used by the scheduler. It is safe to call multiple times.
+func RegisterMetrics() {
+	registerMetrics.Do(func() {
+		legacyregistry.MustRegister(
+			guestCallDuration,
+			guestCallErrors,
+			guestPoolSize,
+			guestInstantiations,
+			hostFunctionBytes,
+			guestReloads,
+		)
+	})
+}
+
+// reportPoolSize returns a function which updates guestPoolSize for the
+// plugin.
+func reportPoolSize(pluginName string) func(free, binding, scheduling int) {
+	return func(free, binding, scheduling int) {
+		guestPoolSize.WithLabelValues(pluginName, guestStateFree).Set(float64(free))
+		guestPoolSize.WithLabelValues(pluginName, guestStateBinding).Set(float64(binding))
+		guestPoolSize.WithLabelValues(pluginName, guestStateScheduling).Set(float64(scheduling))
+	}
+}
@@ -0,0 +1,131 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package wasm
+
+import (
+	"io"
+	"testing"
+
+	"github.com/tetratelabs/wazero/experimental/wazerotest"
+	"k8s.io/component-base/metrics/testutil"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+
+	"sigs.k8s.io/kube-scheduler-wasm-extension/scheduler/test"
+)
+
+func Test_metrics_guestCall(t *testing.T) {
+	tests := []struct {
+		name         string
+		guestURL     string
+		expectedCode framework.Code
+		errorReason  string
+	}{
+		{
+			name:         "success",
+			guestURL:     test.URLTestFilterFromGlobal,
+			expectedCode: framework.Success,
+		},
+		{
+			name:         "trap",
+			guestURL:     test.URLErrorPanicOnFilter,
+			expectedCode: framework.Error,
+			errorReason:  errorReasonTrap,
+		},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.name, func(t *testing.T) {
+			// Use a plugin name per test, as metrics are global.
+			pluginName := "metrics_" + tt.name
+			p, err := NewFromConfig(ctx, pluginName, PluginConfig{GuestURL: tt.guestURL}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.(io.Closer).Close()
+
+			ni := framework.NewNodeInfo()
+			ni.SetNode(test.NodeSmall)
+			if s := p.(framework.FilterPlugin).Filter(ctx, nil, test.PodSmall, ni); s.Code() != tt.expectedCode {
+				t.Fatalf("unexpected status code: want %d, have %d (message: %v)", tt.expectedCode, s.Code(), s.Message())
+			}
+
+			if have, err := testutil.GetHistogramMetricCount(guestCallDuration.WithLabelValues(pluginName, guestExportFilter)); err != nil {
+				t.Fatal(err)
+			} else if want := uint64(1); want != have {
+				t.Fatalf("unexpected call count: want %d, have %d", want, have)
+			}
+
+			// Only the prewarmed guest was created.
+			if have, err := testutil.GetCounterMetricValue(guestInstantiations.WithLabelValues(pluginName)); err != nil {
+				t.Fatal(err)
+			} else if want := float64(1); want != have {
+				t.Fatalf("unexpected instantiations: want %v, have %v", want, have)
+			}
+
+			if tt.errorReason != "" {
+				if have, err := testutil.GetCounterMetricValue(guestCallErrors.WithLabelValues(pluginName, guestExportFilter, tt.errorReason)); err != nil {
+					t.Fatal(err)
+				} else if want := float64(1); want != have {
+					t.Fatalf("unexpected errors: want %v, have %v", want, have)
+				}
+			}
+
+			// A guest which trapped is discarded, so isn't scheduling.
+			wantScheduling := float64(1)
+			if tt.errorReason != "" {
+				wantScheduling = 0
+			}
+			for state, want := range map[string]float64{
+				guestStateFree:       0,
+				guestStateBinding:    0,
+				guestStateScheduling: wantScheduling,
+			} {
+				if have, err := testutil.GetGaugeMetricValue(guestPoolSize.WithLabelValues(pluginName, state)); err != nil {
+					t.Fatal(err)
+				} else if want != have {
+					t.Fatalf("unexpected %s guests: want %v, have %v", state, want, have)
+				}
+			}
+		})
+	}
+}
+
+func Test_metrics_hostFunctionBytes(t *testing.T) {
+	RegisterMetrics()
+
+	h := host{guestConfig: "hello"}
+	mem := wazerotest.NewMemory(wazerotest.PageSize)
+	mod := wazerotest.NewModule(mem)
+	counter := hostFunctionBytes.WithLabelValues(k8sSchedulerGetConfig)
+
+	before, err := testutil.GetCounterMetricValue(counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A buffer too small for the value doesn't count, as nothing is written.
+	h.k8sSchedulerGetConfigFn(ctx, mod, []uint64{0, 1})
+	// This time, the value is written.
+	h.k8sSchedulerGetConfigFn(ctx, mod, []uint64{0, 16})
+
+	if have, err := testutil.GetCounterMetricValue(counter); err != nil {
+		t.Fatal(err)
+	} else if want := float64(len(h.guestConfig)); want != have-before {
+		t.Fatalf("unexpected bytes: want %v, have %v", want, have-before)
+	}
+}
@@ -0,0 +1,436 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package wasm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Media types of manifests accepted from an OCI registry.
+const (
+	mediaTypeOCIManifest    = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+)
+
+// wasmLayerMediaTypes are the media types of a layer holding a wasm guest:
+// the wasm OCI artifact layout of the CNCF TAG Runtime, the older format
+// used by wasm-to-oci, and a plain wasm binary.
+var wasmLayerMediaTypes = map[string]struct{}{
+	"application/vnd.wasm.content.layer.v1+wasm":        {},
+	"application/vnd.module.wasm.content.layer.v1+wasm": {},
+	"application/wasm": {},
+}
+
+// ociReference is a parsed oci:// URL.
+type ociReference struct {
+	// registry is the host and optional port of the registry.
+	registry string
+	// repository is the name of the repository in the registry.
+	repository string
+	// tag is the tag of the manifest, if digest isn't set.
+	tag string
+	// digest is the optional digest of the manifest, which pins it.
+	digest string
+}
+
+// parseOCIReference parses a URL like oci://registry/repository:tag@digest.
+// The tag defaults to "latest", unless the digest is set.
+func parseOCIReference(url string) (ref ociReference, err error) {
+	rest := strings.TrimPrefix(url, "oci://")
+	if i := strings.IndexByte(rest, '@'); i != -1 {
+		rest, ref.digest = rest[:i], rest[i+1:]
+		if !isSHA256Digest(ref.digest) {
+			return ref, fmt.Errorf("invalid OCI reference %s: unsupported digest %q", url, ref.digest)
+		}
+	}
+
+	slash := strings.IndexByte(rest, '/')
+	if slash <= 0 {
+		return ref, fmt.Errorf("invalid OCI reference %s: missing registry or repository", url)
+	}
+	ref.registry, ref.repository = rest[:slash], rest[slash+1:]
+	if colon := strings.LastIndexByte(ref.repository, ':'); colon > strings.LastIndexByte(ref.repository, '/') {
+		ref.repository, ref.tag = ref.repository[:colon], ref.repository[colon+1:]
+		if ref.tag == "" {
+			return ref, fmt.Errorf("invalid OCI reference %s: empty tag", url)
+		}
+	}
+	if ref.repository == "" {
+		return ref, fmt.Errorf("invalid OCI reference %s: missing registry or repository", url)
+	}
+	if ref.tag == "" && ref.digest == "" {
+		ref.tag = "latest"
+	}
+
+	// Docker Hub is an alias, and its official images are in "library".
+	if ref.registry == "docker.io" {
+		ref.registry = "registry-1.docker.io"
+		if !strings.Contains(ref.repository, "/") {
+			ref.repository = "library/" + ref.repository
+		}
+	}
+	return
+}
+
+// isSHA256Digest returns true if the digest is a valid sha256 digest. This
+// also ensures a digest is safe to use in a file path.
+func isSHA256Digest(digest string) bool {
+	hexDigest, ok := strings.CutPrefix(digest, contentHashPrefix)
+	if !ok || len(hexDigest) != 64 || strings.ToLower(hexDigest) != hexDigest {
+		return false
+	}
+	_, err := hex.DecodeString(hexDigest)
+	return err == nil
+}
+
+// ociPullIfChanged pulls the wasm layer of the manifest at the oci:// URL,
+// unless the digest of the manifest is the version.
+//
+// Blobs are read from and written to cacheDir, if set. The manifest is
+// only read from the cache when the URL pins its digest, as tags can move.
+func (f *guestFetcher) ociPullIfChanged(ctx context.Context, url, version string) ([]byte, string, error) {
+	ref, err := parseOCIReference(url)
+	if err != nil {
+		return nil, "", err
+	}
+	c := &ociClient{client: f.client, ref: ref}
+
+	var manifest []byte
+	if ref.digest != "" {
+		if ref.digest == version {
+			return nil, version, nil
+		}
+		manifest = f.readCachedBlob(ref.digest)
+	}
+	if manifest == nil {
+		accept := mediaTypeOCIManifest + ", " + mediaTypeDockerManifest
+		reference := ref.tag
+		if ref.digest != "" {
+			reference = ref.digest
+		}
+		if manifest, err = c.get(ctx, "/manifests/"+reference, accept); err != nil {
+			return nil, "", err
+		}
+		if ref.digest != "" {
+			if err = f.verifyAndCacheBlob(ref.digest, manifest); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+
+	manifestDigest := contentHash(manifest)
+	if manifestDigest == version {
+		return nil, version, nil
+	}
+
+	layer, err := wasmLayer(manifest)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w in %s", err, url)
+	}
+	bin := f.readCachedBlob(layer.Digest)
+	if bin == nil {
+		if bin, err = c.get(ctx, "/blobs/"+layer.Digest, ""); err != nil {
+			return nil, "", err
+		}
+		if err = f.verifyAndCacheBlob(layer.Digest, bin); err != nil {
+			return nil, "", err
+		}
+	}
+	return bin, manifestDigest, nil
+}
+
+// ociDescriptor describes a layer in an OCI manifest.
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+// wasmLayer returns the only layer in the manifest with a wasm media type.
+func wasmLayer(manifest []byte) (layer ociDescriptor, err error) {
+	var m struct {
+		Layers []ociDescriptor `json:"layers"`
+	}
+	if err = json.Unmarshal(manifest, &m); err != nil {
+		return layer, fmt.Errorf("invalid OCI manifest: %w", err)
+	}
+
+	found := false
+	for _, l := range m.Layers {
+		if _, ok := wasmLayerMediaTypes[l.MediaType]; !ok {
+			continue
+		} else if found {
+			return layer, errors.New("more than one wasm layer in OCI manifest")
+		}
+		layer, found = l, true
+	}
+	if !found {
+		return layer, errors.New("no wasm layer in OCI manifest")
+	} else if !isSHA256Digest(layer.Digest) {
+		return layer, fmt.Errorf("unsupported digest %q of wasm layer in OCI manifest", layer.Digest)
+	}
+	return layer, nil
+}
+
+// cachedBlobPath returns the path of a blob in cacheDir.
+func (f *guestFetcher) cachedBlobPath(digest string) string {
+	return filepath.Join(f.cacheDir, "blobs", "sha256", strings.TrimPrefix(digest, contentHashPrefix))
+}
+
+// readCachedBlob returns the blob with the digest from cacheDir, or nil if
+// it isn't cached or is corrupt.
+func (f *guestFetcher) readCachedBlob(digest string) []byte {
+	if f.cacheDir == "" {
+		return nil
+	}
+	if blob, err := os.ReadFile(f.cachedBlobPath(digest)); err == nil && contentHash(blob) == digest {
+		return blob
+	}
+	return nil
+}
+
+// verifyAndCacheBlob returns an error if the blob doesn't match its digest.
+// Otherwise, it writes the blob to cacheDir, if set.
+func (f *guestFetcher) verifyAndCacheBlob(digest string, blob []byte) error {
+	if have := contentHash(blob); have != digest {
+		return fmt.Errorf("digest mismatch: expected %s, have %s", digest, have)
+	}
+	if f.cacheDir == "" {
+		return nil
+	}
+
+	if err := writeFileAtomic(f.cachedBlobPath(digest), blob); err != nil {
+		return fmt.Errorf("error caching blob %s: %w", digest, err)
+	}
+	return nil
+}
+
+// ociClient reads from a repository in an OCI registry, using the
+// distribution API.
+type ociClient struct {
+	client *http.Client
+	ref    ociReference
+
+	// authorization is the value of the Authorization header, set after the
+	// registry challenges a request.
+	authorization string
+}
+
+// get reads the content at the path under the repository, authorizing when
+// the registry requires it.
+func (c *ociClient) get(ctx context.Context, path, accept string) ([]byte, error) {
+	url := "https://" + c.ref.registry + "/v2/" + c.ref.repository + path
+	resp, err := c.do(ctx, url, accept)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && c.authorization == "" {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if c.authorization, err = c.authorize(ctx, challenge); err != nil {
+			return nil, fmt.Errorf("error authorizing to %s: %w", c.ref.registry, err)
+		}
+		if resp, err = c.do(ctx, url, accept); err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &statusError{statusCode: resp.StatusCode, url: url}
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (c *ociClient) do(ctx context.Context, url, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if c.authorization != "" {
+		req.Header.Set("Authorization", c.authorization)
+	}
+	return c.client.Do(req)
+}
+
+// authorize returns the value of the Authorization header which satisfies
+// the challenge in a WWW-Authenticate header, using any credentials in the
+// docker config.
+func (c *ociClient) authorize(ctx context.Context, challenge string) (string, error) {
+	username, password, err := dockerCredentials(c.ref.registry)
+	if err != nil {
+		return "", err
+	}
+
+	scheme, params := parseChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if username == "" && password == "" {
+			return "", errors.New("no credentials in docker config")
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password)), nil
+	case "bearer":
+		return c.token(ctx, params, username, password)
+	default:
+		return "", fmt.Errorf("unsupported challenge %q", challenge)
+	}
+}
+
+// token requests a bearer token from the realm in the challenge parameters,
+// as described by the docker registry token authentication specification.
+func (c *ociClient) token(ctx context.Context, params map[string]string, username, password string) (string, error) {
+	realm, err := neturl.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("invalid token realm %q", params["realm"])
+	}
+	query := realm.Query()
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + c.ref.repository + ":pull"
+	}
+	query.Set("scope", scope)
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if username != "" || password != "" {
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", &statusError{statusCode: resp.StatusCode, url: realm.String()}
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("invalid token response: %w", err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if token.Token == "" {
+		return "", errors.New("empty token")
+	}
+	return "Bearer " + token.Token, nil
+}
+
+// parseChallenge parses the scheme and parameters of a WWW-Authenticate
+// header, such as `Bearer realm="https://auth.example.com",service="example"`.
+func parseChallenge(challenge string) (scheme string, params map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+	params = map[string]string{}
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimLeft(rest, ", ") {
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if quoted, ok := strings.CutPrefix(value, `"`); ok {
+			value, rest, _ = strings.Cut(quoted, `"`)
+		} else {
+			value, rest, _ = strings.Cut(value, ",")
+		}
+		params[key] = strings.TrimSpace(value)
+	}
+	return
+}
+
+// dockerCredentials returns any credentials for the registry in the docker
+// config.json, located in $DOCKER_CONFIG or ~/.docker. Credential helpers are
+// not supported.
+func dockerCredentials(registry string) (username, password string, err error) {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, homeErr := os.UserHomeDir()
+		if homeErr != nil {
+			return "", "", nil // no config to read
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", "", nil
+	} else if err != nil {
+		return "", "", fmt.Errorf("error reading docker config: %w", err)
+	}
+
+	var config struct {
+		Auths map[string]struct {
+			Auth     string `json:"auth"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"auths"`
+	}
+	if err = json.Unmarshal(b, &config); err != nil {
+		return "", "", fmt.Errorf("invalid docker config: %w", err)
+	}
+	for key, auth := range config.Auths {
+		if credentialsRegistry(key) != registry {
+			continue
+		}
+		if auth.Auth == "" {
+			return auth.Username, auth.Password, nil
+		}
+		decoded, decodeErr := base64.StdEncoding.DecodeString(auth.Auth)
+		if decodeErr != nil {
+			return "", "", fmt.Errorf("invalid auth for %s in docker config: %w", key, decodeErr)
+		}
+		username, password, _ = strings.Cut(string(decoded), ":")
+		return username, password, nil
+	}
+	return "", "", nil
+}
+
+// credentialsRegistry returns the registry of a key in the auths of a docker
+// config, which may be a URL, such as "https://index.docker.io/v1/".
+func credentialsRegistry(key string) string {
+	key = strings.TrimPrefix(strings.TrimPrefix(key, "https://"), "http://")
+	key, _, _ = strings.Cut(key, "/")
+	switch key {
+	case "docker.io", "index.docker.io":
+		return "registry-1.docker.io"
+	}
+	return key
+}
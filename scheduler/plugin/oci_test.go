@@ -0,0 +1,294 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package wasm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/kube-scheduler-wasm-extension/scheduler/test"
+)
+
+func Test_parseOCIReference(t *testing.T) {
+	digest := contentHashPrefix + strings.Repeat("a", 64)
+	tests := []struct {
+		url           string
+		expected      ociReference
+		expectedError string
+	}{
+		{
+			url:      "oci://ghcr.io/org/plugin:v1",
+			expected: ociReference{registry: "ghcr.io", repository: "org/plugin", tag: "v1"},
+		},
+		{
+			url:      "oci://localhost:5000/plugin",
+			expected: ociReference{registry: "localhost:5000", repository: "plugin", tag: "latest"},
+		},
+		{
+			url:      "oci://ghcr.io/org/plugin:v1@" + digest,
+			expected: ociReference{registry: "ghcr.io", repository: "org/plugin", tag: "v1", digest: digest},
+		},
+		{
+			url:      "oci://ghcr.io/org/plugin@" + digest,
+			expected: ociReference{registry: "ghcr.io", repository: "org/plugin", digest: digest},
+		},
+		{
+			url:      "oci://docker.io/plugin:v1",
+			expected: ociReference{registry: "registry-1.docker.io", repository: "library/plugin", tag: "v1"},
+		},
+		{
+			url:           "oci://plugin:v1",
+			expectedError: "invalid OCI reference oci://plugin:v1: missing registry or repository",
+		},
+		{
+			url:           "oci://ghcr.io/plugin:",
+			expectedError: "invalid OCI reference oci://ghcr.io/plugin:: empty tag",
+		},
+		{
+			url:           "oci://ghcr.io/plugin@sha256:abc",
+			expectedError: `invalid OCI reference oci://ghcr.io/plugin@sha256:abc: unsupported digest "sha256:abc"`,
+		},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.url, func(t *testing.T) {
+			ref, err := parseOCIReference(tt.url)
+			if tt.expectedError != "" {
+				if err == nil || err.Error() != tt.expectedError {
+					t.Fatalf("expected error %q, have %v", tt.expectedError, err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if ref != tt.expected {
+				t.Fatalf("unexpected reference: want %+v, have %+v", tt.expected, ref)
+			}
+		})
+	}
+}
+
+// fakeRegistry is an in-process stand-in for an OCI registry, serving one
+// repository.
+type fakeRegistry struct {
+	*httptest.Server
+	repository string
+	manifests  map[string][]byte
+	blobs      map[string][]byte
+
+	// username and password, if set, are required to get a bearer token.
+	username, password string
+	// requests counts requests to the distribution API.
+	requests int
+}
+
+const fakeRegistryToken = "token"
+
+func newFakeRegistry(t *testing.T, repository string) *fakeRegistry {
+	r := &fakeRegistry{repository: repository, manifests: map[string][]byte{}, blobs: map[string][]byte{}}
+	r.Server = httptest.NewTLSServer(http.HandlerFunc(r.serveHTTP))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// push adds an artifact with a layer of the media type, tagged with the tag,
+// returning the digest of its manifest.
+func (r *fakeRegistry) push(tag, mediaType string, layer []byte) string {
+	layerDigest := contentHash(layer)
+	r.blobs[layerDigest] = layer
+	manifest, _ := json.Marshal(map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     mediaTypeOCIManifest,
+		"layers": []ociDescriptor{
+			{MediaType: mediaType, Digest: layerDigest, Size: int64(len(layer))},
+		},
+	})
+	digest := contentHash(manifest)
+	r.manifests[tag] = manifest
+	r.manifests[digest] = manifest
+	return digest
+}
+
+func (r *fakeRegistry) url(reference string) string {
+	return "oci://" + r.Listener.Addr().String() + "/" + r.repository + reference
+}
+
+func (r *fakeRegistry) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/token" {
+		if username, password, _ := req.BasicAuth(); username != r.username || password != r.password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": fakeRegistryToken})
+		return
+	}
+
+	r.requests++
+	if r.username != "" && req.Header.Get("Authorization") != "Bearer "+fakeRegistryToken {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="`+r.URL+`/token",service="fake"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	prefix := "/v2/" + r.repository + "/"
+	var content []byte
+	if reference, ok := strings.CutPrefix(req.URL.Path, prefix+"manifests/"); ok {
+		content = r.manifests[reference]
+		w.Header().Set("Content-Type", mediaTypeOCIManifest)
+	} else if digest, ok := strings.CutPrefix(req.URL.Path, prefix+"blobs/"); ok {
+		content = r.blobs[digest]
+	}
+	if content == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	_, _ = w.Write(content)
+}
+
+func Test_guestFetcher_ociPullIfChanged(t *testing.T) {
+	guestBin, err := os.ReadFile(test.URLTestFilterFromGlobal[7:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("anonymous", func(t *testing.T) {
+		r := newFakeRegistry(t, "org/plugin")
+		digest := r.push("v1", "application/vnd.wasm.content.layer.v1+wasm", guestBin)
+		f := &guestFetcher{client: r.Client()}
+
+		bin, version, err := f.getIfChanged(ctx, r.url(":v1"), "")
+		if err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(guestBin, bin) {
+			t.Fatal("unexpected guest")
+		} else if version != digest {
+			t.Fatalf("unexpected version: want %s, have %s", digest, version)
+		}
+
+		// The guest isn't pulled again when the tag is at the same digest.
+		requests := r.requests
+		if bin, have, err := f.getIfChanged(ctx, r.url(":v1"), version); err != nil {
+			t.Fatal(err)
+		} else if bin != nil || have != version {
+			t.Fatalf("expected no change, have version %s", have)
+		} else if want, have := requests+1, r.requests; want != have {
+			t.Fatalf("unexpected requests: want %d, have %d", want, have)
+		}
+	})
+
+	t.Run("docker config credentials", func(t *testing.T) {
+		r := newFakeRegistry(t, "plugin")
+		r.username, r.password = "user", "pass"
+		r.push("latest", "application/vnd.module.wasm.content.layer.v1+wasm", guestBin)
+		f := &guestFetcher{client: r.Client()}
+
+		// Without credentials, the token request is unauthorized.
+		t.Setenv("DOCKER_CONFIG", t.TempDir())
+		if _, _, err := f.getIfChanged(ctx, r.url(""), ""); err == nil || !strings.Contains(err.Error(), "401") {
+			t.Fatalf("expected unauthorized error, have %v", err)
+		}
+
+		dockerConfig := t.TempDir()
+		auth := base64.StdEncoding.EncodeToString([]byte("user:pass"))
+		config := `{"auths":{"https://` + r.Listener.Addr().String() + `/v2/":{"auth":"` + auth + `"}}}`
+		if err := os.WriteFile(path.Join(dockerConfig, "config.json"), []byte(config), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("DOCKER_CONFIG", dockerConfig)
+		if bin, _, err := f.getIfChanged(ctx, r.url(""), ""); err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(guestBin, bin) {
+			t.Fatal("unexpected guest")
+		}
+	})
+
+	t.Run("cached by digest", func(t *testing.T) {
+		r := newFakeRegistry(t, "plugin")
+		digest := r.push("v1", "application/wasm", guestBin)
+		f := &guestFetcher{client: r.Client(), cacheDir: t.TempDir()}
+		url := r.url(":v1@" + digest)
+
+		if _, _, err := f.getIfChanged(ctx, url, ""); err != nil {
+			t.Fatal(err)
+		}
+
+		// The pinned guest can be read when the registry is down.
+		r.Close()
+		if bin, version, err := f.getIfChanged(ctx, url, ""); err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(guestBin, bin) {
+			t.Fatal("unexpected guest")
+		} else if version != digest {
+			t.Fatalf("unexpected version: want %s, have %s", digest, version)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		r := newFakeRegistry(t, "plugin")
+		digest := r.push("v1", "application/wasm", guestBin)
+		r.push("image", "application/vnd.oci.image.layer.v1.tar+gzip", guestBin)
+		wrongDigest := contentHashPrefix + strings.Repeat("0", 64)
+		r.manifests[wrongDigest] = r.manifests["v1"]
+		f := &guestFetcher{client: r.Client()}
+
+		tests := []struct {
+			reference     string
+			expectedError string
+		}{
+			{
+				reference:     ":missing",
+				expectedError: "received 404 status code",
+			},
+			{
+				reference:     ":image",
+				expectedError: "no wasm layer in OCI manifest in " + r.url(":image"),
+			},
+			{
+				reference:     "@" + wrongDigest,
+				expectedError: "digest mismatch: expected " + wrongDigest + ", have " + digest,
+			},
+		}
+		for _, tc := range tests {
+			if _, _, err := f.getIfChanged(ctx, r.url(tc.reference), ""); err == nil || !strings.Contains(err.Error(), tc.expectedError) {
+				t.Errorf("%s: expected error to contain %q, have %v", tc.reference, tc.expectedError, err)
+			}
+		}
+	})
+}
+
+func Test_parseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:a/b:pull,push"`)
+	if scheme != "Bearer" {
+		t.Fatalf("unexpected scheme: %s", scheme)
+	}
+	expected := map[string]string{
+		"realm":   "https://auth.example.com/token",
+		"service": "registry.example.com",
+		"scope":   "repository:a/b:pull,push",
+	}
+	for k, want := range expected {
+		if have := params[k]; want != have {
+			t.Errorf("unexpected %s: want %s, have %s", k, want, have)
+		}
+	}
+}
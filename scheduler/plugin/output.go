@@ -0,0 +1,223 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package wasm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"golang.org/x/time/rate"
+	"k8s.io/klog/v2"
+)
+
+// Defaults of GuestOutputConfig.
+const (
+	defaultOutputLineLimit      = 4096
+	defaultOutputTailLimit      = 4096
+	defaultOutputLinesPerSecond = 100
+)
+
+// guestOutputConfig is the parsed GuestOutputConfig, shared by all guests of
+// a plugin.
+type guestOutputConfig struct {
+	lineLimit, tailLimit int
+
+	// limiter bounds the rate of lines logged by all guests of the plugin.
+	limiter *rate.Limiter
+}
+
+// parseGuestOutputConfig validates the GuestOutputConfig and applies its
+// defaults.
+func parseGuestOutputConfig(config GuestOutputConfig) (guestOutputConfig, error) {
+	c := guestOutputConfig{lineLimit: config.LineLimit, tailLimit: config.TailLimit}
+	if c.lineLimit < 0 || c.tailLimit < 0 || config.LinesPerSecond < 0 || config.Burst < 0 {
+		return c, fmt.Errorf("wasm: guestOutput limits must not be negative")
+	}
+	if c.lineLimit == 0 {
+		c.lineLimit = defaultOutputLineLimit
+	}
+	if c.tailLimit == 0 {
+		c.tailLimit = defaultOutputTailLimit
+	}
+
+	linesPerSecond := config.LinesPerSecond
+	if linesPerSecond == 0 {
+		linesPerSecond = defaultOutputLinesPerSecond
+	}
+	burst := config.Burst
+	if burst == 0 {
+		burst = int(linesPerSecond + 0.5)
+		if burst < 1 {
+			burst = 1
+		}
+	}
+	c.limiter = rate.NewLimiter(rate.Limit(linesPerSecond), burst)
+	return c, nil
+}
+
+// guestOutput captures the stdout and stderr of a guest. Each line is logged
+// as it is written, and the tail of the output of the current call is kept to
+// decorate any error it returns.
+//
+// A guest is only used by one goroutine at a time, so this isn't safe for
+// concurrent use.
+type guestOutput struct {
+	config     guestOutputConfig
+	pluginName string
+
+	// ctx and name are of the current call, set by begin. logger is tagged
+	// with them, only when the call has output, as most don't.
+	ctx    context.Context
+	name   string
+	logger *klog.Logger
+
+	stdout, stderr outputStream
+
+	// tail is the recent output of the current call, cleared by Reset.
+	tail bytes.Buffer
+
+	// dropped is the count of lines not logged due to the rate limit.
+	dropped int
+}
+
+func newGuestOutput(pluginName string, config guestOutputConfig) *guestOutput {
+	o := &guestOutput{config: config, pluginName: pluginName, ctx: context.Background()}
+	o.stdout = outputStream{out: o, name: "stdout"}
+	o.stderr = outputStream{out: o, name: "stderr"}
+	return o
+}
+
+// begin tags output with the guest export name, and the pod and node in the
+// stack of the context, if any, until the next call to begin.
+func (o *guestOutput) begin(ctx context.Context, name string) {
+	o.ctx, o.name, o.logger = ctx, name, nil
+}
+
+// getLogger returns the logger tagged with the current call.
+func (o *guestOutput) getLogger() klog.Logger {
+	if o.logger != nil {
+		return *o.logger
+	}
+	kvs := []any{"plugin", o.pluginName, "extensionPoint", o.name}
+	if params, ok := o.ctx.Value(stackKey{}).(*stack); ok {
+		if pod := params.currentPod; pod != nil {
+			kvs = append(kvs, "pod", klog.KObj(pod))
+		}
+		if nodeName := params.currentNodeName; nodeName != "" {
+			kvs = append(kvs, "node", nodeName)
+		}
+	}
+	logger := klog.FromContext(o.ctx).WithValues(kvs...)
+	o.logger = &logger
+	return logger
+}
+
+// flush logs any partial lines, for example at the end of a call.
+func (o *guestOutput) flush() {
+	o.stdout.flush()
+	o.stderr.flush()
+}
+
+// String returns the tail of the output since the last Reset.
+func (o *guestOutput) String() string {
+	b := o.tail.Bytes()
+	if len(b) > o.config.tailLimit {
+		b = b[len(b)-o.config.tailLimit:]
+	}
+	return string(b)
+}
+
+// Reset clears the tail, so that a following call starts with none.
+func (o *guestOutput) Reset() {
+	o.tail.Reset()
+}
+
+// appendTail appends to the tail, discarding the start of it when it grows
+// too large.
+func (o *guestOutput) appendTail(p []byte) {
+	o.tail.Write(p)
+	if limit := o.config.tailLimit; o.tail.Len() > 2*limit {
+		keep := append([]byte(nil), o.tail.Bytes()[o.tail.Len()-limit:]...)
+		o.tail.Reset()
+		o.tail.Write(keep)
+	}
+}
+
+// log logs the line of the stream, unless over the rate limit.
+func (o *guestOutput) log(stream string, line []byte) {
+	if !o.config.limiter.Allow() {
+		o.dropped++
+		return
+	}
+	logger := o.getLogger()
+	if o.dropped > 0 {
+		logger.Info("Dropped guest output over the rate limit", "lines", o.dropped)
+		o.dropped = 0
+	}
+	logger.Info("Guest output", "stream", stream, "line", string(line))
+}
+
+// outputStream is stdout or stderr of a guest, which logs each line.
+type outputStream struct {
+	out  *guestOutput
+	name string
+
+	// line is a partial line, up to the line limit.
+	line []byte
+	// truncated is true when the partial line exceeded the line limit.
+	truncated bool
+}
+
+// Write implements the same method as documented on io.Writer.
+func (s *outputStream) Write(p []byte) (int, error) {
+	s.out.appendTail(p)
+	for rest := p; len(rest) > 0; {
+		chunk := rest
+		i := bytes.IndexByte(rest, '\n')
+		if i != -1 {
+			chunk, rest = rest[:i], rest[i+1:]
+		} else {
+			rest = nil
+		}
+		s.append(chunk)
+		if i != -1 {
+			s.flush()
+		}
+	}
+	return len(p), nil
+}
+
+// append appends to the partial line, truncating it at the line limit.
+func (s *outputStream) append(p []byte) {
+	if room := s.out.config.lineLimit - len(s.line); len(p) > room {
+		p, s.truncated = p[:room], true
+	}
+	s.line = append(s.line, p...)
+}
+
+// flush logs the partial line, if any.
+func (s *outputStream) flush() {
+	if len(s.line) == 0 && !s.truncated {
+		return
+	}
+	if s.truncated {
+		s.line = append(s.line, "..."...)
+	}
+	s.out.log(s.name, s.line)
+	s.line, s.truncated = s.line[:0], false
+}
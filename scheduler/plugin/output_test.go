@@ -0,0 +1,150 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wasm
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/klog/v2"
+	"k8s.io/klog/v2/ktesting"
+
+	"sigs.k8s.io/kube-scheduler-wasm-extension/scheduler/test"
+)
+
+func Test_guestOutput(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       GuestOutputConfig
+		stdout       []string
+		stderr       []string
+		expectedLogs []string
+		expectedTail string
+	}{
+		{
+			name:         "lines",
+			stdout:       []string{"hello\nwor", "ld\n", "partial"},
+			expectedLogs: []string{`line="hello"`, `line="world"`, `line="partial"`},
+			expectedTail: "hello\nworld\npartial",
+		},
+		{
+			name:         "stderr",
+			stderr:       []string{"panic!\n"},
+			expectedLogs: []string{`stream="stderr" line="panic!"`},
+			expectedTail: "panic!\n",
+		},
+		{
+			name:         "line limit",
+			config:       GuestOutputConfig{LineLimit: 4},
+			stdout:       []string{"hello\n", "a", "bcdef\n"},
+			expectedLogs: []string{`line="hell..."`, `line="abcd..."`},
+			expectedTail: "hello\nabcdef\n",
+		},
+		{
+			name:         "tail limit",
+			config:       GuestOutputConfig{TailLimit: 4},
+			stdout:       []string{"hello\n", "world\n"},
+			expectedLogs: []string{`line="hello"`, `line="world"`},
+			expectedTail: "rld\n",
+		},
+		{
+			name:         "rate limit",
+			config:       GuestOutputConfig{LinesPerSecond: 0.001, Burst: 1},
+			stdout:       []string{"one\ntwo\nthree\n"},
+			expectedLogs: []string{`line="one"`},
+			expectedTail: "one\ntwo\nthree\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, logs := newBufferedLogger(t)
+			config, err := parseGuestOutputConfig(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pod := test.PodSmall
+			out := newGuestOutput("test", config)
+			out.begin(context.WithValue(ctx, stackKey{}, &stack{currentPod: pod, currentNodeName: "node1"}), guestExportFilter)
+			for _, s := range tc.stdout {
+				_, _ = out.stdout.Write([]byte(s))
+			}
+			for _, s := range tc.stderr {
+				_, _ = out.stderr.Write([]byte(s))
+			}
+			out.flush()
+
+			if want, have := len(tc.expectedLogs), strings.Count(logs(), "Guest output"); want != have {
+				t.Fatalf("unexpected count of lines logged: want %d, have %d\n%s", want, have, logs())
+			}
+			for _, want := range tc.expectedLogs {
+				if !strings.Contains(logs(), want) {
+					t.Errorf("expected logs to contain %s, have %s", want, logs())
+				}
+			}
+			for _, want := range []string{`plugin="test"`, `extensionPoint="filter"`, `pod="` + pod.Namespace + "/" + pod.Name + `"`, `node="node1"`} {
+				if !strings.Contains(logs(), want) {
+					t.Errorf("expected logs to contain %s, have %s", want, logs())
+				}
+			}
+			if want, have := tc.expectedTail, out.String(); want != have {
+				t.Fatalf("unexpected tail: want %q, have %q", want, have)
+			}
+			out.Reset()
+			if have := out.String(); have != "" {
+				t.Fatalf("expected no tail after reset, have %q", have)
+			}
+		})
+	}
+}
+
+func Test_guestOutput_dropped(t *testing.T) {
+	ctx, logs := newBufferedLogger(t)
+	config, err := parseGuestOutputConfig(GuestOutputConfig{LinesPerSecond: 1000, Burst: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := newGuestOutput("test", config)
+	out.begin(ctx, guestExportFilter)
+	_, _ = out.stdout.Write([]byte("one\ntwo\n"))
+
+	// The count of dropped lines is logged with the next allowed line.
+	time.Sleep(5 * time.Millisecond)
+	_, _ = out.stdout.Write([]byte("three\n"))
+
+	if want := `Dropped guest output over the rate limit plugin="test" extensionPoint="filter" lines=1`; !strings.Contains(logs(), want) {
+		t.Fatalf("expected logs to contain %s, have %s", want, logs())
+	}
+}
+
+func Test_parseGuestOutputConfig(t *testing.T) {
+	if _, err := parseGuestOutputConfig(GuestOutputConfig{LineLimit: -1}); err == nil {
+		t.Fatal("expected an error for a negative limit")
+	}
+}
+
+// newBufferedLogger returns a context with a logger which writes to the test
+// output, and a function returning what was logged.
+func newBufferedLogger(t *testing.T) (context.Context, func() string) {
+	logger := ktesting.NewLogger(t, ktesting.NewConfig(ktesting.BufferLogs(true)))
+	logs := func() string {
+		return logger.GetSink().(ktesting.Underlier).GetBuffer().String()
+	}
+	return klog.NewContext(context.Background(), logger), logs
+}
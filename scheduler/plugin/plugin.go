@@ -20,6 +20,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -27,8 +28,10 @@ import (
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/klog/v2"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
+	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/queuesort"
 	frameworkruntime "k8s.io/kubernetes/pkg/scheduler/framework/runtime"
 )
 
@@ -45,39 +48,96 @@ func PluginFactory(pluginName string) frameworkruntime.PluginFactory {
 // NewFromConfig is like New, except it allows us to explicitly provide the
 // context and configuration of the plugin. This allows flexibility in tests.
 func NewFromConfig(ctx context.Context, pluginName string, config PluginConfig, frameworkHandle framework.Handle) (framework.Plugin, error) {
+	RegisterMetrics()
+
 	url := config.GuestURL
 	if url == "" {
 		return nil, errors.New("wasm: guestURL is required")
 	}
-	guestBin, err := getURL(ctx, url)
+	verifier, err := newGuestVerifier(config)
+	if err != nil {
+		return nil, err
+	}
+	fetcher, err := newGuestFetcher(config, frameworkHandle)
+	if err != nil {
+		return nil, err
+	}
+	guestBin, guestVersion, err := fetcher.getIfChanged(ctx, url, "")
 	if err != nil {
 		return nil, fmt.Errorf("wasm: error reading guestURL %s: %w", url, err)
 	}
+	var configVersion string
+	if configURL := config.GuestConfigURL; configURL != "" {
+		if config.GuestConfig != "" {
+			return nil, errors.New("wasm: guestConfig and guestConfigURL are mutually exclusive")
+		}
+		var guestConfig []byte
+		if guestConfig, configVersion, err = fetcher.getIfChanged(ctx, configURL, ""); err != nil {
+			return nil, fmt.Errorf("wasm: error reading guestConfigURL %s: %w", configURL, err)
+		}
+		config.GuestConfig = string(guestConfig)
+	}
+	if err = verifier.verify(ctx, fetcher, guestBin); err != nil {
+		return nil, err
+	}
 
-	runtime, guestModule, err := prepareRuntime(ctx, guestBin, config.LogSeverity, config.GuestConfig, frameworkHandle)
+	var informers *informerListers
+	if len(config.Informers) > 0 {
+		newDynamicClient := func() (dynamic.Interface, error) {
+			return dynamic.NewForConfig(frameworkHandle.KubeConfig())
+		}
+		if informers, err = newInformerListers(ctx, config.Informers, frameworkHandle.SharedInformerFactory(), newDynamicClient); err != nil {
+			return nil, err
+		}
+	}
+
+	// Informers must be registered before the scheduler starts them, so only
+	// do this when the guest looks up storage objects.
+	var storage *storageListers
+	newStorage := func() (*storageListers, error) {
+		if frameworkHandle != nil {
+			storage = newStorageListers(frameworkHandle.SharedInformerFactory())
+		}
+		return storage, nil
+	}
+	runtime, guestModule, err := prepareRuntime(ctx, guestBin, config, frameworkHandle, newStorage, informers)
 	if err != nil {
+		informers.close()
 		return nil, err
 	}
 
-	pl, err := newWasmPlugin(ctx, pluginName, runtime, guestModule, config)
+	pl, err := newWasmPlugin(ctx, pluginName, runtime, guestModule, config, frameworkHandle)
 	if err != nil {
 		_ = runtime.Close(ctx)
+		informers.close()
 		return nil, err
 	}
+	pl.informers = informers
+	pl.storage = storage
 
 	// The scheduler framework uses type assertions, so mask based on what
 	// the guest exports.
-	if pl, err := maskInterfaces(pl); err != nil {
-		_ = runtime.Close(ctx)
+	masked, err := maskInterfaces(pl)
+	if err != nil {
+		_ = pl.Close()
 		return nil, err
-	} else {
-		return pl, nil
 	}
+
+	pl.verifier = verifier
+	pl.guestConfig = config.GuestConfig
+	if config.GuestConfigURL != "" {
+		pl.guestBin = guestBin
+	}
+
+	if interval := config.ReloadInterval.Duration; interval > 0 {
+		pl.startReloading(ctx, fetcher, interval, guestVersion, configVersion)
+	}
+	return masked, nil
 }
 
 // newWasmPlugin is extracted to prevent small bugs: The caller must close the
 // wazero.Runtime to avoid leaking mmapped files.
-func newWasmPlugin(ctx context.Context, pluginName string, runtime wazero.Runtime, guestModule wazero.CompiledModule, config PluginConfig) (*wasmPlugin, error) {
+func newWasmPlugin(ctx context.Context, pluginName string, runtime wazero.Runtime, guestModule wazero.CompiledModule, config PluginConfig, handle framework.Handle) (*wasmPlugin, error) {
 	var guestInterfaces interfaces
 	var err error
 	if guestInterfaces, err = detectInterfaces(guestModule.ExportedFunctions()); err != nil {
@@ -86,30 +146,89 @@ func newWasmPlugin(ctx context.Context, pluginName string, runtime wazero.Runtim
 		return nil, fmt.Errorf("wasm: guest doesn't export plugin functions")
 	}
 
+	guestTimeouts, err := parseTimeouts(config.Timeouts)
+	if err != nil {
+		return nil, err
+	}
+
+	poolConfig, err := parsePoolConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	poolConfig.reportSize = reportPoolSize(pluginName)
+
+	outputConfig, err := parseGuestOutputConfig(config.GuestOutput)
+	if err != nil {
+		return nil, err
+	}
+
 	pl := &wasmPlugin{
 		pluginName:        pluginName,
-		runtime:           runtime,
-		guestModule:       guestModule,
+		config:            config,
+		handle:            handle,
 		guestArgs:         config.Args,
 		guestInterfaces:   guestInterfaces,
 		guestModuleConfig: wazero.NewModuleConfig(),
+		guestTimeouts:     guestTimeouts,
+		guestMemoryLimit:  config.MemoryLimitPages,
 		instanceCounter:   atomic.Uint64{},
+		poolConfig:        poolConfig,
+		outputConfig:      outputConfig,
 	}
-	if pl.pool, err = newGuestPool(ctx, pl.newGuest); err != nil {
-		return nil, fmt.Errorf("failed to create a guest pool: %w", err)
+	if pl.gen, err = pl.newGeneration(ctx, runtime, guestModule); err != nil {
+		return nil, err
 	}
 	return pl, nil
 }
 
 type wasmPlugin struct {
 	pluginName        string
-	runtime           wazero.Runtime
-	guestModule       wazero.CompiledModule
+	config            PluginConfig
+	handle            framework.Handle
 	guestInterfaces   interfaces
+	cycleStateIDs     atomic.Uint32
 	guestModuleConfig wazero.ModuleConfig
+	guestTimeouts     map[string]time.Duration
+	guestMemoryLimit  uint32
 	instanceCounter   atomic.Uint64
-	pool              *guestPool[*guest]
+	poolConfig        guestPoolConfig
+	outputConfig      guestOutputConfig
 	guestArgs         []string
+
+	// genMux guards gen and retired.
+	genMux sync.Mutex
+	// gen is the current generation of the guest, used by new scheduling
+	// cycles.
+	gen *generation
+	// retired are generations replaced by reloading the guest. Each is closed
+	// once it has no cycles in progress.
+	retired []*generation
+
+	// eventsMux guards events.
+	eventsMux sync.Mutex
+	// events are the cluster events returned by the guest from
+	// EventsToRegister, or nil before it is called. The scheduler registers
+	// them once, so a reloaded guest must return the same ones.
+	events *registeredEvents
+
+	// verifier, if set, checks a reloaded guest before it is compiled.
+	verifier *guestVerifier
+
+	// guestConfig is the configuration given to the current generation. When
+	// GuestConfigURL is set, guestBin is its guest, to reload it with a
+	// changed configuration. These are only used by the reload goroutine.
+	guestConfig string
+	guestBin    []byte
+
+	// stopReloading stops reloading the guest, if reloadInterval was set.
+	stopReloading context.CancelFunc
+
+	// informers are the listers of PluginConfig.Informers, if set.
+	informers *informerListers
+
+	// storage are the listers of storage objects, if the guest looked them up
+	// when the plugin was created.
+	storage *storageListers
 }
 
 // ProfilerSupport exposes functions needed to profile the guest with wzprof.
@@ -119,7 +238,7 @@ type ProfilerSupport interface {
 }
 
 func (pl *wasmPlugin) Guest() wazero.CompiledModule {
-	return pl.guestModule
+	return pl.current().guestModule
 }
 
 func (pl *wasmPlugin) plugin() *wasmPlugin {
@@ -154,36 +273,132 @@ func (pl *wasmPlugin) EventsToRegister(ctx context.Context) (clusterEvents []fra
 		return allClusterEvents, nil // unimplemented
 	}
 
-	// We currently don't support QueueingHintFn, so we don't expect it from
-	// the guest. Instead, we convert the events to events with hints.
-	// We could support it in the future, but the hint will be difficult
-	// to implement. If we do, we may need to make a generic guest export
-	// QueueingHintFn which has another parameter of the an funcID which
-	// substitutes for event.QueueingHintFn and passed later to the generic
-	// guest export. There will be other concerns as the parameters to
-	// QueueingHintFn are `interface{}` so probably need to be narrowed to
-	// support at all.
-
 	// Add the stack to the go context so that the corresponding host function
 	// can look them up.
 	params := &stack{}
 	ctx = context.WithValue(ctx, stackKey{}, params)
 	clusterEvents = allClusterEvents // On any problem fallback to default
 
+	pl.eventsMux.Lock()
+	defer pl.eventsMux.Unlock()
+
 	// Enqueue is not a part of the scheduling cycle.
 	// Note: there's no error return from EventsToRegister()
-	if err := pl.pool.doWithGuest(ctx, func(g *guest) {
-		// Only override the default cluster events if at least one was
-		// returned from the guest
-		if ce := g.eventsToRegister(ctx); len(ce) != 0 {
-			clusterEventsWithHints := make([]framework.ClusterEventWithHint, len(ce))
-			for i, e := range ce {
-				clusterEventsWithHints[i] = framework.ClusterEventWithHint{Event: e}
+	var events *registeredEvents
+	var guestErr error
+	if err := pl.current().pool.doWithGuest(ctx, func(g *guest) {
+		events, guestErr = g.registeredEvents(ctx)
+	}); err != nil {
+		panic(err)
+	} else if guestErr != nil {
+		panic(guestErr)
+	}
+	pl.events = events
+
+	// Only override the default cluster events if at least one was
+	// returned from the guest
+	if ce := events.events; len(ce) != 0 {
+		// The guest has a single export for queueing hints, so each
+		// QueueingHintFn passes the index of its event. Without the
+		// export, the scheduler always queues the pod on these events.
+		clusterEventsWithHints := make([]framework.ClusterEventWithHint, len(ce))
+		for i, e := range ce {
+			clusterEventsWithHints[i] = framework.ClusterEventWithHint{Event: e}
+			if events.hasQueueingHint {
+				clusterEventsWithHints[i].QueueingHintFn = pl.queueingHintFn(i)
 			}
-			clusterEvents = clusterEventsWithHints
 		}
+		clusterEvents = clusterEventsWithHints
+	}
+	return
+}
+
+// queueingHintFn returns a framework.QueueingHintFn which calls the guest
+// with the index of the event returned by EventsToRegister.
+func (pl *wasmPlugin) queueingHintFn(eventIndex int) framework.QueueingHintFn {
+	return func(logger klog.Logger, pod *v1.Pod, oldObj, newObj interface{}) (hint framework.QueueingHint, err error) {
+		// Add the stack to the go context so that the corresponding host
+		// function can look them up.
+		params := &stack{currentPod: pod, eventOldObject: eventObject(oldObj), eventNewObject: eventObject(newObj)}
+		ctx := context.WithValue(klog.NewContext(context.Background(), logger), stackKey{}, params)
+
+		// Hints are requested by event handlers, which run in parallel with
+		// the scheduling cycle. Use a free guest, so that its state is left
+		// alone.
+		hint = framework.Queue
+		// A reloaded guest registers the same events, with hints, or the
+		// reload is rejected. So, the index is valid for the current one.
+		if poolErr := pl.current().pool.doWithFreeGuest(ctx, func(g *guest) {
+			hint, err = g.queueingHint(ctx, eventIndex)
+		}); poolErr != nil {
+			return framework.Queue, poolErr
+		}
+		return
+	}
+}
+
+// eventObject returns the object of a cluster event, or nil if it is not a
+// type the guest can decode.
+func eventObject(obj interface{}) valueType {
+	if vt, ok := obj.(valueType); ok {
+		return vt
+	}
+	return nil
+}
+
+var _ framework.PreEnqueuePlugin = (*wasmPlugin)(nil)
+
+// PreEnqueue implements the same method as documented on
+// framework.PreEnqueuePlugin.
+func (pl *wasmPlugin) PreEnqueue(ctx context.Context, pod *v1.Pod) (status *framework.Status) {
+	// We always implement PreEnqueuePlugin, even when the guest doesn't.
+	if pl.guestInterfaces&iPreEnqueuePlugin == 0 {
+		return nil // unimplemented
+	}
+
+	// Add the stack to the go context so that the corresponding host function
+	// can look them up.
+	params := &stack{currentPod: pod}
+	ctx = context.WithValue(ctx, stackKey{}, params)
+
+	// PreEnqueue is called when a pod is added to the scheduling queue, which
+	// happens in parallel with the scheduling cycle. Use a free guest, so
+	// that its state is left alone.
+	if err := pl.current().pool.doWithFreeGuest(ctx, func(g *guest) {
+		status = g.preEnqueue(ctx)
 	}); err != nil {
-		panic(err)
+		status = framework.AsStatus(err)
+	}
+	return
+}
+
+var _ framework.QueueSortPlugin = (*wasmPlugin)(nil)
+
+// defaultQueueSort is used when the guest fails to compare pods.
+var defaultQueueSort = &queuesort.PrioritySort{}
+
+// Less implements the same method as documented on
+// framework.QueueSortPlugin.
+func (pl *wasmPlugin) Less(podInfo1, podInfo2 *framework.QueuedPodInfo) (less bool) {
+	// Add the stack to the go context so that the corresponding host function
+	// can look them up.
+	params := &stack{queuedPods: [2]*v1.Pod{podInfo1.Pod, podInfo2.Pod}}
+	ctx := context.WithValue(context.Background(), stackKey{}, params)
+
+	// Less is called by the scheduling queue, in parallel with the scheduling
+	// cycle, and often. Use the guest held for it, so that the queue doesn't
+	// wait on the pool.
+	var err error
+	if poolErr := pl.doWithSortGuest(ctx, pl.current(), func(g *guest) {
+		less, err = g.less(ctx, podInfo1, podInfo2)
+	}); poolErr != nil {
+		err = poolErr
+	}
+	if err != nil {
+		// Note: there's no error return from Less(), so fall back to the
+		// default order instead.
+		klog.Background().Error(err, "Guest failed to sort pods, using the default order", "plugin", pl.pluginName)
+		return defaultQueueSort.Less(podInfo1, podInfo2)
 	}
 	return
 }
@@ -199,9 +414,9 @@ func (pl *wasmPlugin) AddPod(ctx context.Context, state *framework.CycleState, p
 
 	// Add the stack to the go context so that the corresponding host function
 	// can look them up.
-	params := &stack{currentPod: podToSchedule, targetPod: podInfoToAdd.Pod, currentNodeName: nodeInfo.Node().Name}
+	params := &stack{cycleState: state, currentPod: podToSchedule, targetPod: podInfoToAdd.Pod, currentNodeName: nodeInfo.Node().Name, currentNodeInfo: nodeInfo}
 	ctx = context.WithValue(ctx, stackKey{}, params)
-	if err := pl.pool.doWithSchedulingGuest(ctx, podToSchedule.UID, func(g *guest) {
+	if err := pl.doWithCycleState(ctx, state, podToSchedule.UID, func(g *guest) {
 		status = g.addPod(ctx)
 	}); err != nil {
 		status = framework.AsStatus(err)
@@ -218,9 +433,9 @@ func (pl *wasmPlugin) RemovePod(ctx context.Context, state *framework.CycleState
 
 	// Add the stack to the go context so that the corresponding host function
 	// can look them up.
-	params := &stack{currentPod: podToSchedule, targetPod: podInfoToRemove.Pod, currentNodeName: nodeInfo.Node().Name}
+	params := &stack{cycleState: state, currentPod: podToSchedule, targetPod: podInfoToRemove.Pod, currentNodeName: nodeInfo.Node().Name, currentNodeInfo: nodeInfo}
 	ctx = context.WithValue(ctx, stackKey{}, params)
-	if err := pl.pool.doWithSchedulingGuest(ctx, podToSchedule.UID, func(g *guest) {
+	if err := pl.doWithCycleState(ctx, state, podToSchedule.UID, func(g *guest) {
 		status = g.removePod(ctx)
 	}); err != nil {
 		status = framework.AsStatus(err)
@@ -242,7 +457,7 @@ func (pl *wasmPlugin) PreFilterExtensions() framework.PreFilterExtensions {
 
 // PreFilter implements the same method as documented on
 // framework.PreFilterPlugin.
-func (pl *wasmPlugin) PreFilter(ctx context.Context, _ *framework.CycleState, pod *v1.Pod) (result *framework.PreFilterResult, status *framework.Status) {
+func (pl *wasmPlugin) PreFilter(ctx context.Context, state *framework.CycleState, pod *v1.Pod) (result *framework.PreFilterResult, status *framework.Status) {
 	// We implement PreFilterPlugin with FilterPlugin, even when the guest doesn't.
 	if pl.guestInterfaces&iPreFilterPlugin == 0 {
 		return nil, nil // unimplemented
@@ -250,11 +465,13 @@ func (pl *wasmPlugin) PreFilter(ctx context.Context, _ *framework.CycleState, po
 
 	// Add the stack to the go context so that the corresponding host function
 	// can look them up.
-	params := &stack{currentPod: pod}
+	params := &stack{cycleState: state, currentPod: pod}
 	ctx = context.WithValue(ctx, stackKey{}, params)
-	if err := pl.pool.doWithSchedulingGuest(ctx, pod.UID, func(g *guest) {
+	data := pl.newCycleState(state)
+	if err := pl.schedulingPool(pod.UID).doWithSchedulingGuest(ctx, pod.UID, func(g *guest) {
 		var nodeNames []string
 		nodeNames, status = g.preFilter(ctx)
+		g.resetCycleState(data)
 		if nodeNames != nil {
 			result = &framework.PreFilterResult{NodeNames: sets.New(nodeNames...)}
 		}
@@ -267,12 +484,12 @@ func (pl *wasmPlugin) PreFilter(ctx context.Context, _ *framework.CycleState, po
 var _ framework.FilterPlugin = (*wasmPlugin)(nil)
 
 // Filter implements the same method as documented on framework.FilterPlugin.
-func (pl *wasmPlugin) Filter(ctx context.Context, _ *framework.CycleState, pod *v1.Pod, nodeInfo *framework.NodeInfo) (status *framework.Status) {
+func (pl *wasmPlugin) Filter(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodeInfo *framework.NodeInfo) (status *framework.Status) {
 	// Add the stack to the go context so that the corresponding host function
 	// can look them up.
-	params := &stack{currentPod: pod, currentNodeName: nodeInfo.Node().Name}
+	params := &stack{cycleState: state, currentPod: pod, currentNodeName: nodeInfo.Node().Name, currentNodeInfo: nodeInfo}
 	ctx = context.WithValue(ctx, stackKey{}, params)
-	if err := pl.pool.doWithSchedulingGuest(ctx, pod.UID, func(g *guest) {
+	if err := pl.doWithCycleState(ctx, state, pod.UID, func(g *guest) {
 		status = g.filter(ctx)
 	}); err != nil {
 		status = framework.AsStatus(err)
@@ -291,9 +508,9 @@ func (pl *wasmPlugin) PostFilter(ctx context.Context, state *framework.CycleStat
 
 	// Add the stack to the go context so that the corresponding host function
 	// can look them up.
-	params := &stack{currentPod: pod, nodeToStatusMap: filteredNodeStatusMap}
+	params := &stack{cycleState: state, currentPod: pod, nodeToStatusMap: filteredNodeStatusMap}
 	ctx = context.WithValue(ctx, stackKey{}, params)
-	if err := pl.pool.doWithSchedulingGuest(ctx, pod.UID, func(g *guest) {
+	if err := pl.doWithCycleState(ctx, state, pod.UID, func(g *guest) {
 		result, status = g.postFilter(ctx)
 	}); err != nil {
 		status = framework.AsStatus(err)
@@ -312,9 +529,9 @@ func (pl *wasmPlugin) PreScore(ctx context.Context, state *framework.CycleState,
 
 	// Add the stack to the go context so that the corresponding host function
 	// can look them up.
-	params := &stack{currentPod: pod, filteredNodes: nodeInfoList}
+	params := &stack{cycleState: state, currentPod: pod, filteredNodes: nodeInfoList}
 	ctx = context.WithValue(ctx, stackKey{}, params)
-	if err := pl.pool.doWithSchedulingGuest(ctx, pod.UID, func(g *guest) {
+	if err := pl.doWithCycleState(ctx, state, pod.UID, func(g *guest) {
 		status = g.preScore(ctx)
 	}); err != nil {
 		status = framework.AsStatus(err)
@@ -330,10 +547,10 @@ func (pl *wasmPlugin) NormalizeScore(ctx context.Context, state *framework.Cycle
 	if pl.guestInterfaces&iScoreExtensions == 0 {
 		return nil // unimplemented
 	}
-	params := &stack{currentPod: pod, nodeScoreList: scores}
+	params := &stack{cycleState: state, currentPod: pod, nodeScoreList: scores}
 	ctx = context.WithValue(ctx, stackKey{}, params)
 	var updatedScores framework.NodeScoreList
-	if err := pl.pool.doWithSchedulingGuest(ctx, pod.UID, func(g *guest) {
+	if err := pl.doWithCycleState(ctx, state, pod.UID, func(g *guest) {
 		updatedScores, status = g.normalizeScore(ctx)
 	}); err != nil {
 		status = framework.AsStatus(err)
@@ -354,9 +571,9 @@ var _ framework.ScorePlugin = (*wasmPlugin)(nil)
 func (pl *wasmPlugin) Score(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodeInfo *framework.NodeInfo) (score int64, status *framework.Status) {
 	// Add the stack to the go context so that the corresponding host function
 	// can look them up.
-	params := &stack{currentPod: pod, currentNodeName: nodeInfo.GetName()}
+	params := &stack{cycleState: state, currentPod: pod, currentNodeName: nodeInfo.GetName(), currentNodeInfo: nodeInfo}
 	ctx = context.WithValue(ctx, stackKey{}, params)
-	if err := pl.pool.doWithSchedulingGuest(ctx, pod.UID, func(g *guest) {
+	if err := pl.doWithCycleState(ctx, state, pod.UID, func(g *guest) {
 		score, status = g.score(ctx)
 	}); err != nil {
 		status = framework.AsStatus(err)
@@ -377,9 +594,9 @@ var _ framework.ReservePlugin = (*wasmPlugin)(nil)
 
 // Reserve implements the same method as documented on framework.ReservePlugin.
 func (pl *wasmPlugin) Reserve(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodeName string) (status *framework.Status) {
-	params := &stack{currentPod: pod, currentNodeName: nodeName}
+	params := &stack{cycleState: state, currentPod: pod, currentNodeName: nodeName}
 	ctx = context.WithValue(ctx, stackKey{}, params)
-	if err := pl.pool.doWithSchedulingGuest(ctx, pod.UID, func(g *guest) {
+	if err := pl.doWithCycleState(ctx, state, pod.UID, func(g *guest) {
 		status = g.reserve(ctx)
 	}); err != nil {
 		status = framework.AsStatus(err)
@@ -389,12 +606,12 @@ func (pl *wasmPlugin) Reserve(ctx context.Context, state *framework.CycleState,
 
 // Unreserve implements the same method as documented on framework.ReservePlugin.
 func (pl *wasmPlugin) Unreserve(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodeName string) {
-	defer pl.pool.freeFromBinding(pod.UID) // the cycle is over, put it back into the pool.
+	defer pl.freeFromBinding(pod.UID) // the cycle is over, put it back into the pool.
 
-	params := &stack{currentPod: pod, currentNodeName: nodeName}
+	params := &stack{cycleState: state, currentPod: pod, currentNodeName: nodeName}
 	ctx = context.WithValue(ctx, stackKey{}, params)
 	logger := klog.FromContext(ctx)
-	if err := pl.pool.doWithSchedulingGuest(ctx, pod.UID, func(g *guest) {
+	if err := pl.doWithCycleState(ctx, state, pod.UID, func(g *guest) {
 		g.unreserve(ctx)
 	}); err != nil {
 		logger.Error(err, "doWithSchedulingGuest Failed")
@@ -412,9 +629,12 @@ func (pl *wasmPlugin) PreBind(ctx context.Context, state *framework.CycleState,
 
 	// Add the stack to the go context so that the corresponding host function
 	// can look them up.
-	params := &stack{currentPod: pod, currentNodeName: nodeName}
+	params := &stack{cycleState: state, currentPod: pod, currentNodeName: nodeName}
 	ctx = context.WithValue(ctx, stackKey{}, params)
-	g := pl.pool.getForBinding(pod.UID)
+	g, err := pl.bindingPool(pod.UID).getForBinding(ctx, pod.UID)
+	if err != nil {
+		return framework.AsStatus(err)
+	}
 	status = g.preBind(ctx)
 	return
 }
@@ -428,10 +648,15 @@ func (pl *wasmPlugin) PostBind(ctx context.Context, state *framework.CycleState,
 		return // unimplemented
 	}
 
-	defer pl.pool.freeFromBinding(pod.UID) // the cycle is over, put it back into the pool.
-	params := &stack{currentPod: pod, currentNodeName: nodeName}
+	defer pl.freeFromBinding(pod.UID) // the cycle is over, put it back into the pool.
+	params := &stack{cycleState: state, currentPod: pod, currentNodeName: nodeName}
 	ctx = context.WithValue(ctx, stackKey{}, params)
-	g := pl.pool.getForBinding(pod.UID)
+	logger := klog.FromContext(ctx)
+	g, err := pl.bindingPool(pod.UID).getForBinding(ctx, pod.UID)
+	if err != nil {
+		logger.Error(err, "getForBinding Failed")
+		return
+	}
 	g.postBind(ctx)
 }
 
@@ -439,14 +664,20 @@ var _ framework.PermitPlugin = (*wasmPlugin)(nil)
 
 // Permit implements the same method as documented on framework.PermitPlugin.
 func (pl *wasmPlugin) Permit(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodeName string) (status *framework.Status, timeout time.Duration) {
-	params := &stack{currentPod: pod, currentNodeName: nodeName}
+	params := &stack{cycleState: state, currentPod: pod, currentNodeName: nodeName}
 	ctx = context.WithValue(ctx, stackKey{}, params)
-	if err := pl.pool.doWithSchedulingGuest(ctx, pod.UID, func(g *guest) {
+	pool := pl.schedulingPool(pod.UID)
+	if err := pl.doWithCycleState(ctx, state, pod.UID, func(g *guest) {
 		status, timeout = g.permit(ctx)
 	}); err != nil {
 		status = framework.AsStatus(err)
 	}
-	_ = pl.pool.getForBinding(pod.UID)
+
+	// Associate a guest with the binding cycle, even if the one used for
+	// scheduling was closed.
+	if _, err := pool.getForBinding(ctx, pod.UID); err != nil && status.IsSuccess() {
+		status = framework.AsStatus(err)
+	}
 	return
 }
 
@@ -456,18 +687,32 @@ var _ framework.BindPlugin = (*wasmPlugin)(nil)
 func (pl *wasmPlugin) Bind(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodeName string) (status *framework.Status) {
 	// Add the stack to the go context so that the corresponding host function
 	// can look them up.
-	params := &stack{currentPod: pod, currentNodeName: nodeName}
+	params := &stack{cycleState: state, currentPod: pod, currentNodeName: nodeName}
 	ctx = context.WithValue(ctx, stackKey{}, params)
-	g := pl.pool.getForBinding(pod.UID)
+	g, err := pl.bindingPool(pod.UID).getForBinding(ctx, pod.UID)
+	if err != nil {
+		return framework.AsStatus(err)
+	}
 	status = g.bind(ctx)
 	return
 }
 
 // Close implements io.Closer
 func (pl *wasmPlugin) Close() error {
-	// wazero's runtime closes everything.
-	if rt := pl.runtime; rt != nil {
-		return rt.Close(context.Background())
+	if stop := pl.stopReloading; stop != nil {
+		stop()
+	}
+	pl.informers.close()
+
+	pl.genMux.Lock()
+	defer pl.genMux.Unlock()
+
+	for _, gen := range pl.retired {
+		_ = gen.close()
+	}
+	pl.retired = nil
+	if gen := pl.gen; gen != nil {
+		return gen.close()
 	}
 	return nil
 }
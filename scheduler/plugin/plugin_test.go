@@ -28,6 +28,7 @@ import (
 	"os"
 	"path"
 	"reflect"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -39,6 +40,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
 	st "k8s.io/kubernetes/pkg/scheduler/testing"
 
@@ -283,6 +285,10 @@ func TestNewFromConfig(t *testing.T) {
 	type testcase struct {
 		name          string
 		guestURL      string
+		timeouts      map[string]metav1.Duration
+		memoryLimit   uint32
+		poolMinSize   int
+		poolMaxSize   int
 		expectedError string
 	}
 	tests := []testcase{
@@ -316,11 +322,81 @@ module[1] function[_start] failed: wasm error: unreachable
 wasm stack trace:
 	panic_on_start.$2()`,
 		},
+		{
+			name:     "valid timeout",
+			guestURL: test.URLTestFilter,
+			timeouts: map[string]metav1.Duration{"filter": {Duration: time.Second}},
+		},
+		{
+			name:          "timeout: unknown extension point",
+			guestURL:      test.URLTestFilter,
+			timeouts:      map[string]metav1.Duration{"Filter": {Duration: time.Second}},
+			expectedError: `wasm: invalid timeout for unknown extension point "Filter"`,
+		},
+		{
+			name:          "timeout: enqueue",
+			guestURL:      test.URLTestFilter,
+			timeouts:      map[string]metav1.Duration{"enqueue": {Duration: time.Second}},
+			expectedError: `wasm: invalid timeout for enqueue: extension point cannot be bounded`,
+		},
+		{
+			name:          "timeout: not positive",
+			guestURL:      test.URLTestFilter,
+			timeouts:      map[string]metav1.Duration{"filter": {}},
+			expectedError: `wasm: invalid timeout for filter: 0s must be positive`,
+		},
+		{
+			name:        "valid memory limit",
+			guestURL:    test.URLErrorOOMOnFilter,
+			memoryLimit: 2,
+		},
+		{
+			name:          "memory limit: too large",
+			guestURL:      test.URLErrorOOMOnFilter,
+			memoryLimit:   65537,
+			expectedError: "wasm: memoryLimitPages 65537 is larger than the maximum 65536",
+		},
+		{
+			name:          "memory limit: less than guest minimum",
+			guestURL:      test.URLErrorOOMOnFilter,
+			memoryLimit:   1,
+			expectedError: "wasm: guest memory min 2 pages is over memoryLimitPages 1",
+		},
+		{
+			name:        "memory limit: less than guest maximum",
+			guestURL:    test.URLErrorOOMWithMaxOnFilter,
+			memoryLimit: 3,
+		},
+		{
+			name:        "valid pool size",
+			guestURL:    test.URLTestFilter,
+			poolMinSize: 2,
+			poolMaxSize: 4,
+		},
+		{
+			name:          "pool size: max less than min",
+			guestURL:      test.URLTestFilter,
+			poolMinSize:   2,
+			poolMaxSize:   1,
+			expectedError: "wasm: invalid poolMaxSize 1: must not be less than poolMinSize 2",
+		},
+		{
+			name:          "pool size: negative",
+			guestURL:      test.URLTestFilter,
+			poolMinSize:   -1,
+			expectedError: "wasm: invalid poolMinSize -1: must not be negative",
+		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			p, err := wasm.NewFromConfig(ctx, "wasm", wasm.PluginConfig{GuestURL: tc.guestURL}, nil)
+			p, err := wasm.NewFromConfig(ctx, "wasm", wasm.PluginConfig{
+				GuestURL:         tc.guestURL,
+				Timeouts:         tc.timeouts,
+				MemoryLimitPages: tc.memoryLimit,
+				PoolMinSize:      tc.poolMinSize,
+				PoolMaxSize:      tc.poolMaxSize,
+			}, nil)
 			if err != nil {
 				if want, have := tc.expectedError, err.Error(); want != have {
 					t.Fatalf("unexpected error: want %v, have %v", want, have)
@@ -335,6 +411,97 @@ wasm stack trace:
 	}
 }
 
+func TestNewFromConfig_compilationCacheDir(t *testing.T) {
+	dir := path.Join(t.TempDir(), "cache")
+
+	// newPlugin creates a plugin using the cache and runs a filter on it.
+	newPlugin := func(guestURL string) io.Closer {
+		p, err := wasm.NewFromConfig(ctx, "wasm", wasm.PluginConfig{
+			GuestURL:            guestURL,
+			CompilationCacheDir: dir,
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ni := framework.NewNodeInfo()
+		ni.SetNode(test.NodeSmall)
+		s := p.(framework.FilterPlugin).Filter(ctx, nil, test.PodSmall, ni)
+		if want, have := framework.Success, s.Code(); want != have {
+			t.Fatalf("unexpected status code: want %d, have %d (message: %v)", want, have, s.Message())
+		}
+		return p.(io.Closer)
+	}
+
+	// cacheFiles returns the files in the version-specific subdirectory.
+	cacheFiles := func() map[string]os.FileInfo {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(entries) != 1 || !entries[0].IsDir() {
+			t.Fatalf("expected a version-specific directory, have %v", entries)
+		}
+		versionDir := path.Join(dir, entries[0].Name())
+		files, err := os.ReadDir(versionDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		infos := make(map[string]os.FileInfo, len(files))
+		for _, f := range files {
+			if infos[f.Name()], err = os.Stat(path.Join(versionDir, f.Name())); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return infos
+	}
+
+	// Closing the only plugin using the cache closes it, so the next plugin
+	// has to read the compiled guest from disk.
+	_ = newPlugin(test.URLTestFilterFromGlobal).Close()
+	compiled := cacheFiles()
+	if want, have := 1, len(compiled); want != have {
+		t.Fatalf("unexpected cache entries: want %d, have %d", want, have)
+	}
+
+	// The same guest reuses the entry, instead of compiling and writing it.
+	_ = newPlugin(test.URLTestFilterFromGlobal).Close()
+	reused := cacheFiles()
+	if want, have := len(compiled), len(reused); want != have {
+		t.Fatalf("unexpected cache entries: want %d, have %d", want, have)
+	}
+	for name, want := range compiled {
+		if have, ok := reused[name]; !ok || !os.SameFile(want, have) || want.ModTime() != have.ModTime() {
+			t.Fatalf("expected cache entry %s to be reused", name)
+		}
+	}
+
+	// Entries are keyed by the guest binary, so a different guest adds one.
+	// Plugins open at the same time share the cache.
+	p1 := newPlugin(test.URLTestFilterFromGlobal)
+	p2 := newPlugin(test.URLErrorOOMOnFilter)
+	_ = p1.Close()
+	_ = p2.Close()
+	if want, have := len(compiled)+1, len(cacheFiles()); want != have {
+		t.Fatalf("unexpected cache entries: want %d, have %d", want, have)
+	}
+
+	t.Run("not a directory", func(t *testing.T) {
+		file := path.Join(t.TempDir(), "file")
+		if err := os.WriteFile(file, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := wasm.NewFromConfig(ctx, "wasm", wasm.PluginConfig{
+			GuestURL:            test.URLTestFilterFromGlobal,
+			CompilationCacheDir: file,
+		}, nil)
+		want := fmt.Sprintf("wasm: error creating compilation cache in %[1]s: %[1]s is not dir", file)
+		if err == nil || err.Error() != want {
+			t.Fatalf("unexpected error: want %v, have %v", want, err)
+		}
+	})
+}
+
 func TestEnqueue(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -400,6 +567,259 @@ func TestEnqueue(t *testing.T) {
 	})
 }
 
+func TestQueueingHint(t *testing.T) {
+	tests := []struct {
+		name           string
+		globals        map[string]int32
+		eventIndex     int
+		oldObj, newObj interface{}
+		expectedHint   framework.QueueingHint
+		expectedErr    string
+		expectedLens   map[string]int32
+	}{
+		{
+			name:         "skip: node added",
+			globals:      map[string]int32{"queueing_hint": 0},
+			eventIndex:   0,
+			newObj:       test.NodeSmall,
+			expectedHint: framework.QueueSkip,
+			expectedLens: map[string]int32{"event_index": 0, "old_object_len": 0, "new_object_len": int32(test.NodeSmall.Size())},
+		},
+		{
+			name:         "queue: pod deleted",
+			globals:      map[string]int32{"queueing_hint": 1},
+			eventIndex:   1,
+			oldObj:       test.PodSmall,
+			expectedHint: framework.Queue,
+			expectedLens: map[string]int32{"event_index": 1, "old_object_len": int32(test.PodSmall.Size()), "new_object_len": 0},
+		},
+		{
+			name:         "object not supported",
+			globals:      map[string]int32{"queueing_hint": 1},
+			eventIndex:   1,
+			newObj:       "not a kubernetes object",
+			expectedHint: framework.Queue,
+			expectedLens: map[string]int32{"event_index": 1, "old_object_len": 0, "new_object_len": 0},
+		},
+		{
+			name:         "invalid hint",
+			globals:      map[string]int32{"queueing_hint": 2},
+			eventIndex:   0,
+			expectedHint: framework.Queue,
+			expectedErr:  "wasm: guest returned invalid queueing hint 2",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := wasm.NewFromConfig(ctx, "wasm", wasm.PluginConfig{GuestURL: test.URLTestQueueingHintFromGlobal}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.(io.Closer).Close()
+
+			clusterEvents, _ := p.(framework.EnqueueExtensions).EventsToRegister(ctx)
+			if want, have := 2, len(clusterEvents); want != have {
+				t.Fatalf("unexpected cluster events: want %v, have %v", want, have)
+			}
+
+			pl := wasm.NewTestWasmPlugin(p)
+			pl.SetFreeGlobals(tc.globals)
+
+			hintFn := clusterEvents[tc.eventIndex].QueueingHintFn
+			hint, err := hintFn(klog.Background(), test.PodSmall, tc.oldObj, tc.newObj)
+			if want, have := tc.expectedHint, hint; want != have {
+				t.Fatalf("unexpected hint: want %v, have %v", want, have)
+			}
+			if tc.expectedErr != "" {
+				if err == nil || err.Error() != tc.expectedErr {
+					t.Fatalf("unexpected error: want %v, have %v", tc.expectedErr, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			expectedLens := tc.expectedLens
+			expectedLens["pod_len"] = int32(test.PodSmall.Size())
+			if want, have := expectedLens, pl.GetGlobals("event_index", "pod_len", "old_object_len", "new_object_len"); !reflect.DeepEqual(want, have) {
+				t.Fatalf("unexpected globals: want %v, have %v", want, have)
+			}
+		})
+	}
+
+	t.Run("not implemented", func(t *testing.T) {
+		p, err := wasm.NewFromConfig(ctx, "wasm", wasm.PluginConfig{GuestURL: test.URLTestCycleState, Args: []string{"test", "2"}}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer p.(io.Closer).Close()
+
+		clusterEvents, _ := p.(framework.EnqueueExtensions).EventsToRegister(ctx)
+		for _, e := range clusterEvents {
+			if e.QueueingHintFn != nil {
+				t.Fatalf("unexpected QueueingHintFn for %v", e.Event)
+			}
+		}
+	})
+}
+
+func TestPreEnqueue(t *testing.T) {
+	tests := []struct {
+		name                  string
+		guestURL              string
+		globals               map[string]int32
+		expectedStatusCode    framework.Code
+		expectedStatusMessage string
+	}{
+		{
+			name:               "not implemented",
+			guestURL:           test.URLTestFilterFromGlobal,
+			expectedStatusCode: framework.Success,
+		},
+		{
+			name:               "min statusCode",
+			guestURL:           test.URLTestPreEnqueueFromGlobal,
+			globals:            map[string]int32{"status_code": math.MinInt32},
+			expectedStatusCode: math.MinInt32,
+		},
+		{
+			name:               "max statusCode",
+			guestURL:           test.URLTestPreEnqueueFromGlobal,
+			globals:            map[string]int32{"status_code": math.MaxInt32},
+			expectedStatusCode: math.MaxInt32,
+		},
+		{
+			name:               "panic",
+			guestURL:           test.URLErrorPanicOnPreEnqueue,
+			expectedStatusCode: framework.Error,
+			expectedStatusMessage: `wasm: preenqueue error: panic!
+wasm error: unreachable
+wasm stack trace:
+	panic_on_preenqueue.$1() i32`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := wasm.NewFromConfig(ctx, "wasm", wasm.PluginConfig{GuestURL: tc.guestURL}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.(io.Closer).Close()
+
+			if len(tc.globals) > 0 {
+				pl := wasm.NewTestWasmPlugin(p)
+				pl.SetFreeGlobals(tc.globals)
+			}
+
+			s := p.(framework.PreEnqueuePlugin).PreEnqueue(ctx, test.PodSmall)
+			if want, have := tc.expectedStatusCode, s.Code(); want != have {
+				t.Fatalf("unexpected status code: want %d, have %d (message: %v)", want, have, s.Message())
+			}
+			if want, have := tc.expectedStatusMessage, s.Message(); want != have {
+				t.Fatalf("unexpected status message: want %v, have %v", want, have)
+			}
+		})
+	}
+}
+
+func TestLess(t *testing.T) {
+	now := time.Now()
+	pod1 := st.MakePod().Name("pod1").UID("pod1").Priority(1).Obj()
+	pod1.ResourceVersion = "123"
+	pod2 := st.MakePod().Name("pod2").UID("pod2").Priority(2).Obj()
+
+	tests := []struct {
+		name             string
+		globals          map[string]int32
+		podInfo1         *framework.QueuedPodInfo
+		podInfo2         *framework.QueuedPodInfo
+		expectedLess     bool
+		expectedGlobals  map[string]int32
+		expectedFallback bool
+	}{
+		{
+			name:            "more attempts",
+			podInfo1:        &framework.QueuedPodInfo{PodInfo: &framework.PodInfo{Pod: pod1}, Timestamp: now, Attempts: 2},
+			podInfo2:        &framework.QueuedPodInfo{PodInfo: &framework.PodInfo{Pod: pod2}, Timestamp: now, Attempts: 1},
+			expectedLess:    true,
+			expectedGlobals: map[string]int32{"pod_key_len": int32(len("pod1/123")), "pod_len": int32(pod2.Size())},
+		},
+		{
+			name:            "fewer attempts",
+			podInfo1:        &framework.QueuedPodInfo{PodInfo: &framework.PodInfo{Pod: pod1}, Timestamp: now, Attempts: 1},
+			podInfo2:        &framework.QueuedPodInfo{PodInfo: &framework.PodInfo{Pod: pod2}, Timestamp: now, Attempts: 2},
+			expectedLess:    false,
+			expectedGlobals: map[string]int32{"pod_key_len": int32(len("pod1/123")), "pod_len": int32(pod2.Size())},
+		},
+		{
+			name:            "older",
+			podInfo1:        &framework.QueuedPodInfo{PodInfo: &framework.PodInfo{Pod: pod1}, Timestamp: now.Add(-time.Second)},
+			podInfo2:        &framework.QueuedPodInfo{PodInfo: &framework.PodInfo{Pod: pod2}, Timestamp: now},
+			expectedLess:    true,
+			expectedGlobals: map[string]int32{"pod_key_len": int32(len("pod1/123")), "pod_len": int32(pod2.Size())},
+		},
+		{
+			name:     "error falls back to priority",
+			globals:  map[string]int32{"pod_index": 2},
+			podInfo1: &framework.QueuedPodInfo{PodInfo: &framework.PodInfo{Pod: pod1}, Timestamp: now.Add(-time.Second)},
+			podInfo2: &framework.QueuedPodInfo{PodInfo: &framework.PodInfo{Pod: pod2}, Timestamp: now},
+			// pod2 has a higher priority, even though pod1 is older.
+			expectedLess:     false,
+			expectedFallback: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := wasm.NewFromConfig(ctx, "wasm", wasm.PluginConfig{GuestURL: test.URLTestLessFromParams}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.(io.Closer).Close()
+
+			pl := wasm.NewTestWasmPlugin(p)
+			if len(tc.globals) > 0 {
+				pl.SetSortGlobals(tc.globals)
+			}
+
+			less := p.(framework.QueueSortPlugin).Less(tc.podInfo1, tc.podInfo2)
+			if want, have := tc.expectedLess, less; want != have {
+				t.Fatalf("unexpected less: want %v, have %v", want, have)
+			}
+			if tc.expectedFallback {
+				return // the guest was closed
+			}
+			if want, have := tc.expectedGlobals, pl.GetSortGlobals("pod_key_len", "pod_len"); !reflect.DeepEqual(want, have) {
+				t.Fatalf("unexpected globals: want %v, have %v", want, have)
+			}
+		})
+	}
+}
+
+func TestLess_poolExhausted(t *testing.T) {
+	p, err := wasm.NewFromConfig(ctx, "wasm", wasm.PluginConfig{GuestURL: test.URLTestLessFromParams, PoolMaxSize: 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.(io.Closer).Close()
+
+	// The only guest in the pool is in a binding cycle.
+	pl := wasm.NewTestWasmPlugin(p)
+	pl.CreateGuestInBindingGuestPool("pod")
+
+	// Less uses its own guest, so it doesn't fall back to priority.
+	now := time.Now()
+	pod1 := st.MakePod().Name("pod1").UID("pod1").Priority(1).Obj()
+	pod2 := st.MakePod().Name("pod2").UID("pod2").Priority(2).Obj()
+	podInfo1 := &framework.QueuedPodInfo{PodInfo: &framework.PodInfo{Pod: pod1}, Timestamp: now.Add(-time.Second)}
+	podInfo2 := &framework.QueuedPodInfo{PodInfo: &framework.PodInfo{Pod: pod2}, Timestamp: now}
+	if less := p.(framework.QueueSortPlugin).Less(podInfo1, podInfo2); !less {
+		t.Fatal("expected the older pod to be less")
+	}
+}
+
 func TestPreFilter(t *testing.T) {
 	tests := []struct {
 		name                  string
@@ -597,6 +1017,311 @@ wasm stack trace:
 	}
 }
 
+func TestNodeInfoPods(t *testing.T) {
+	plain := st.MakePod().Name("plain").Obj()
+	affinity := st.MakePod().Name("affinity").PodAffinityExists("foo", "zone", st.PodAffinityWithRequiredReq).Obj()
+	antiAffinity := st.MakePod().Name("anti-affinity").PodAntiAffinityExists("foo", "zone", st.PodAntiAffinityWithRequiredReq).Obj()
+
+	tests := []struct {
+		name         string
+		pods         []*v1.Pod
+		podsKind     int32
+		expectedPods []v1.Pod
+	}{
+		{
+			name: "no pods",
+		},
+		{
+			name:         "pods",
+			pods:         []*v1.Pod{plain, affinity, antiAffinity},
+			expectedPods: []v1.Pod{*plain, *affinity, *antiAffinity},
+		},
+		{
+			name:         "pods with affinity",
+			pods:         []*v1.Pod{plain, affinity, antiAffinity},
+			podsKind:     1,
+			expectedPods: []v1.Pod{*affinity, *antiAffinity},
+		},
+		{
+			name:         "pods with required anti-affinity",
+			pods:         []*v1.Pod{plain, affinity, antiAffinity},
+			podsKind:     2,
+			expectedPods: []v1.Pod{*antiAffinity},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := wasm.NewFromConfig(ctx, "wasm", wasm.PluginConfig{GuestURL: test.URLTestNodeInfoPodsFromGlobal}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.(io.Closer).Close()
+
+			pl := wasm.NewTestWasmPlugin(p)
+			pl.SetGlobals(map[string]int32{"pods_kind": tc.podsKind})
+
+			// The pods are read from the NodeInfo passed to Filter, which
+			// isn't in the snapshot.
+			ni := framework.NewNodeInfo(tc.pods...)
+			ni.SetNode(test.NodeSmall)
+
+			// The guest returns the size of the PodList as the status code.
+			status := p.(framework.FilterPlugin).Filter(ctx, nil, test.PodSmall, ni)
+			expected := &v1.PodList{Items: tc.expectedPods}
+			if want, have := framework.Code(expected.Size()), status.Code(); want != have {
+				t.Fatalf("unexpected PodList size: want %d, have %d", want, have)
+			}
+		})
+	}
+}
+
+func TestFilter_timeout(t *testing.T) {
+	p, err := wasm.NewFromConfig(ctx, "wasm", wasm.PluginConfig{
+		GuestURL: test.URLErrorLoopOnFilter,
+		Timeouts: map[string]metav1.Duration{"filter": {Duration: 10 * time.Millisecond}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.(io.Closer).Close()
+
+	pl := wasm.NewTestWasmPlugin(p)
+	ni := framework.NewNodeInfo()
+	ni.SetNode(test.NodeSmall)
+
+	// Each call should time out, even if the prior guest was closed.
+	for i := 0; i < 2; i++ {
+		s := pl.Filter(ctx, nil, test.PodSmall, ni)
+		if want, have := framework.Error, s.Code(); want != have {
+			t.Fatalf("unexpected status code: want %d, have %d (message: %v)", want, have, s.Message())
+		}
+		if want, have := "wasm: filter error: timeout of 10ms exceeded", s.Message(); want != have {
+			t.Fatalf("unexpected status message: want %v, have %v", want, have)
+		}
+		if pl.GetScheduledGuest() != nil {
+			t.Fatal("expected the closed guest to be discarded")
+		}
+		if len(pl.GetFreePool()) != 0 {
+			t.Fatal("expected the closed guest not to be returned to the pool")
+		}
+	}
+}
+
+func TestFilter_panic(t *testing.T) {
+	p, err := wasm.NewFromConfig(ctx, "wasm", wasm.PluginConfig{GuestURL: test.URLErrorPanicOnFilterFromGlobal}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.(io.Closer).Close()
+
+	pl := wasm.NewTestWasmPlugin(p)
+	ni := framework.NewNodeInfo()
+	ni.SetNode(test.NodeSmall)
+
+	// Set the global on the scheduled guest, so that its filter panics.
+	pl.SetGlobals(map[string]int32{"panic": 1})
+	s := pl.Filter(ctx, nil, test.PodSmall, ni)
+	if want, have := framework.Error, s.Code(); want != have {
+		t.Fatalf("unexpected status code: want %d, have %d (message: %v)", want, have, s.Message())
+	}
+	if pl.GetScheduledGuest() != nil {
+		t.Fatal("expected the guest to be discarded")
+	}
+
+	// The next call should use a new guest, which doesn't have the global set.
+	s = pl.Filter(ctx, nil, test.PodSmall, ni)
+	if want, have := framework.Success, s.Code(); want != have {
+		t.Fatalf("unexpected status code: want %d, have %d (message: %v)", want, have, s.Message())
+	}
+}
+
+func TestGuestDiscardedAfterPanic(t *testing.T) {
+	tests := []struct {
+		name                  string
+		guestURL              string
+		call                  func(*wasm.WasmPlugin) *framework.Status
+		guest                 func(*wasm.WasmPlugin) *wasm.Guest
+		expectedStatusMessage string
+	}{
+		{
+			name:     "filter",
+			guestURL: test.URLErrorPanicOnFilter,
+			call: func(pl *wasm.WasmPlugin) *framework.Status {
+				ni := framework.NewNodeInfo()
+				ni.SetNode(test.NodeSmall)
+				return pl.Filter(ctx, nil, test.PodSmall, ni)
+			},
+			guest: (*wasm.WasmPlugin).GetScheduledGuest,
+			expectedStatusMessage: `wasm: filter error: panic!
+wasm error: unreachable
+wasm stack trace:
+	panic_on_filter.$1() i32`,
+		},
+		{
+			name:     "permit",
+			guestURL: test.URLErrorPanicOnPermit,
+			call: func(pl *wasm.WasmPlugin) *framework.Status {
+				defer pl.ClearBindingCycles()
+				s, _ := pl.Permit(ctx, nil, test.PodSmall, test.NodeSmallName)
+				return s
+			},
+			guest: (*wasm.WasmPlugin).GetScheduledGuest,
+			expectedStatusMessage: `wasm: permit error: panic!
+wasm error: unreachable
+wasm stack trace:
+	panic_on_permit.$1() i64`,
+		},
+		{
+			name:     "bind",
+			guestURL: test.URLErrorPanicOnBind,
+			call: func(pl *wasm.WasmPlugin) *framework.Status {
+				return pl.Bind(ctx, nil, test.PodSmall, test.NodeSmallName)
+			},
+			guest: func(pl *wasm.WasmPlugin) *wasm.Guest {
+				return pl.GetBindingCycles()[test.PodSmall.UID]
+			},
+			expectedStatusMessage: `wasm: bind error: panic!
+wasm error: unreachable
+wasm stack trace:
+	panic_on_bind.$1() i32`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := wasm.NewFromConfig(ctx, "wasm", wasm.PluginConfig{GuestURL: tc.guestURL}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.(io.Closer).Close()
+
+			pl := wasm.NewTestWasmPlugin(p)
+			pl.CreateGuestInBindingGuestPool(test.PodSmall.UID)
+
+			// Each call should use a new guest, so the panic is the same as
+			// the first instead of an error about a closed module.
+			for i := 0; i < 2; i++ {
+				s := tc.call(pl)
+				if want, have := framework.Error, s.Code(); want != have {
+					t.Fatalf("unexpected status code: want %d, have %d (message: %v)", want, have, s.Message())
+				}
+				if want, have := tc.expectedStatusMessage, s.Message(); want != have {
+					t.Fatalf("unexpected status message: want %v, have %v", want, have)
+				}
+				if g := tc.guest(pl); g != nil && !g.IsClosed() {
+					t.Fatal("expected the guest to be closed")
+				}
+				for _, g := range pl.GetFreePool() {
+					if g.IsClosed() {
+						t.Fatal("expected the closed guest not to be returned to the pool")
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFilter_canceled(t *testing.T) {
+	// Only score has a timeout, but enabling any closes guests whose
+	// caller's context is done.
+	p, err := wasm.NewFromConfig(ctx, "wasm", wasm.PluginConfig{
+		GuestURL: test.URLErrorLoopOnFilter,
+		Timeouts: map[string]metav1.Duration{"score": {Duration: time.Hour}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.(io.Closer).Close()
+
+	pl := wasm.NewTestWasmPlugin(p)
+	ni := framework.NewNodeInfo()
+	ni.SetNode(test.NodeSmall)
+
+	cancelCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	s := pl.Filter(cancelCtx, nil, test.PodSmall, ni)
+	if want, have := framework.Error, s.Code(); want != have {
+		t.Fatalf("unexpected status code: want %d, have %d (message: %v)", want, have, s.Message())
+	}
+	if want, have := "wasm: filter error: guest closed: context deadline exceeded", s.Message(); want != have {
+		t.Fatalf("unexpected status message: want %v, have %v", want, have)
+	}
+	if pl.GetScheduledGuest() != nil {
+		t.Fatal("expected the closed guest to be discarded")
+	}
+}
+
+func TestFilter_memoryLimit(t *testing.T) {
+	tests := []struct {
+		name                  string
+		guestURL              string
+		memoryLimit           uint32
+		expectedStatusCode    framework.Code
+		expectedStatusMessage string
+	}{
+		{
+			name:               "no limit",
+			guestURL:           test.URLErrorOOMOnFilter,
+			expectedStatusCode: framework.Success,
+		},
+		{
+			name:               "under limit",
+			guestURL:           test.URLErrorOOMOnFilter,
+			memoryLimit:        4,
+			expectedStatusCode: framework.Success,
+		},
+		{
+			name:                  "limit exceeded",
+			guestURL:              test.URLErrorOOMOnFilter,
+			memoryLimit:           3,
+			expectedStatusCode:    framework.Error,
+			expectedStatusMessage: `wasm: filter error: memory limit of 3 pages exceeded: requested 4 pages`,
+		},
+		{
+			name:                  "limit exceeded: under guest maximum",
+			guestURL:              test.URLErrorOOMWithMaxOnFilter,
+			memoryLimit:           3,
+			expectedStatusCode:    framework.Error,
+			expectedStatusMessage: `wasm: filter error: memory limit of 3 pages exceeded: requested 4 pages`,
+		},
+		{
+			// The guest is at the limit, but panicked for another reason.
+			name:               "unrelated panic at limit",
+			guestURL:           test.URLErrorPanicOnFilter,
+			memoryLimit:        1,
+			expectedStatusCode: framework.Error,
+			expectedStatusMessage: `wasm: filter error: panic!
+wasm error: unreachable
+wasm stack trace:
+	panic_on_filter.$1() i32`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := wasm.NewFromConfig(ctx, "wasm", wasm.PluginConfig{
+				GuestURL:         tc.guestURL,
+				MemoryLimitPages: tc.memoryLimit,
+			}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.(io.Closer).Close()
+
+			ni := framework.NewNodeInfo()
+			ni.SetNode(test.NodeSmall)
+			s := p.(framework.FilterPlugin).Filter(ctx, nil, test.PodSmall, ni)
+			if want, have := tc.expectedStatusCode, s.Code(); want != have {
+				t.Fatalf("unexpected status code: want %d, have %d (message: %v)", want, have, s.Message())
+			}
+			if want, have := tc.expectedStatusMessage, s.Message(); want != have {
+				t.Fatalf("unexpected status message: want %v, have %v", want, have)
+			}
+		})
+	}
+}
+
 func TestPostFilter(t *testing.T) {
 	tests := []struct {
 		name                  string
@@ -768,7 +1493,7 @@ wasm stack trace:
 			guestURL:           test.URLErrorPreScoreWithoutScore,
 			pod:                test.PodSmall,
 			expectedStatusCode: framework.Error,
-			expectedError:      `wasm: filter, score, reserve, permit or bind must be exported`,
+			expectedError:      `wasm: preenqueue, less, filter, score, reserve, permit or bind must be exported`,
 		},
 	}
 
@@ -978,7 +1703,7 @@ wasm stack trace:
 			guestURL:           test.URLErrorScoreExtensionsWithoutScore,
 			pod:                test.PodSmall,
 			expectedStatusCode: framework.Error,
-			expectedError:      `wasm: filter, score, reserve, permit or bind must be exported`,
+			expectedError:      `wasm: preenqueue, less, filter, score, reserve, permit or bind must be exported`,
 		},
 	}
 
@@ -1211,6 +1936,32 @@ func TestPermit(t *testing.T) {
 	}
 }
 
+func TestPermit_timeout(t *testing.T) {
+	p, err := wasm.NewFromConfig(ctx, "wasm", wasm.PluginConfig{
+		GuestURL: test.URLErrorLoopOnPermit,
+		Timeouts: map[string]metav1.Duration{"permit": {Duration: 10 * time.Millisecond}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.(io.Closer).Close()
+
+	pl := wasm.NewTestWasmPlugin(p)
+	s, _ := pl.Permit(ctx, nil, test.PodSmall, test.NodeSmallName)
+	if want, have := framework.Error, s.Code(); want != have {
+		t.Fatalf("unexpected status code: want %d, have %d (message: %v)", want, have, s.Message())
+	}
+	if want, have := "wasm: permit error: timeout of 10ms exceeded", s.Message(); want != have {
+		t.Fatalf("unexpected status message: want %v, have %v", want, have)
+	}
+
+	// The binding cycle should have a usable guest, even though the one used
+	// in the scheduling cycle was closed.
+	if g, ok := pl.GetBindingCycles()[test.PodSmall.UID]; !ok || g.IsClosed() {
+		t.Fatal("expected an open guest in the binding cycle")
+	}
+}
+
 func TestPreBind(t *testing.T) {
 	tests := []struct {
 		name                  string
@@ -1381,6 +2132,39 @@ wasm stack trace:
 	}
 }
 
+func TestBind_timeout(t *testing.T) {
+	p, err := wasm.NewFromConfig(ctx, "wasm", wasm.PluginConfig{
+		GuestURL: test.URLErrorLoopOnBind,
+		Timeouts: map[string]metav1.Duration{"bind": {Duration: 10 * time.Millisecond}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.(io.Closer).Close()
+
+	pl := wasm.NewTestWasmPlugin(p)
+	pl.CreateGuestInBindingGuestPool(test.PodSmall.UID)
+
+	// Each call should time out, even if the prior guest was closed.
+	var prior *wasm.Guest
+	for i := 0; i < 2; i++ {
+		s := pl.Bind(ctx, nil, test.PodSmall, test.NodeSmallName)
+		if want, have := framework.Error, s.Code(); want != have {
+			t.Fatalf("unexpected status code: want %d, have %d (message: %v)", want, have, s.Message())
+		}
+		if want, have := "wasm: bind error: timeout of 10ms exceeded", s.Message(); want != have {
+			t.Fatalf("unexpected status message: want %v, have %v", want, have)
+		}
+		g := pl.GetBindingCycles()[test.PodSmall.UID]
+		if !g.IsClosed() {
+			t.Fatal("expected the guest to be closed")
+		} else if g == prior {
+			t.Fatal("expected the closed guest to be replaced")
+		}
+		prior = g
+	}
+}
+
 func TestPostBind(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -1674,6 +2458,150 @@ wasm stack trace:
 }
 
 // This test checks whether framework.handle.EventRecorder.Eventf can be called within wasm file.
+func TestCycleStateClone(t *testing.T) {
+	p, err := wasm.NewFromConfig(ctx, "wasm", wasm.PluginConfig{GuestURL: test.URLTestCycleStateFromGlobal}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.(io.Closer).Close()
+
+	pod := test.PodSmall
+	ni := framework.NewNodeInfo()
+	ni.SetNode(test.NodeSmall)
+	podInfo := &framework.PodInfo{Pod: st.MakePod().Name("victim").Obj()}
+
+	// The guest returns its cycle state, the count of added pods, as the
+	// status code of filter.
+	requireFilter := func(state *framework.CycleState, want framework.Code) {
+		t.Helper()
+		if have := p.(framework.FilterPlugin).Filter(ctx, state, pod, ni).Code(); want != have {
+			t.Fatalf("unexpected status code: want %d, have %d", want, have)
+		}
+	}
+	addPod := func(state *framework.CycleState) {
+		t.Helper()
+		if status := p.(framework.PreFilterExtensions).AddPod(ctx, state, pod, podInfo, ni); !status.IsSuccess() {
+			t.Fatal(status)
+		}
+	}
+
+	state := framework.NewCycleState()
+	if _, status := p.(framework.PreFilterPlugin).PreFilter(ctx, state, pod); !status.IsSuccess() {
+		t.Fatal(status)
+	}
+	requireFilter(state, 0)
+
+	// Changes to a clone don't affect the original.
+	clone := state.Clone()
+	addPod(clone)
+	requireFilter(clone, 1)
+	requireFilter(state, 0)
+
+	// A new clone starts from the original, not the last clone used.
+	clone2 := state.Clone()
+	addPod(clone2)
+	addPod(clone2)
+	requireFilter(clone2, 2)
+	requireFilter(clone, 1)
+
+	// A clone of a clone starts from its parent.
+	nested := clone.Clone()
+	if status := p.(framework.PreFilterExtensions).RemovePod(ctx, nested, pod, podInfo, ni); !status.IsSuccess() {
+		t.Fatal(status)
+	}
+	requireFilter(nested, 0)
+	requireFilter(clone, 1)
+	requireFilter(state, 0)
+}
+
+// binaryStateData is a native framework.StateData readable by guests.
+type binaryStateData string
+
+func (d binaryStateData) Clone() framework.StateData { return d }
+
+func (d binaryStateData) MarshalBinary() ([]byte, error) { return []byte(d), nil }
+
+// opaqueStateData is a native framework.StateData not readable by guests.
+type opaqueStateData struct{}
+
+func (d opaqueStateData) Clone() framework.StateData { return d }
+
+func TestSharedCycleState(t *testing.T) {
+	tests := []struct {
+		name                  string
+		in                    framework.StateData
+		expectedStatusCode    framework.Code
+		expectedStatusMessage string
+		expectedOut           framework.StateData
+	}{
+		{
+			name:               "written by guest",
+			in:                 wasm.StateData("hello"),
+			expectedStatusCode: framework.Success,
+			expectedOut:        wasm.StateData("hello"),
+		},
+		{
+			name:               "written by native plugin",
+			in:                 binaryStateData("hello"),
+			expectedStatusCode: framework.Success,
+			expectedOut:        wasm.StateData("hello"),
+		},
+		{
+			name:               "empty",
+			in:                 wasm.StateData{},
+			expectedStatusCode: framework.Success,
+			expectedOut:        wasm.StateData{},
+		},
+		{
+			name:               "not found",
+			expectedStatusCode: framework.Error,
+		},
+		{
+			name:               "not readable",
+			in:                 opaqueStateData{},
+			expectedStatusCode: framework.Error,
+			expectedStatusMessage: `wasm: filter error: wasm: cycle state value wasm_test.opaqueStateData doesn't implement encoding.BinaryMarshaler (recovered by wazero)
+wasm stack trace:
+	k8s.io/scheduler.cyclestate.read(i32,i32,i32,i32) i64
+	sharedcyclestate.$3() i32`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := wasm.NewFromConfig(ctx, "wasm", wasm.PluginConfig{GuestURL: test.URLTestSharedCycleState}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.(io.Closer).Close()
+
+			state := framework.NewCycleState()
+			if tc.in != nil {
+				state.Write("in", tc.in)
+			}
+			ni := framework.NewNodeInfo()
+			ni.SetNode(test.NodeSmall)
+
+			status := p.(framework.FilterPlugin).Filter(ctx, state, test.PodSmall, ni)
+			if want, have := tc.expectedStatusCode, status.Code(); want != have {
+				t.Fatalf("unexpected status code: want %d, have %d", want, have)
+			}
+			if want, have := tc.expectedStatusMessage, status.Message(); want != have {
+				t.Fatalf("unexpected status message: want %v, have %v", want, have)
+			}
+
+			// The guest moves "in" to "out" when it is readable.
+			out, _ := state.Read("out")
+			if want, have := tc.expectedOut, out; !reflect.DeepEqual(want, have) {
+				t.Fatalf("unexpected out: want %#v, have %#v", want, have)
+			}
+			if _, err := state.Read("in"); tc.expectedOut != nil && err == nil {
+				t.Fatal("expected in to be deleted")
+			}
+		})
+	}
+}
+
 func TestEventf(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -1855,8 +2783,18 @@ func TestGetWaitingPod(t *testing.T) {
 }
 
 // Extracts and trims the actual log message from a formatted klog string
-// (klog includes timestamp before actual log message)
+// (klog includes timestamp before actual log message). Info messages, such as
+// guest output, are skipped.
 func extractMessage(log string) string {
+	for _, entry := range klogEntry.FindAllStringIndex(log, -1) {
+		if log[entry[0]] != 'I' {
+			log = log[entry[0]:]
+			break
+		}
+	}
+	if strings.HasPrefix(log, "I") {
+		return ""
+	}
 	parts := strings.SplitN(log, "]", 2)
 	if len(parts) < 2 {
 		return ""
@@ -1864,6 +2802,9 @@ func extractMessage(log string) string {
 	return strings.TrimSpace(parts[1])
 }
 
+// klogEntry matches the start of a klog entry, such as "E1016 ".
+var klogEntry = regexp.MustCompile(`(?m)^[IWEF]\d{4} `)
+
 // captureStderr temporarily redirects the standard error output to capture any data written to it.
 // This function is particularly useful for capturing klog's error output during tests.
 // It takes a function f, executes it, and captures anything written to stderr during its execution.
@@ -18,19 +18,55 @@ package wasm
 
 import (
 	"context"
+	"fmt"
 	"sync"
+	"time"
 
 	"k8s.io/apimachinery/pkg/types"
 )
 
+// instance is the type of guest managed by guestPool.
+type instance interface {
+	comparable
+
+	// isClosed returns true when the guest can no longer be used, for example
+	// when it trapped or its execution deadline was exceeded.
+	isClosed() bool
+
+	// close closes the guest, so that it can no longer be used.
+	close()
+}
+
+// guestPoolConfig configures the size of a guestPool.
+type guestPoolConfig struct {
+	// minSize is the number of guests to create eagerly and keep when idle.
+	minSize int
+	// maxSize is the maximum number of guests or zero for no maximum.
+	maxSize int
+	// maxWait is how long to wait for a guest when there are maxSize in use.
+	// Zero fails fast.
+	maxWait time.Duration
+	// idleTTL is how long a free guest is kept, when there are more than
+	// minSize. Zero keeps them forever.
+	idleTTL time.Duration
+	// reportSize, if set, is called with the count of guests in each state
+	// after the pool changes.
+	reportSize func(free, binding, scheduling int)
+}
+
 // guestPool manages guest to pod assignments in a scheduling or binding cycle.
 //
 // Assumptions made about the lifecycle are taken from the below diagram
 // https://kubernetes.io/docs/concepts/scheduling-eviction/scheduling-framework/#extension-points
-type guestPool[guest comparable] struct {
+//
+// Guests which are closed after use, such as after a trap, are discarded
+// instead of re-used. Replacements are created lazily, on the next call.
+type guestPool[guest instance] struct {
 	// newGuest is a function to create a new guest.
 	newGuest func(context.Context) (guest, error)
 
+	config guestPoolConfig
+
 	mux sync.RWMutex
 
 	// scheduledPodUID is the UID of the pod being scheduled.
@@ -43,20 +79,91 @@ type guestPool[guest comparable] struct {
 
 	// free pool of guests not in use
 	free []guest
+
+	// freeSince is when each guest in the free pool was last put there.
+	freeSince map[guest]time.Time
+
+	// size is the count of guests which are not closed, in use or free.
+	size int
+
+	// released is closed and replaced when a guest is put back or discarded,
+	// to wake up callers waiting for the pool to be below its maxSize.
+	released chan struct{}
+
+	// stopReaper stops reaping idle guests, if idleTTL was set.
+	stopReaper context.CancelFunc
 }
 
-func newGuestPool[guest comparable](ctx context.Context, newGuest func(context.Context) (guest, error)) (*guestPool[guest], error) {
-	// Eagerly add one instance to the pool. Doing so helps to fail fast.
-	g, createErr := newGuest(ctx)
-	if createErr != nil {
-		return nil, createErr
+func newGuestPool[guest instance](ctx context.Context, newGuest func(context.Context) (guest, error), config guestPoolConfig) (*guestPool[guest], error) {
+	p := &guestPool[guest]{
+		newGuest:  newGuest,
+		config:    config,
+		binding:   make(map[types.UID]guest),
+		freeSince: make(map[guest]time.Time),
+		released:  make(chan struct{}),
 	}
 
-	return &guestPool[guest]{
-		newGuest: newGuest,
-		binding:  make(map[types.UID]guest),
-		free:     []guest{g},
-	}, nil
+	// Eagerly add at least one instance to the pool. Doing so helps to fail
+	// fast.
+	for i := 0; i < max(config.minSize, 1); i++ {
+		g, createErr := newGuest(ctx)
+		if createErr != nil {
+			return nil, createErr
+		}
+		p.size++
+		p.put(g)
+	}
+
+	if ttl := config.idleTTL; ttl > 0 {
+		var reaperCtx context.Context
+		reaperCtx, p.stopReaper = context.WithCancel(context.Background())
+		go p.reapEvery(reaperCtx, ttl)
+	}
+	p.report()
+	return p, nil
+}
+
+// get returns a guest from the free pool, or a new one. When the pool is at
+// its maxSize, this waits up to maxWait for another guest to be released.
+// This must be called under a lock, which is released while waiting.
+func (p *guestPool[guest]) get(ctx context.Context) (g guest, err error) {
+	var deadline <-chan time.Time
+	for {
+		if n := len(p.free); n > 0 { // Prefer the free pool
+			g = p.free[0]
+			p.free = p.free[1:]
+			delete(p.freeSince, g)
+			return
+		}
+		if p.config.maxSize == 0 || p.size < p.config.maxSize {
+			if g, err = p.newGuest(ctx); err == nil {
+				p.size++
+			}
+			return
+		}
+		if p.config.maxWait == 0 {
+			return g, fmt.Errorf("wasm: guest pool exhausted: %d guests in use", p.size)
+		}
+		if deadline == nil {
+			timer := time.NewTimer(p.config.maxWait)
+			defer timer.Stop()
+			deadline = timer.C
+		}
+
+		// Wait for a guest to be released, without holding the lock.
+		released := p.released
+		p.mux.Unlock()
+		select {
+		case <-released:
+			p.mux.Lock()
+		case <-deadline:
+			p.mux.Lock()
+			return g, fmt.Errorf("wasm: guest pool exhausted: %d guests in use after waiting %v", p.size, p.config.maxWait)
+		case <-ctx.Done():
+			p.mux.Lock()
+			return g, ctx.Err()
+		}
+	}
 }
 
 // doWithGuest runs initialization functions that precede the scheduling cycle.
@@ -64,16 +171,33 @@ func newGuestPool[guest comparable](ctx context.Context, newGuest func(context.C
 func (p *guestPool[guest]) doWithGuest(ctx context.Context, fn func(guest)) (err error) {
 	p.mux.Lock()
 	defer p.mux.Unlock()
+	defer p.report()
 
 	p.scheduledPodUID = ""
 	var g, zero guest
 
 	if g = p.scheduled; g != zero { // Prefer last scheduled
 		p.scheduled = zero
-	} else if len(p.free) > 0 { // Then, the free pool
-		g = p.free[0]
-		p.free = p.free[1:]
-	} else if g, err = p.newGuest(ctx); err != nil { // Finally, a new guest
+	} else if g, err = p.get(ctx); err != nil { // Then, the free pool or a new guest
+		return
+	}
+
+	fn(g)
+	p.put(g)
+	return
+}
+
+// doWithFreeGuest runs the function with a guest not in any cycle. Unlike
+// doWithGuest, this leaves the scheduling cycle alone, as it is used for
+// calls which can happen while a pod is being scheduled, such as
+// framework.QueueingHintFn.
+func (p *guestPool[guest]) doWithFreeGuest(ctx context.Context, fn func(guest)) (err error) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	defer p.report()
+
+	var g guest
+	if g, err = p.get(ctx); err != nil { // The free pool or a new guest
 		return
 	}
 
@@ -95,38 +219,42 @@ func (p *guestPool[guest]) doWithGuest(ctx context.Context, fn func(guest)) (err
 //
 // Hence, we need to serialize access to the scheduling guest, so that it isn't
 // corrupted from overlapping use.
-func (p *guestPool[guest]) doWithSchedulingGuest(ctx context.Context, podUID types.UID, fn func(guest)) error {
+func (p *guestPool[guest]) doWithSchedulingGuest(ctx context.Context, podUID types.UID, fn func(guest)) (err error) {
 	p.mux.Lock()
 	defer p.mux.Unlock()
+	defer p.report()
 
 	// The scheduling cycle runs sequentially. If we still have an association,
 	// take it over. Guests who cache state should use the podUID to identify a
 	// difference.
 	p.scheduledPodUID = podUID
 	var zero guest
-	if scheduled := p.scheduled; scheduled != zero {
-		fn(scheduled)
-		return nil
-	}
-
-	// Prefer the free pool
-	if len(p.free) > 0 {
-		g := p.free[0]
-		p.free = p.free[1:]
+	g := p.scheduled
+	if g == zero {
+		if g, err = p.get(ctx); err != nil {
+			// If we're at this point, the guest previously scheduled was
+			// re-assigned to the binding cycle, or closed. Getting another
+			// guest failed.
+			return err
+		}
+		if p.scheduled != zero {
+			// Another call assigned a guest while we waited, so use that.
+			p.put(g)
+			g = p.scheduled
+		}
+		p.scheduledPodUID = podUID
 		p.scheduled = g
-		fn(g)
-		return nil
 	}
 
-	// If we're at this point, the guest previously scheduled was re-assigned
-	// to the binding cycle. Create a new guest.
-	if g, err := p.newGuest(ctx); err == nil {
-		p.scheduled = g
-		fn(g)
-		return nil
-	} else {
-		return err
+	fn(g)
+
+	// Forget a guest closed during the call, so that the next call in this
+	// scheduling cycle uses a new one.
+	if g.isClosed() {
+		p.scheduled = zero
+		p.discard()
 	}
+	return nil
 }
 
 // getForBinding returns a guest for the current podUID or an error.
@@ -135,34 +263,51 @@ func (p *guestPool[guest]) doWithSchedulingGuest(ctx context.Context, podUID typ
 // after a schedule. If there's an existing association with the podUID, it
 // is re-used. Otherwise, the current scheduling guest is re-associated for
 // binding.
-func (p *guestPool[guest]) getForBinding(podUID types.UID) guest {
+//
+// When the guest for the podUID was closed, for example because it trapped,
+// a new guest is created in its place.
+func (p *guestPool[guest]) getForBinding(ctx context.Context, podUID types.UID) (g guest, err error) {
 	p.mux.Lock()
 	defer p.mux.Unlock()
+	defer p.report()
 
 	// Fast path is we are in an existing binding cycle.
 	var zero guest
-	if g := p.binding[podUID]; g != zero {
-		return g // current guest is still correct.
-	}
-
-	// We re-used the guest from the scheduling cycle for the binding cycle,
-	// so that it doesn't have to unmarshal the pod again.
-	if scheduled := p.scheduled; scheduled != zero {
+	if g = p.binding[podUID]; g != zero && !g.isClosed() {
+		return // current guest is still correct.
+	} else if g != zero {
+		delete(p.binding, podUID) // discard, as it cannot be re-used.
+		p.discard()
+	} else if scheduled := p.scheduled; scheduled != zero {
+		// We re-used the guest from the scheduling cycle for the binding cycle,
+		// so that it doesn't have to unmarshal the pod again.
 		p.scheduledPodUID = ""
 		p.scheduled = zero
 		p.binding[podUID] = scheduled
-		return scheduled
+		return scheduled, nil
+	} else if p.scheduledPodUID == podUID {
+		// The guest used in the scheduling cycle was closed during its last
+		// call, so there's nothing to re-associate.
+		p.scheduledPodUID = ""
+	} else {
+		// Reaching here is unexpected, because the binding cycle must happen
+		// after a scheduling one, even if binding cycles can run in parallel.
+		panic("unexpected podUID")
 	}
 
-	// Reaching here is unexpected, because the binding cycle must happen after
-	// a scheduling one, even if binding cycles can run in parallel.
-	panic("unexpected podUID")
+	// Replace the closed guest, preferring the free pool.
+	if g, err = p.get(ctx); err != nil {
+		return
+	}
+	p.binding[podUID] = g
+	return
 }
 
 // freeFromBinding should be called when a binding cycle ends for any reason.
 func (p *guestPool[guest]) freeFromBinding(podUID types.UID) {
 	p.mux.Lock()
 	defer p.mux.Unlock()
+	defer p.report()
 
 	if g, ok := p.binding[podUID]; ok {
 		delete(p.binding, podUID)
@@ -170,12 +315,117 @@ func (p *guestPool[guest]) freeFromBinding(podUID types.UID) {
 	}
 }
 
-// put puts the guest instance back to the pool. This must be called under a
-// lock.
+// put puts the guest instance back to the pool, unless it was closed. This
+// must be called under a lock.
 func (p *guestPool[guest]) put(g guest) {
 	var zero guest
 	if g == zero {
 		panic("nil guest")
 	}
+	if g.isClosed() {
+		p.discard() // as it cannot be re-used.
+		return
+	}
 	p.free = append(p.free, g)
+	p.freeSince[g] = time.Now()
+	p.notifyReleased()
+}
+
+// discard accounts for a closed guest no longer in the pool. This must be
+// called under a lock.
+func (p *guestPool[guest]) discard() {
+	p.size--
+	p.notifyReleased()
+}
+
+// notifyReleased wakes up any callers of get waiting for a guest. This must be
+// called under a lock.
+func (p *guestPool[guest]) notifyReleased() {
+	close(p.released)
+	p.released = make(chan struct{})
+}
+
+// hasCycle returns true if the pod is in a scheduling or binding cycle using
+// this pool.
+func (p *guestPool[guest]) hasCycle(podUID types.UID) bool {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	var zero guest
+	return p.scheduledPodUID == podUID || p.binding[podUID] != zero
+}
+
+// inUse returns true if any pod is in a binding cycle using this pool or, when
+// includeScheduling, a scheduling cycle may be.
+func (p *guestPool[guest]) inUse(includeScheduling bool) bool {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	return len(p.binding) > 0 || (includeScheduling && p.scheduledPodUID != "")
+}
+
+// setReportSize replaces the function to report the size of the pool, which
+// is called immediately if not nil.
+func (p *guestPool[guest]) setReportSize(reportSize func(free, binding, scheduling int)) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	p.config.reportSize = reportSize
+	p.report()
+}
+
+// report calls reportSize, if set, with the count of guests in each state.
+// This must be called under a lock.
+func (p *guestPool[guest]) report() {
+	if p.config.reportSize == nil {
+		return
+	}
+	var zero guest
+	scheduling := 0
+	if p.scheduled != zero {
+		scheduling = 1
+	}
+	p.config.reportSize(len(p.free), len(p.binding), scheduling)
+}
+
+// reapEvery calls reap at the interval until the context is done.
+func (p *guestPool[guest]) reapEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			p.reap(now)
+		}
+	}
+}
+
+// reap closes guests in the free pool which have been idle for at least
+// idleTTL, while more than minSize guests exist.
+func (p *guestPool[guest]) reap(now time.Time) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	defer p.report()
+
+	// The free pool is in order of when guests were put, so the oldest are
+	// first.
+	for len(p.free) > 0 && p.size > p.config.minSize {
+		g := p.free[0]
+		if now.Sub(p.freeSince[g]) < p.config.idleTTL {
+			return
+		}
+		p.free = p.free[1:]
+		delete(p.freeSince, g)
+		g.close()
+		p.size--
+	}
+}
+
+// close stops reaping idle guests. Guests are closed with the runtime.
+func (p *guestPool[guest]) close() {
+	if stop := p.stopReaper; stop != nil {
+		stop()
+	}
 }
@@ -20,6 +20,7 @@ import (
 	"context"
 	"reflect"
 	"testing"
+	"time"
 
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/uuid"
@@ -28,7 +29,16 @@ import (
 var ctx = context.Background()
 
 type testGuest struct {
-	val int
+	val    int
+	closed bool
+}
+
+func (g *testGuest) isClosed() bool {
+	return g.closed
+}
+
+func (g *testGuest) close() {
+	g.closed = true
 }
 
 func Test_guestPool_doWithGuest(t *testing.T) {
@@ -38,7 +48,7 @@ func Test_guestPool_doWithGuest(t *testing.T) {
 	pl, err := newGuestPool(ctx, func(context.Context) (*testGuest, error) {
 		counter++
 		return &testGuest{val: counter}, nil
-	})
+	}, guestPoolConfig{})
 	if err != nil {
 		t.Fatalf("failed to get guest instance: %v", err)
 	}
@@ -84,7 +94,9 @@ func Test_guestPool_doWithGuest(t *testing.T) {
 	if err = pl.doWithSchedulingGuest(ctx, uid, func(t *testGuest) {}); err != nil {
 		t.Fatalf("failed to get guest instance: %v", err)
 	}
-	_ = pl.getForBinding(uid)
+	if _, err = pl.getForBinding(ctx, uid); err != nil {
+		t.Fatalf("failed to get guest instance: %v", err)
+	}
 
 	// init while binding is going on will need a new guest
 	if err = pl.doWithGuest(ctx, func(t *testGuest) {
@@ -105,7 +117,7 @@ func Test_guestPool_doWithSchedulingGuest(t *testing.T) {
 	pl, err := newGuestPool(ctx, func(context.Context) (*testGuest, error) {
 		counter++
 		return &testGuest{val: counter}, nil
-	})
+	}, guestPoolConfig{})
 	if err != nil {
 		t.Fatalf("failed to get guest instance: %v", err)
 	}
@@ -145,6 +157,48 @@ func Test_guestPool_doWithSchedulingGuest(t *testing.T) {
 	}
 }
 
+func Test_guestPool_doWithFreeGuest(t *testing.T) {
+	uid := uuid.NewUUID()
+
+	var counter int
+	pl, err := newGuestPool(ctx, func(context.Context) (*testGuest, error) {
+		counter++
+		return &testGuest{val: counter}, nil
+	}, guestPoolConfig{})
+	if err != nil {
+		t.Fatalf("failed to get guest instance: %v", err)
+	}
+
+	var g1 *testGuest
+	if err = pl.doWithSchedulingGuest(ctx, uid, func(t *testGuest) {
+		g1 = t
+	}); err != nil {
+		t.Fatalf("failed to get guest instance: %v", err)
+	}
+
+	// A call during the scheduling cycle must not take its guest.
+	var g2 *testGuest
+	if err = pl.doWithFreeGuest(ctx, func(t *testGuest) {
+		g2 = t
+	}); err != nil {
+		t.Fatalf("failed to get guest instance: %v", err)
+	}
+	if want, have := g1, g2; reflect.DeepEqual(want, have) {
+		t.Fatalf("expected a new guest: want %v, have %v", want, have)
+	}
+	if want, have := uid, pl.scheduledPodUID; want != have {
+		t.Fatalf("unexpected scheduledPodUID: want %v, have %v", want, have)
+	}
+	if want, have := g1, pl.scheduled; !reflect.DeepEqual(want, have) {
+		t.Fatalf("unexpected scheduled: want %v, have %v", want, have)
+	}
+
+	// The guest is returned to the free pool.
+	if want, have := []*testGuest{g2}, pl.free; !reflect.DeepEqual(want, have) {
+		t.Fatalf("unexpected free pool: want %v, have %v", want, have)
+	}
+}
+
 func Test_guestPool_getForBinding(t *testing.T) {
 	uid := uuid.NewUUID()
 	differentUID := uuid.NewUUID()
@@ -153,7 +207,7 @@ func Test_guestPool_getForBinding(t *testing.T) {
 	pl, err := newGuestPool(ctx, func(context.Context) (*testGuest, error) {
 		counter++
 		return &testGuest{val: counter}, nil
-	})
+	}, guestPoolConfig{})
 	if err != nil {
 		t.Fatalf("failed to get guest instance: %v", err)
 	}
@@ -167,7 +221,9 @@ func Test_guestPool_getForBinding(t *testing.T) {
 	}
 
 	// reassign for binding
-	pl.getForBinding(uid)
+	if _, err = pl.getForBinding(ctx, uid); err != nil {
+		t.Fatalf("failed to get guest instance: %v", err)
+	}
 
 	if pl.scheduledPodUID != "" {
 		t.Fatalf("expected no scheduling cycles")
@@ -186,7 +242,10 @@ func Test_guestPool_getForBinding(t *testing.T) {
 	}
 
 	// reassign it for binding
-	g3 := pl.getForBinding(differentUID)
+	g3, err := pl.getForBinding(ctx, differentUID)
+	if err != nil {
+		t.Fatalf("failed to get guest instance: %v", err)
+	}
 	if want, have := g2, g3; !reflect.DeepEqual(want, have) {
 		t.Fatalf("expected the same guest: want %v, have %v", want, have)
 	}
@@ -196,7 +255,9 @@ func Test_guestPool_getForBinding(t *testing.T) {
 	}
 
 	// take it again
-	g3 = pl.getForBinding(differentUID)
+	if g3, err = pl.getForBinding(ctx, differentUID); err != nil {
+		t.Fatalf("failed to get guest instance: %v", err)
+	}
 	if want, have := g2, g3; !reflect.DeepEqual(want, have) {
 		t.Fatalf("expected the same guest: want %v, have %v", want, have)
 	}
@@ -209,7 +270,7 @@ func Test_guestPool_freeFromBinding(t *testing.T) {
 	pl, err := newGuestPool(ctx, func(context.Context) (*testGuest, error) {
 		counter++
 		return &testGuest{val: counter}, nil
-	})
+	}, guestPoolConfig{})
 	if err != nil {
 		t.Fatalf("failed to get guest instance: %v", err)
 	}
@@ -223,7 +284,9 @@ func Test_guestPool_freeFromBinding(t *testing.T) {
 	}
 
 	// reassign for binding
-	pl.getForBinding(uid)
+	if _, err = pl.getForBinding(ctx, uid); err != nil {
+		t.Fatalf("failed to get guest instance: %v", err)
+	}
 
 	// free it from binding
 	pl.freeFromBinding(uid)
@@ -235,3 +298,294 @@ func Test_guestPool_freeFromBinding(t *testing.T) {
 		t.Fatalf("expected no guests in the binding cycle: want %v, have %v", want, have)
 	}
 }
+
+func Test_guestPool_closed(t *testing.T) {
+	uid := uuid.NewUUID()
+
+	var counter int
+	pl, err := newGuestPool(ctx, func(context.Context) (*testGuest, error) {
+		counter++
+		return &testGuest{val: counter}, nil
+	}, guestPoolConfig{})
+	if err != nil {
+		t.Fatalf("failed to get guest instance: %v", err)
+	}
+
+	// a guest closed while scheduling isn't re-used
+	var g1 *testGuest
+	if err = pl.doWithSchedulingGuest(ctx, uid, func(t *testGuest) {
+		g1 = t
+		t.closed = true
+	}); err != nil {
+		t.Fatalf("failed to get guest instance: %v", err)
+	}
+	if pl.scheduled != nil {
+		t.Fatalf("expected the closed guest to be unassigned")
+	}
+	if want, have := uid, pl.scheduledPodUID; want != have {
+		t.Fatalf("unexpected scheduledPodUID: want %v, have %v", want, have)
+	}
+
+	var g2 *testGuest
+	if err = pl.doWithSchedulingGuest(ctx, uid, func(t *testGuest) {
+		g2 = t
+	}); err != nil {
+		t.Fatalf("failed to get guest instance: %v", err)
+	}
+	if g1 == g2 {
+		t.Fatalf("expected a new guest")
+	}
+
+	// a guest closed during the binding cycle is replaced, and isn't returned
+	// to the pool
+	g3, err := pl.getForBinding(ctx, uid)
+	if err != nil {
+		t.Fatalf("failed to get guest instance: %v", err)
+	} else if g2 != g3 {
+		t.Fatalf("expected the scheduled guest")
+	}
+	g3.closed = true
+	g4, err := pl.getForBinding(ctx, uid)
+	if err != nil {
+		t.Fatalf("failed to get guest instance: %v", err)
+	} else if g3 == g4 {
+		t.Fatalf("expected a new guest")
+	}
+	g4.closed = true
+	pl.freeFromBinding(uid)
+	if want, have := 0, len(pl.free); want != have {
+		t.Fatalf("unexpected free pool size: want %v, have %v", want, have)
+	}
+
+	// a guest closed during init isn't returned to the pool
+	if err = pl.doWithGuest(ctx, func(t *testGuest) {
+		t.closed = true
+	}); err != nil {
+		t.Fatalf("failed to get guest instance: %v", err)
+	}
+	if want, have := 0, len(pl.free); want != have {
+		t.Fatalf("unexpected free pool size: want %v, have %v", want, have)
+	}
+}
+
+func Test_guestPool_getForBinding_closedScheduling(t *testing.T) {
+	uid := uuid.NewUUID()
+
+	var counter int
+	pl, err := newGuestPool(ctx, func(context.Context) (*testGuest, error) {
+		counter++
+		return &testGuest{val: counter}, nil
+	}, guestPoolConfig{})
+	if err != nil {
+		t.Fatalf("failed to get guest instance: %v", err)
+	}
+
+	// the last call in the scheduling cycle closed the guest, e.g. a timeout
+	var g1 *testGuest
+	if err = pl.doWithSchedulingGuest(ctx, uid, func(t *testGuest) {
+		g1 = t
+		t.closed = true
+	}); err != nil {
+		t.Fatalf("failed to get guest instance: %v", err)
+	}
+
+	// binding should use a new guest instead of panicking
+	g2, err := pl.getForBinding(ctx, uid)
+	if err != nil {
+		t.Fatalf("failed to get guest instance: %v", err)
+	}
+	if g1 == g2 {
+		t.Fatalf("expected a new guest")
+	}
+	if pl.scheduledPodUID != "" {
+		t.Fatalf("expected no scheduling cycles")
+	}
+	if want, have := map[types.UID]*testGuest{uid: g2}, pl.binding; !reflect.DeepEqual(want, have) {
+		t.Fatalf("unexpected binding cycles: want %v, have %v", want, have)
+	}
+
+	// a pod which was never scheduled is still a bug
+	defer func() {
+		if want, have := "unexpected podUID", recover(); want != have {
+			t.Fatalf("unexpected panic: want %v, have %v", want, have)
+		}
+	}()
+	_, _ = pl.getForBinding(ctx, uuid.NewUUID())
+}
+
+func Test_guestPool_minSize(t *testing.T) {
+	var counter int
+	pl, err := newGuestPool(ctx, func(context.Context) (*testGuest, error) {
+		counter++
+		return &testGuest{val: counter}, nil
+	}, guestPoolConfig{minSize: 3})
+	if err != nil {
+		t.Fatalf("failed to get guest instance: %v", err)
+	}
+
+	if want, have := 3, len(pl.free); want != have {
+		t.Fatalf("unexpected free pool size: want %v, have %v", want, have)
+	}
+	if want, have := 3, pl.size; want != have {
+		t.Fatalf("unexpected pool size: want %v, have %v", want, have)
+	}
+}
+
+func Test_guestPool_maxSize(t *testing.T) {
+	uid := uuid.NewUUID()
+	differentUID := uuid.NewUUID()
+
+	var counter int
+	newGuest := func(context.Context) (*testGuest, error) {
+		counter++
+		return &testGuest{val: counter}, nil
+	}
+
+	t.Run("fail fast", func(t *testing.T) {
+		pl, err := newGuestPool(ctx, newGuest, guestPoolConfig{maxSize: 1})
+		if err != nil {
+			t.Fatalf("failed to get guest instance: %v", err)
+		}
+
+		// the only guest is in the binding cycle
+		if err = pl.doWithSchedulingGuest(ctx, uid, func(*testGuest) {}); err != nil {
+			t.Fatalf("failed to get guest instance: %v", err)
+		}
+		if _, err = pl.getForBinding(ctx, uid); err != nil {
+			t.Fatalf("failed to get guest instance: %v", err)
+		}
+
+		err = pl.doWithSchedulingGuest(ctx, differentUID, func(*testGuest) {
+			t.Fatal("expected no guest")
+		})
+		if want, have := "wasm: guest pool exhausted: 1 guests in use", err.Error(); want != have {
+			t.Fatalf("unexpected error: want %v, have %v", want, have)
+		}
+	})
+
+	t.Run("wait", func(t *testing.T) {
+		pl, err := newGuestPool(ctx, newGuest, guestPoolConfig{maxSize: 1, maxWait: time.Minute})
+		if err != nil {
+			t.Fatalf("failed to get guest instance: %v", err)
+		}
+
+		// the only guest is in the binding cycle
+		var g1 *testGuest
+		if err = pl.doWithSchedulingGuest(ctx, uid, func(g *testGuest) {
+			g1 = g
+		}); err != nil {
+			t.Fatalf("failed to get guest instance: %v", err)
+		}
+		if _, err = pl.getForBinding(ctx, uid); err != nil {
+			t.Fatalf("failed to get guest instance: %v", err)
+		}
+
+		// the next scheduling cycle waits for the binding cycle to end
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			pl.freeFromBinding(uid)
+		}()
+		var g2 *testGuest
+		if err = pl.doWithSchedulingGuest(ctx, differentUID, func(g *testGuest) {
+			g2 = g
+		}); err != nil {
+			t.Fatalf("failed to get guest instance: %v", err)
+		}
+		if g1 != g2 {
+			t.Fatalf("expected the released guest")
+		}
+	})
+
+	t.Run("wait timeout", func(t *testing.T) {
+		pl, err := newGuestPool(ctx, newGuest, guestPoolConfig{maxSize: 1, maxWait: 10 * time.Millisecond})
+		if err != nil {
+			t.Fatalf("failed to get guest instance: %v", err)
+		}
+
+		if err = pl.doWithSchedulingGuest(ctx, uid, func(*testGuest) {}); err != nil {
+			t.Fatalf("failed to get guest instance: %v", err)
+		}
+		if _, err = pl.getForBinding(ctx, uid); err != nil {
+			t.Fatalf("failed to get guest instance: %v", err)
+		}
+
+		err = pl.doWithGuest(ctx, func(*testGuest) {
+			t.Fatal("expected no guest")
+		})
+		if want, have := "wasm: guest pool exhausted: 1 guests in use after waiting 10ms", err.Error(); want != have {
+			t.Fatalf("unexpected error: want %v, have %v", want, have)
+		}
+	})
+
+	t.Run("closed guests don't count", func(t *testing.T) {
+		pl, err := newGuestPool(ctx, newGuest, guestPoolConfig{maxSize: 1})
+		if err != nil {
+			t.Fatalf("failed to get guest instance: %v", err)
+		}
+
+		for i := 0; i < 2; i++ {
+			if err = pl.doWithSchedulingGuest(ctx, uid, func(g *testGuest) {
+				g.closed = true
+			}); err != nil {
+				t.Fatalf("failed to get guest instance: %v", err)
+			}
+		}
+		if want, have := 0, pl.size; want != have {
+			t.Fatalf("unexpected pool size: want %v, have %v", want, have)
+		}
+	})
+}
+
+func Test_guestPool_reap(t *testing.T) {
+	uid := uuid.NewUUID()
+	differentUID := uuid.NewUUID()
+
+	var counter int
+	pl, err := newGuestPool(ctx, func(context.Context) (*testGuest, error) {
+		counter++
+		return &testGuest{val: counter}, nil
+	}, guestPoolConfig{minSize: 1, idleTTL: time.Minute})
+	if err != nil {
+		t.Fatalf("failed to get guest instance: %v", err)
+	}
+	defer pl.close()
+
+	// use three guests: two in binding cycles, then free them
+	for _, podUID := range []types.UID{uid, differentUID} {
+		if err = pl.doWithSchedulingGuest(ctx, podUID, func(*testGuest) {}); err != nil {
+			t.Fatalf("failed to get guest instance: %v", err)
+		}
+		if _, err = pl.getForBinding(ctx, podUID); err != nil {
+			t.Fatalf("failed to get guest instance: %v", err)
+		}
+	}
+	if err = pl.doWithSchedulingGuest(ctx, uuid.NewUUID(), func(*testGuest) {}); err != nil {
+		t.Fatalf("failed to get guest instance: %v", err)
+	}
+	pl.freeFromBinding(uid)
+	pl.freeFromBinding(differentUID)
+	if want, have := 3, pl.size; want != have {
+		t.Fatalf("unexpected pool size: want %v, have %v", want, have)
+	}
+	free := append([]*testGuest{}, pl.free...)
+
+	// guests which haven't been idle long enough are kept
+	pl.reap(time.Now())
+	if want, have := 2, len(pl.free); want != have {
+		t.Fatalf("unexpected free pool size: want %v, have %v", want, have)
+	}
+
+	// idle guests are closed, down to the minimum size
+	pl.reap(time.Now().Add(time.Hour))
+	if want, have := 0, len(pl.free); want != have {
+		t.Fatalf("unexpected free pool size: want %v, have %v", want, have)
+	}
+	if want, have := 1, pl.size; want != have {
+		t.Fatalf("unexpected pool size: want %v, have %v", want, have)
+	}
+	for _, g := range free {
+		if !g.closed {
+			t.Fatalf("expected reaped guest to be closed")
+		}
+	}
+}
@@ -0,0 +1,368 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package wasm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// errInterfacesChanged is returned when a reloaded guest doesn't implement
+// the same plugin interfaces as the one it would replace. The scheduler
+// framework type checks plugins once, so this requires a restart.
+var errInterfacesChanged = errors.New("wasm: guest exports different plugin functions than the one loaded")
+
+// errStorageNotRegistered is returned when a reloaded guest looks up storage
+// objects, but the guest the plugin was created with didn't. Their informers
+// must be registered before the scheduler starts them, so this requires a
+// restart.
+var errStorageNotRegistered = errors.New("wasm: guest looks up storage objects, but their informers weren't registered when the plugin was created")
+
+// errEventsChanged is returned when a reloaded guest doesn't register the same
+// cluster events, or queueing hints, as the one it would replace. The
+// scheduler registers events once, and each queueing hint is identified by the
+// index of its event, so this requires a restart.
+var errEventsChanged = errors.New("wasm: guest registers different cluster events than the one loaded")
+
+// generation is a compiled guest and the pool of its instances. Reloading the
+// guest creates a new generation.
+type generation struct {
+	runtime     wazero.Runtime
+	guestModule wazero.CompiledModule
+	pool        *guestPool[*guest]
+
+	// sortMux guards sortGuest.
+	sortMux sync.Mutex
+	// sortGuest is held for framework.QueueSortPlugin calls, if the guest
+	// implements it. The scheduling queue calls Less under its lock, so it
+	// must not wait on the pool or instantiate a guest.
+	sortGuest *guest
+}
+
+// close closes the pool and the runtime, which closes all guests.
+func (gen *generation) close() error {
+	gen.pool.close()
+	return gen.runtime.Close(context.Background())
+}
+
+// newGeneration creates a pool of guests compiled in the runtime. The caller
+// must close the runtime on error.
+func (pl *wasmPlugin) newGeneration(ctx context.Context, runtime wazero.Runtime, guestModule wazero.CompiledModule) (*generation, error) {
+	gen := &generation{runtime: runtime, guestModule: guestModule}
+	newGuest := func(ctx context.Context) (*guest, error) {
+		return pl.newGuest(ctx, gen)
+	}
+
+	var err error
+	if gen.pool, err = newGuestPool(ctx, newGuest, pl.poolConfig); err != nil {
+		return nil, fmt.Errorf("failed to create a guest pool: %w", err)
+	}
+	if pl.guestInterfaces&iQueueSortPlugin != 0 {
+		if gen.sortGuest, err = newGuest(ctx); err != nil {
+			return nil, fmt.Errorf("failed to create a queue sort guest: %w", err)
+		}
+	}
+	return gen, nil
+}
+
+// doWithSortGuest runs the function with the guest held for queue sort calls.
+// It is only replaced once closed, for example after a trap.
+func (pl *wasmPlugin) doWithSortGuest(ctx context.Context, gen *generation, fn func(*guest)) error {
+	gen.sortMux.Lock()
+	defer gen.sortMux.Unlock()
+
+	if gen.sortGuest == nil || gen.sortGuest.isClosed() {
+		g, err := pl.newGuest(ctx, gen)
+		if err != nil {
+			return err
+		}
+		gen.sortGuest = g
+	}
+	fn(gen.sortGuest)
+	return nil
+}
+
+// current returns the current generation.
+func (pl *wasmPlugin) current() *generation {
+	pl.genMux.Lock()
+	defer pl.genMux.Unlock()
+
+	return pl.gen
+}
+
+// schedulingPool returns the pool for a call in the scheduling cycle of the
+// pod. This is the pool of the current generation, unless the cycle started
+// on a retired one.
+//
+// Scheduling cycles are sequential, so a call for a pod not in any cycle
+// means the scheduling cycles of retired generations are over. Any retired
+// generation without binding cycles is closed at this point.
+func (pl *wasmPlugin) schedulingPool(podUID types.UID) *guestPool[*guest] {
+	pl.genMux.Lock()
+	defer pl.genMux.Unlock()
+
+	pool := pl.gen.pool
+	inCycle := pool.hasCycle(podUID)
+	for _, gen := range pl.retired {
+		if gen.pool.hasCycle(podUID) {
+			pool, inCycle = gen.pool, true
+		}
+	}
+
+	if !inCycle {
+		pl.closeRetired(false)
+	}
+	return pool
+}
+
+// bindingPool returns the pool for a call in the binding cycle of the pod.
+// This is the pool of the current generation, unless the cycle started on a
+// retired one.
+func (pl *wasmPlugin) bindingPool(podUID types.UID) *guestPool[*guest] {
+	pl.genMux.Lock()
+	defer pl.genMux.Unlock()
+
+	for _, gen := range pl.retired {
+		if gen.pool.hasCycle(podUID) {
+			return gen.pool
+		}
+	}
+	return pl.gen.pool
+}
+
+// freeFromBinding should be called when a binding cycle ends for any reason.
+// If the binding cycle was the last using a retired generation, it is closed.
+func (pl *wasmPlugin) freeFromBinding(podUID types.UID) {
+	pl.bindingPool(podUID).freeFromBinding(podUID)
+
+	pl.genMux.Lock()
+	defer pl.genMux.Unlock()
+
+	pl.closeRetired(true)
+}
+
+// closeRetired closes any retired generation not in use. When
+// includeScheduling, a generation which may be in a scheduling cycle is also
+// considered in use. This must be called under a lock.
+func (pl *wasmPlugin) closeRetired(includeScheduling bool) {
+	retired := pl.retired[:0]
+	for _, gen := range pl.retired {
+		if gen.pool.inUse(includeScheduling) {
+			retired = append(retired, gen)
+		} else {
+			_ = gen.close()
+		}
+	}
+	pl.retired = retired
+}
+
+// startReloading reloads the guest when the content at GuestURL, or
+// GuestConfigURL, changes from its version, checking at the interval until
+// the plugin is closed.
+func (pl *wasmPlugin) startReloading(ctx context.Context, fetcher *guestFetcher, interval time.Duration, version, configVersion string) {
+	logger := klog.FromContext(ctx).WithValues("plugin", pl.pluginName, "guestURL", pl.config.GuestURL)
+
+	var reloadCtx context.Context
+	reloadCtx, pl.stopReloading = context.WithCancel(context.Background())
+	reloadCtx = klog.NewContext(reloadCtx, logger)
+	go pl.reloadEvery(reloadCtx, fetcher, interval, version, configVersion)
+}
+
+// reloadEvery calls reloadIfChanged, and reloadConfigIfChanged if there is a
+// GuestConfigURL, at the interval until the context is done.
+func (pl *wasmPlugin) reloadEvery(ctx context.Context, fetcher *guestFetcher, interval time.Duration, version, configVersion string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			version = pl.reloadIfChanged(ctx, fetcher, version)
+			if pl.config.GuestConfigURL != "" {
+				configVersion = pl.reloadConfigIfChanged(ctx, fetcher, configVersion)
+			}
+		}
+	}
+}
+
+// reloadIfChanged reloads the guest if the content at GuestURL changed from
+// the version, and returns the version read.
+//
+// The version returned changes even if the guest was rejected, so that it
+// isn't retried until the content changes again. The exception is a guest
+// which isn't verified, which is retried in case its signature is updated.
+func (pl *wasmPlugin) reloadIfChanged(ctx context.Context, fetcher *guestFetcher, version string) string {
+	logger := klog.FromContext(ctx)
+	guestBin, newVersion, err := fetcher.getIfChanged(ctx, pl.config.GuestURL, version)
+	if err != nil {
+		logger.Error(err, "Failed to read guest for reload")
+		guestReloads.WithLabelValues(pl.pluginName, reloadResultError).Inc()
+		return version
+	} else if guestBin == nil {
+		return version // unchanged
+	}
+
+	if err = pl.verifier.verify(ctx, fetcher, guestBin); errors.Is(err, errGuestNotVerified) {
+		logger.Error(err, "Rejected guest reload")
+		guestReloads.WithLabelValues(pl.pluginName, reloadResultRejected).Inc()
+		return version
+	} else if err != nil {
+		logger.Error(err, "Failed to verify guest for reload")
+		guestReloads.WithLabelValues(pl.pluginName, reloadResultError).Inc()
+		return version
+	}
+
+	if err = pl.reload(ctx, guestBin, pl.guestConfig); isReloadRejected(err) {
+		logger.Error(err, "Rejected guest reload")
+		guestReloads.WithLabelValues(pl.pluginName, reloadResultRejected).Inc()
+	} else if err != nil {
+		logger.Error(err, "Failed to reload guest")
+		guestReloads.WithLabelValues(pl.pluginName, reloadResultError).Inc()
+	} else {
+		logger.Info("Reloaded guest", "version", newVersion)
+		guestReloads.WithLabelValues(pl.pluginName, reloadResultSuccess).Inc()
+	}
+	return newVersion
+}
+
+// reloadConfigIfChanged reloads the current guest with the content at
+// GuestConfigURL, if it changed from the version, and returns the version
+// read. Like reloadIfChanged, the version returned changes even if the
+// reload failed.
+func (pl *wasmPlugin) reloadConfigIfChanged(ctx context.Context, fetcher *guestFetcher, version string) string {
+	logger := klog.FromContext(ctx).WithValues("guestConfigURL", pl.config.GuestConfigURL)
+	guestConfig, newVersion, err := fetcher.getIfChanged(ctx, pl.config.GuestConfigURL, version)
+	if err != nil {
+		logger.Error(err, "Failed to read guest config for reload")
+		guestReloads.WithLabelValues(pl.pluginName, reloadResultError).Inc()
+		return version
+	} else if guestConfig == nil {
+		return version // unchanged
+	}
+
+	// Guests usually read their config once, so instantiate new ones.
+	if err = pl.reload(ctx, pl.guestBin, string(guestConfig)); isReloadRejected(err) {
+		logger.Error(err, "Rejected guest config reload")
+		guestReloads.WithLabelValues(pl.pluginName, reloadResultRejected).Inc()
+	} else if err != nil {
+		logger.Error(err, "Failed to reload guest config")
+		guestReloads.WithLabelValues(pl.pluginName, reloadResultError).Inc()
+	} else {
+		logger.Info("Reloaded guest config", "version", newVersion)
+		guestReloads.WithLabelValues(pl.pluginName, reloadResultSuccess).Inc()
+	}
+	return newVersion
+}
+
+// isReloadRejected returns true if the error is because the reloaded guest
+// isn't compatible with the plugin, as opposed to failing to load.
+func isReloadRejected(err error) bool {
+	return errors.Is(err, errInterfacesChanged) || errors.Is(err, errStorageNotRegistered) || errors.Is(err, errEventsChanged)
+}
+
+// reload compiles the guest and, if it implements the same plugin interfaces
+// and registers the same cluster events, makes it the current generation with
+// the config. The prior generation is retired, and closed once its cycles in
+// progress finish.
+func (pl *wasmPlugin) reload(ctx context.Context, guestBin []byte, guestConfig string) error {
+	config := pl.config
+	config.GuestConfig = guestConfig
+	storage := func() (*storageListers, error) {
+		if pl.storage == nil && pl.handle != nil {
+			return nil, errStorageNotRegistered
+		}
+		return pl.storage, nil
+	}
+	runtime, guestModule, err := prepareRuntime(ctx, guestBin, config, pl.handle, storage, pl.informers)
+	if err != nil {
+		return err
+	}
+
+	guestInterfaces, err := detectInterfaces(guestModule.ExportedFunctions())
+	if err == nil && guestInterfaces != pl.guestInterfaces {
+		err = errInterfacesChanged
+	}
+	var gen *generation
+	if err == nil {
+		gen, err = pl.newGeneration(ctx, runtime, guestModule)
+	}
+	if err != nil {
+		_ = runtime.Close(ctx)
+		return err
+	}
+
+	// Hold the lock until the generation is swapped, so that EventsToRegister
+	// can't see events of the prior one.
+	pl.eventsMux.Lock()
+	defer pl.eventsMux.Unlock()
+
+	if err = pl.checkEvents(ctx, gen); err != nil {
+		_ = gen.close()
+		return err
+	}
+
+	pl.genMux.Lock()
+	defer pl.genMux.Unlock()
+
+	old := pl.gen
+	pl.gen = gen
+	pl.retired = append(pl.retired, old)
+
+	// Only report the size of the current pool, as the metric isn't labeled
+	// by generation.
+	old.pool.setReportSize(nil)
+	gen.pool.setReportSize(pl.poolConfig.reportSize)
+
+	pl.guestConfig = guestConfig
+	if pl.config.GuestConfigURL != "" {
+		pl.guestBin = guestBin
+	}
+	return nil
+}
+
+// checkEvents returns errEventsChanged if the scheduler registered the events
+// of the current generation, and the guests of gen return different ones. This
+// must be called under eventsMux.
+func (pl *wasmPlugin) checkEvents(ctx context.Context, gen *generation) error {
+	if pl.events == nil {
+		return nil // not registered, yet
+	}
+
+	ctx = context.WithValue(ctx, stackKey{}, &stack{})
+	var events *registeredEvents
+	var guestErr error
+	if err := gen.pool.doWithGuest(ctx, func(g *guest) {
+		events, guestErr = g.registeredEvents(ctx)
+	}); err != nil {
+		return err
+	} else if guestErr != nil {
+		return guestErr
+	}
+
+	if !events.equal(pl.events) {
+		return errEventsChanged
+	}
+	return nil
+}
@@ -0,0 +1,299 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package wasm
+
+import (
+	"io"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/component-base/metrics/testutil"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+
+	"sigs.k8s.io/kube-scheduler-wasm-extension/scheduler/test"
+)
+
+func Test_reload(t *testing.T) {
+	guestPath := path.Join(t.TempDir(), "guest.wasm")
+	writeGuest(t, guestPath, test.URLTestFilterFromGlobal, time.Unix(1, 0))
+
+	// Use a long interval, so that the test controls when to reload.
+	p, err := NewFromConfig(ctx, "reload", PluginConfig{
+		GuestURL:       "file://" + guestPath,
+		ReloadInterval: metav1.Duration{Duration: time.Hour},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.(io.Closer).Close()
+	pl := p.(ProfilerSupport).plugin()
+
+	fetcher, err := newGuestFetcher(pl.config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, version, err := fetcher.getIfChanged(ctx, pl.config.GuestURL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filter := func(podUID types.UID) *framework.Status {
+		pod := test.PodSmall.DeepCopy()
+		pod.UID = podUID
+		ni := framework.NewNodeInfo()
+		ni.SetNode(test.NodeSmall)
+		return pl.Filter(ctx, nil, pod, ni)
+	}
+	reloads := func(result string) float64 {
+		v, err := testutil.GetCounterMetricValue(guestReloads.WithLabelValues("reload", result))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	t.Run("unchanged", func(t *testing.T) {
+		gen := pl.current()
+		if have := pl.reloadIfChanged(ctx, fetcher, version); have != version {
+			t.Fatalf("unexpected version: want %s, have %s", version, have)
+		}
+		if pl.current() != gen {
+			t.Fatal("expected the guest not to be reloaded")
+		}
+	})
+
+	t.Run("interfaces changed", func(t *testing.T) {
+		gen := pl.current()
+		rejected := reloads(reloadResultRejected)
+
+		writeGuest(t, guestPath, test.URLTestScoreFromGlobal, time.Unix(2, 0))
+		version = pl.reloadIfChanged(ctx, fetcher, version)
+
+		if pl.current() != gen {
+			t.Fatal("expected the guest not to be reloaded")
+		}
+		if have := reloads(reloadResultRejected); have != rejected+1 {
+			t.Fatalf("unexpected rejected reloads: want %v, have %v", rejected+1, have)
+		}
+		if s := filter("a"); !s.IsSuccess() {
+			t.Fatalf("unexpected status: %v", s)
+		}
+	})
+
+	t.Run("binding cycle finishes on old guest", func(t *testing.T) {
+		old := pl.current()
+
+		// Pod a is in the binding cycle, for example, waiting in Permit.
+		if _, err := pl.bindingPool("a").getForBinding(ctx, "a"); err != nil {
+			t.Fatal(err)
+		}
+
+		// The new guest panics on filter, so that we can tell it is in use.
+		writeGuest(t, guestPath, test.URLErrorPanicOnFilter, time.Unix(3, 0))
+		succeeded := reloads(reloadResultSuccess)
+		version = pl.reloadIfChanged(ctx, fetcher, version)
+		if have := reloads(reloadResultSuccess); have != succeeded+1 {
+			t.Fatalf("unexpected successful reloads: want %v, have %v", succeeded+1, have)
+		}
+		if pl.current() == old {
+			t.Fatal("expected the guest to be reloaded")
+		}
+
+		// A new scheduling cycle uses the new guest.
+		if s := filter("b"); s.Code() != framework.Error {
+			t.Fatalf("expected the new guest to panic, have %v", s)
+		}
+
+		// The binding cycle still uses the old guest, which isn't closed.
+		if pool := pl.bindingPool("a"); pool != old.pool {
+			t.Fatal("expected the binding cycle to use the old guest")
+		}
+		if g := old.pool.binding["a"]; g == nil || g.isClosed() {
+			t.Fatal("expected the binding guest to be open")
+		}
+
+		// When the binding cycle ends, the old guest is closed.
+		pl.freeFromBinding("a")
+		if len(pl.retired) != 0 {
+			t.Fatalf("expected no retired generations, have %d", len(pl.retired))
+		}
+		if len(old.pool.free) == 0 || !old.pool.free[0].isClosed() {
+			t.Fatal("expected the old guests to be closed")
+		}
+	})
+}
+
+func Test_reload_closedWhenSchedulingEnds(t *testing.T) {
+	guestPath := path.Join(t.TempDir(), "guest.wasm")
+	writeGuest(t, guestPath, test.URLTestFilterFromGlobal, time.Unix(1, 0))
+
+	p, err := NewFromConfig(ctx, "reload", PluginConfig{
+		GuestURL:       "file://" + guestPath,
+		ReloadInterval: metav1.Duration{Duration: time.Hour},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.(io.Closer).Close()
+	pl := p.(ProfilerSupport).plugin()
+
+	ni := framework.NewNodeInfo()
+	ni.SetNode(test.NodeSmall)
+	pod := test.PodSmall
+
+	// Start a scheduling cycle, then reload.
+	if s := pl.Filter(ctx, nil, pod, ni); !s.IsSuccess() {
+		t.Fatalf("unexpected status: %v", s)
+	}
+	old := pl.current()
+	writeGuest(t, guestPath, test.URLErrorPanicOnFilter, time.Unix(2, 0))
+	_ = pl.reloadIfChanged(ctx, &guestFetcher{}, "")
+
+	// The rest of the scheduling cycle uses the old guest.
+	if s := pl.Filter(ctx, nil, pod, ni); !s.IsSuccess() {
+		t.Fatalf("unexpected status: %v", s)
+	}
+	if len(pl.retired) != 1 {
+		t.Fatalf("expected one retired generation, have %d", len(pl.retired))
+	}
+
+	// The next scheduling cycle uses the new guest, and closes the old one.
+	otherPod := pod.DeepCopy()
+	otherPod.UID = "other"
+	if s := pl.Filter(ctx, nil, otherPod, ni); s.Code() != framework.Error {
+		t.Fatalf("expected the new guest to panic, have %v", s)
+	}
+	if len(pl.retired) != 0 {
+		t.Fatalf("expected no retired generations, have %d", len(pl.retired))
+	}
+	if !old.pool.scheduled.isClosed() {
+		t.Fatal("expected the old guest to be closed")
+	}
+}
+
+func Test_reload_eventsChanged(t *testing.T) {
+	guestPath := path.Join(t.TempDir(), "guest.wasm")
+	writeGuest(t, guestPath, test.URLTestQueueingHintFromGlobal, time.Unix(1, 0))
+
+	p, err := NewFromConfig(ctx, "reload", PluginConfig{
+		GuestURL:       "file://" + guestPath,
+		ReloadInterval: metav1.Duration{Duration: time.Hour},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.(io.Closer).Close()
+	pl := p.(ProfilerSupport).plugin()
+
+	rejected, err := testutil.GetCounterMetricValue(guestReloads.WithLabelValues("reload", reloadResultRejected))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The scheduler registers the events, and their queueing hints, once.
+	if _, err = pl.EventsToRegister(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The new guest registers the same events, in a different order.
+	gen := pl.current()
+	writeGuest(t, guestPath, test.URLTestQueueingHintReordered, time.Unix(2, 0))
+	_ = pl.reloadIfChanged(ctx, &guestFetcher{}, "")
+
+	if pl.current() != gen {
+		t.Fatal("expected the guest not to be reloaded")
+	}
+	have, err := testutil.GetCounterMetricValue(guestReloads.WithLabelValues("reload", reloadResultRejected))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have != rejected+1 {
+		t.Fatalf("unexpected rejected reloads: want %v, have %v", rejected+1, have)
+	}
+	if len(pl.retired) != 0 {
+		t.Fatalf("expected no retired generations, have %d", len(pl.retired))
+	}
+
+	// A guest registering the same events is reloaded.
+	writeGuest(t, guestPath, test.URLTestQueueingHintFromGlobal, time.Unix(3, 0))
+	_ = pl.reloadIfChanged(ctx, &guestFetcher{}, "")
+
+	if pl.current() == gen {
+		t.Fatal("expected the guest to be reloaded")
+	}
+}
+
+func Test_reload_storageNotRegistered(t *testing.T) {
+	guestPath := path.Join(t.TempDir(), "guest.wasm")
+	writeGuest(t, guestPath, test.URLTestFilterFromGlobal, time.Unix(1, 0))
+
+	// The scheduler starts the informers after plugins are created.
+	factory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	handle := &test.FakeHandle{SharedInformerFactoryValue: factory}
+	p, err := NewFromConfig(ctx, "reload", PluginConfig{
+		GuestURL:       "file://" + guestPath,
+		ReloadInterval: metav1.Duration{Duration: time.Hour},
+	}, handle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.(io.Closer).Close()
+	pl := p.(ProfilerSupport).plugin()
+
+	rejected, err := testutil.GetCounterMetricValue(guestReloads.WithLabelValues("reload", reloadResultRejected))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The new guest looks up storage objects, but the first didn't.
+	gen := pl.current()
+	writeGuest(t, guestPath, test.URLTestFilterStorage, time.Unix(2, 0))
+	_ = pl.reloadIfChanged(ctx, &guestFetcher{}, "")
+
+	if pl.current() != gen {
+		t.Fatal("expected the guest not to be reloaded")
+	}
+	have, err := testutil.GetCounterMetricValue(guestReloads.WithLabelValues("reload", reloadResultRejected))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have != rejected+1 {
+		t.Fatalf("unexpected rejected reloads: want %v, have %v", rejected+1, have)
+	}
+}
+
+// writeGuest copies the guest at the file URL to the path, with the
+// modification time.
+func writeGuest(t *testing.T, guestPath, url string, modTime time.Time) {
+	t.Helper()
+	bin, err := os.ReadFile(url[7:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(guestPath, bin, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chtimes(guestPath, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
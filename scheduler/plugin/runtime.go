@@ -19,6 +19,9 @@ package wasm
 import (
 	"context"
 	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
 
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/api"
@@ -26,14 +29,47 @@ import (
 	"k8s.io/kubernetes/pkg/scheduler/framework"
 )
 
+// maxMemoryLimitPages is the maximum memory of a guest: 4GiB in 64KiB pages.
+const maxMemoryLimitPages = 65536
+
 // prepareRuntime compiles the guest and instantiates any host modules it needs.
-func prepareRuntime(ctx context.Context, guestBin []byte, logSeverity int32, guestConfig string, handle framework.Handle) (runtime wazero.Runtime, guest wazero.CompiledModule, err error) {
-	// Create the runtime, which when closed releases any resources associated with it.
-	runtime = wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+// The storage function is only called when the guest looks up storage objects.
+func prepareRuntime(ctx context.Context, guestBin []byte, config PluginConfig, handle framework.Handle, storage func() (*storageListers, error), informers *informerListers) (runtime wazero.Runtime, guest wazero.CompiledModule, err error) {
+	runtimeConfig, err := newRuntimeConfig(config.Engine)
+	if err != nil {
+		return nil, nil, err
+	}
+	runtimeConfig = runtimeConfig.
 		// Here are settings required by the wasm profiler wzprof:
 		// * DebugInfo is already true by default, so no impact.
 		// * CustomSections buffers more data into memory at compile time.
-		WithDebugInfoEnabled(true).WithCustomSections(true))
+		WithDebugInfoEnabled(true).WithCustomSections(true)
+
+	// Timeouts are implemented by closing the guest when the context is done.
+	// This adds overhead to guest calls, so only enable it when needed.
+	if len(config.Timeouts) > 0 {
+		runtimeConfig = runtimeConfig.WithCloseOnContextDone(true)
+	}
+
+	// Memory limits are enforced per instance, so only validate them here.
+	limit := config.MemoryLimitPages
+	if limit > maxMemoryLimitPages {
+		return nil, nil, fmt.Errorf("wasm: memoryLimitPages %d is larger than the maximum %d", limit, maxMemoryLimitPages)
+	}
+
+	var cache *sharedCompilationCache
+	if dir := config.CompilationCacheDir; dir != "" {
+		if cache, err = acquireCompilationCache(dir); err != nil {
+			return nil, nil, fmt.Errorf("wasm: error creating compilation cache in %s: %w", dir, err)
+		}
+		runtimeConfig = runtimeConfig.WithCompilationCache(cache.CompilationCache)
+	}
+
+	// Create the runtime, which when closed releases any resources associated with it.
+	runtime = wazero.NewRuntimeWithConfig(ctx, runtimeConfig)
+	if cache != nil {
+		runtime = &cachedRuntime{Runtime: runtime, cache: cache}
+	}
 
 	// Close the runtime on any error
 	defer func() {
@@ -48,6 +84,13 @@ func prepareRuntime(ctx context.Context, guestBin []byte, logSeverity int32, gue
 		return
 	}
 
+	// A guest can't start if its initial memory is over the limit. Any
+	// declared maximum is fine, as growth is limited when instantiated.
+	if minPages := guest.ExportedMemories()[guestExportMemory].Min(); limit > 0 && minPages > limit {
+		err = fmt.Errorf("wasm: guest memory min %d pages is over memoryLimitPages %d", minPages, limit)
+		return
+	}
+
 	// Detect and handle any host imports or lack thereof.
 	imports := detectImports(guest.ImportedFunctions())
 	if imports&importWasiP1 != 0 {
@@ -63,13 +106,19 @@ func prepareRuntime(ctx context.Context, guestBin []byte, logSeverity int32, gue
 		}
 	}
 	if imports&importK8sKlog != 0 {
-		if _, err = instantiateHostKlog(ctx, runtime, logSeverity); err != nil {
+		if _, err = instantiateHostKlog(ctx, runtime, config.LogSeverity); err != nil {
 			err = fmt.Errorf("wasm: error instantiating klog functions: %w", err)
 			return
 		}
 	}
 	if imports&importK8sScheduler != 0 {
-		if _, err = instantiateHostScheduler(ctx, runtime, guestConfig, handle); err != nil {
+		var storageListers *storageListers
+		if imports&importK8sSchedulerStorage != 0 {
+			if storageListers, err = storage(); err != nil {
+				return
+			}
+		}
+		if _, err = instantiateHostScheduler(ctx, runtime, config.GuestConfig, handle, storageListers, informers); err != nil {
 			err = fmt.Errorf("wasm: error instantiating scheduler host functions: %w", err)
 			return
 		}
@@ -77,6 +126,80 @@ func prepareRuntime(ctx context.Context, guestBin []byte, logSeverity int32, gue
 	return
 }
 
+var (
+	compilationCachesMu sync.Mutex
+	// compilationCaches are shared by directory, so that plugins in different
+	// profiles don't race writing the same files.
+	compilationCaches = map[string]*sharedCompilationCache{}
+)
+
+// sharedCompilationCache is a wazero.CompilationCache shared by all runtimes
+// using the same directory.
+type sharedCompilationCache struct {
+	wazero.CompilationCache
+	dir string
+	// refs is the count of runtimes using the cache, guarded by
+	// compilationCachesMu.
+	refs int
+}
+
+// acquireCompilationCache returns the compilation cache backed by the
+// directory, creating it if needed. Callers must release the cache after
+// closing any runtime that uses it.
+func acquireCompilationCache(dir string) (*sharedCompilationCache, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	compilationCachesMu.Lock()
+	defer compilationCachesMu.Unlock()
+
+	cache, ok := compilationCaches[dir]
+	if !ok {
+		c, err := wazero.NewCompilationCacheWithDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		cache = &sharedCompilationCache{CompilationCache: c, dir: dir}
+		compilationCaches[dir] = cache
+	}
+	cache.refs++
+	return cache, nil
+}
+
+// release closes the cache when no runtime uses it anymore. The cache can't
+// be closed sooner, as that also closes the compiled code of any runtime
+// still using it.
+func (c *sharedCompilationCache) release(ctx context.Context) error {
+	compilationCachesMu.Lock()
+	defer compilationCachesMu.Unlock()
+
+	if c.refs--; c.refs > 0 {
+		return nil
+	}
+	delete(compilationCaches, c.dir)
+	return c.CompilationCache.Close(ctx)
+}
+
+// cachedRuntime releases its compilation cache when closed.
+type cachedRuntime struct {
+	wazero.Runtime
+	cache *sharedCompilationCache
+	once  sync.Once
+}
+
+// Close implements the same method as documented on wazero.Runtime.
+func (r *cachedRuntime) Close(ctx context.Context) (err error) {
+	err = r.Runtime.Close(ctx)
+	r.once.Do(func() {
+		if releaseErr := r.cache.release(ctx); err == nil {
+			err = releaseErr
+		}
+	})
+	return
+}
+
 type imports uint
 
 const (
@@ -84,12 +207,13 @@ const (
 	importK8sApi
 	importK8sKlog
 	importK8sScheduler
+	importK8sSchedulerStorage
 )
 
 func detectImports(importedFns []api.FunctionDefinition) imports {
 	var imports imports
 	for _, f := range importedFns {
-		moduleName, _, _ := f.Import()
+		moduleName, name, _ := f.Import()
 		switch moduleName {
 		case k8sApi:
 			imports |= importK8sApi
@@ -97,6 +221,9 @@ func detectImports(importedFns []api.FunctionDefinition) imports {
 			imports |= importK8sKlog
 		case k8sScheduler:
 			imports |= importK8sScheduler
+			if strings.HasPrefix(name, k8sSchedulerStoragePrefix) {
+				imports |= importK8sSchedulerStorage
+			}
 		case wasi_snapshot_preview1.ModuleName:
 			imports |= importWasiP1
 		}
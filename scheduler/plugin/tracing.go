@@ -0,0 +1,95 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package wasm
+
+import (
+	"context"
+
+	wazeroapi "github.com/tetratelabs/wazero/api"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracerName is the instrumentation scope of spans created by this package.
+const tracerName = "sigs.k8s.io/kube-scheduler-wasm-extension/scheduler/plugin"
+
+// Attribute keys added to spans.
+const (
+	attributePlugin   = attribute.Key("k8s.scheduler.plugin")
+	attributePodUID   = attribute.Key("k8s.pod.uid")
+	attributeNodeName = attribute.Key("k8s.node.name")
+)
+
+// spanAttributesKey is a context.Context value associated with the attributes
+// of the guest span, so that host function spans can re-use them.
+type spanAttributesKey struct{}
+
+// startGuestSpan starts a span for a call to the guest export name, using
+// the tracer provider of the span in the context. When the context isn't
+// recording a trace, this returns the context and a no-op span.
+//
+// Spans are tagged with the plugin name, and the pod UID and node name in
+// the stack, if set.
+func startGuestSpan(ctx context.Context, pluginName, name string) (context.Context, trace.Span) {
+	parent := trace.SpanFromContext(ctx)
+	if !parent.IsRecording() {
+		return ctx, noop.Span{}
+	}
+
+	attrs := []attribute.KeyValue{attributePlugin.String(pluginName)}
+	if params, ok := ctx.Value(stackKey{}).(*stack); ok {
+		if pod := params.currentPod; pod != nil {
+			attrs = append(attrs, attributePodUID.String(string(pod.UID)))
+		}
+		if nodeName := params.currentNodeName; nodeName != "" {
+			attrs = append(attrs, attributeNodeName.String(nodeName))
+		}
+	}
+	ctx = context.WithValue(ctx, spanAttributesKey{}, attrs)
+	return parent.TracerProvider().Tracer(tracerName).
+		Start(ctx, "wasm.guest."+name, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends the span, recording the error, if any.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// traceHostFunction wraps the host function fn, so that calls to it from a
+// traced guest call are recorded as child spans.
+func traceHostFunction(moduleName, name string, fn wazeroapi.GoModuleFunc) wazeroapi.GoModuleFunc {
+	spanName := "wasm.host." + moduleName + "." + name
+	return func(ctx context.Context, mod wazeroapi.Module, stack []uint64) {
+		parent := trace.SpanFromContext(ctx)
+		if !parent.IsRecording() {
+			fn(ctx, mod, stack)
+			return
+		}
+
+		attrs, _ := ctx.Value(spanAttributesKey{}).([]attribute.KeyValue)
+		ctx, span := parent.TracerProvider().Tracer(tracerName).
+			Start(ctx, spanName, trace.WithAttributes(attrs...))
+		defer span.End()
+		fn(ctx, mod, stack)
+	}
+}
@@ -0,0 +1,152 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package wasm
+
+import (
+	"io"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+
+	"sigs.k8s.io/kube-scheduler-wasm-extension/scheduler/test"
+)
+
+func Test_tracing(t *testing.T) {
+	tests := []struct {
+		name              string
+		guestURL          string
+		expectedCode      framework.Code
+		expectedHostSpans []string
+	}{
+		{
+			name:         "host functions",
+			guestURL:     test.URLTestFilter,
+			expectedCode: framework.Success,
+			expectedHostSpans: []string{
+				"wasm.host.k8s.io/scheduler.currentPod",
+				"wasm.host.k8s.io/scheduler.currentNodeName",
+				"wasm.host.k8s.io/api.node",
+			},
+		},
+		{
+			name:         "trap",
+			guestURL:     test.URLErrorPanicOnFilter,
+			expectedCode: framework.Error,
+		},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewFromConfig(ctx, "tracing", PluginConfig{GuestURL: tt.guestURL}, newTracingHandle())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.(io.Closer).Close()
+
+			recorder := tracetest.NewSpanRecorder()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			tracedCtx, root := tp.Tracer("test").Start(ctx, "root")
+
+			ni := framework.NewNodeInfo()
+			ni.SetNode(test.NodeSmall)
+			s := p.(framework.FilterPlugin).Filter(tracedCtx, nil, test.PodSmall, ni)
+			root.End()
+			if s.Code() != tt.expectedCode {
+				t.Fatalf("unexpected status code: want %d, have %d (message: %v)", tt.expectedCode, s.Code(), s.Message())
+			}
+
+			spans := map[string]sdktrace.ReadOnlySpan{}
+			for _, span := range recorder.Ended() {
+				spans[span.Name()] = span
+			}
+
+			guestSpan, ok := spans["wasm.guest.filter"]
+			if !ok {
+				t.Fatalf("missing guest span in %v", spans)
+			} else if want, have := root.SpanContext().SpanID(), guestSpan.Parent().SpanID(); want != have {
+				t.Fatalf("unexpected guest span parent: want %v, have %v", want, have)
+			}
+			wantAttrs := []attribute.KeyValue{
+				attributePlugin.String("tracing"),
+				attributePodUID.String(string(test.PodSmall.UID)),
+				attributeNodeName.String(test.NodeSmall.Name),
+			}
+			assertAttributes(t, guestSpan, wantAttrs)
+
+			if tt.expectedCode == framework.Error {
+				if want, have := codes.Error, guestSpan.Status().Code; want != have {
+					t.Fatalf("unexpected guest span status: want %v, have %v", want, have)
+				}
+			}
+
+			for _, name := range tt.expectedHostSpans {
+				hostSpan, ok := spans[name]
+				if !ok {
+					t.Fatalf("missing host span %s in %v", name, spans)
+				} else if want, have := guestSpan.SpanContext().SpanID(), hostSpan.Parent().SpanID(); want != have {
+					t.Fatalf("unexpected host span parent: want %v, have %v", want, have)
+				}
+				assertAttributes(t, hostSpan, wantAttrs)
+			}
+		})
+	}
+}
+
+func Test_tracing_untraced(t *testing.T) {
+	p, err := NewFromConfig(ctx, "tracing", PluginConfig{GuestURL: test.URLTestFilter}, newTracingHandle())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.(io.Closer).Close()
+
+	// A context without a span doesn't start any, so there's nothing to
+	// record, and the call still succeeds.
+	ni := framework.NewNodeInfo()
+	ni.SetNode(test.NodeSmall)
+	if s := p.(framework.FilterPlugin).Filter(ctx, nil, test.PodSmall, ni); !s.IsSuccess() {
+		t.Fatalf("unexpected status: %v", s)
+	}
+}
+
+// newTracingHandle returns a handle which can look up test.NodeSmall.
+func newTracingHandle() framework.Handle {
+	ni := framework.NewNodeInfo()
+	ni.SetNode(test.NodeSmall)
+	return &test.FakeHandle{
+		SharedLister: &test.FakeSharedLister{
+			NodeInfoLister: &test.FakeNodeInfoLister{Nodes: []*framework.NodeInfo{ni}},
+		},
+	}
+}
+
+func assertAttributes(t *testing.T, span sdktrace.ReadOnlySpan, want []attribute.KeyValue) {
+	t.Helper()
+	have := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		have[kv.Key] = kv.Value
+	}
+	for _, kv := range want {
+		if v, ok := have[kv.Key]; !ok || v != kv.Value {
+			t.Errorf("span %s: unexpected attribute %s: want %v, have %v", span.Name(), kv.Key, kv.Value.Emit(), v.Emit())
+		}
+	}
+}
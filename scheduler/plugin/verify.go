@@ -0,0 +1,136 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package wasm
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// errGuestNotVerified is returned when a guest doesn't match guestSHA256 or
+// its signature.
+var errGuestNotVerified = errors.New("wasm: guest not verified")
+
+// guestVerifier checks a guest binary before it is compiled.
+type guestVerifier struct {
+	// sha256 is the expected digest of the guest, if set.
+	sha256 []byte
+
+	// publicKey verifies the signature at signatureURL, if set.
+	publicKey crypto.PublicKey
+	// signatureURL is the URL of the detached signature of the guest.
+	signatureURL string
+}
+
+// newGuestVerifier returns a guestVerifier configured by the plugin config,
+// or nil if there's nothing to verify.
+func newGuestVerifier(config PluginConfig) (*guestVerifier, error) {
+	v := &guestVerifier{signatureURL: config.GuestSignatureURL}
+
+	if s := config.GuestSHA256; s != "" {
+		digest, err := hex.DecodeString(s)
+		if err != nil || len(digest) != sha256.Size {
+			return nil, fmt.Errorf("wasm: invalid guestSHA256 %q: must be %d hex characters", s, 2*sha256.Size)
+		}
+		v.sha256 = digest
+	}
+
+	if k := config.GuestPublicKey; k != "" {
+		block, _ := pem.Decode([]byte(k))
+		if block == nil {
+			return nil, errors.New("wasm: invalid guestPublicKey: not PEM encoded")
+		}
+		publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("wasm: invalid guestPublicKey: %w", err)
+		}
+		switch publicKey.(type) {
+		case ed25519.PublicKey, *ecdsa.PublicKey:
+		default:
+			return nil, fmt.Errorf("wasm: invalid guestPublicKey: unsupported type %T", publicKey)
+		}
+		if v.signatureURL == "" {
+			return nil, errors.New("wasm: guestSignatureURL is required with guestPublicKey")
+		}
+		v.publicKey = publicKey
+	} else if v.signatureURL != "" {
+		return nil, errors.New("wasm: guestPublicKey is required with guestSignatureURL")
+	}
+
+	if v.sha256 == nil && v.publicKey == nil {
+		return nil, nil
+	}
+	return v, nil
+}
+
+// verify returns an error wrapping errGuestNotVerified if the guest doesn't
+// match the configured digest or signature. Other errors are from reading
+// the signature.
+func (v *guestVerifier) verify(ctx context.Context, fetcher *guestFetcher, guestBin []byte) error {
+	if v == nil {
+		return nil
+	}
+
+	if v.sha256 != nil {
+		if digest := sha256.Sum256(guestBin); !bytes.Equal(v.sha256, digest[:]) {
+			return fmt.Errorf("%w: guestSHA256 is %x, but the guest is %x", errGuestNotVerified, v.sha256, digest)
+		}
+	}
+
+	if v.publicKey == nil {
+		return nil
+	}
+	signature, _, err := fetcher.getIfChanged(ctx, v.signatureURL, "")
+	if err != nil {
+		return fmt.Errorf("wasm: error reading guestSignatureURL %s: %w", v.signatureURL, err)
+	}
+	signature = decodeSignature(signature)
+
+	var valid bool
+	switch publicKey := v.publicKey.(type) {
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(publicKey, guestBin, signature)
+	case *ecdsa.PublicKey:
+		// Like cosign sign-blob, an ECDSA signature is of the SHA-256 digest.
+		digest := sha256.Sum256(guestBin)
+		valid = ecdsa.VerifyASN1(publicKey, digest[:], signature)
+	}
+	if !valid {
+		return fmt.Errorf("%w: invalid signature at %s", errGuestNotVerified, v.signatureURL)
+	}
+	return nil
+}
+
+// decodeSignature returns the signature decoded from base64, as written by
+// tools like cosign, or as-is if it isn't base64.
+func decodeSignature(signature []byte) []byte {
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature))); err == nil {
+		return decoded
+	}
+	return signature
+}
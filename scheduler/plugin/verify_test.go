@@ -0,0 +1,220 @@
+/*
+   Copyright 2023 The Kubernetes Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package wasm
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/kube-scheduler-wasm-extension/scheduler/test"
+)
+
+func TestNewFromConfig_verify(t *testing.T) {
+	guestBin, err := os.ReadFile(test.URLTestFilterFromGlobal[7:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(guestBin)
+
+	ed25519Public, ed25519Private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdsaPrivate, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdsaSignature, err := ecdsaPrivate.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPrivate, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpDir := t.TempDir()
+	writeSignature := func(name string, signature []byte) string {
+		p := path.Join(tmpDir, name)
+		if err := os.WriteFile(p, signature, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return "file://" + p
+	}
+	ed25519SignatureURL := writeSignature("ed25519.sig", ed25519.Sign(ed25519Private, guestBin))
+	ecdsaSignatureURL := writeSignature("ecdsa.sig", []byte(base64.StdEncoding.EncodeToString(ecdsaSignature)+"\n"))
+	otherSignatureURL := writeSignature("other.sig", ed25519.Sign(ed25519Private, []byte("other")))
+
+	tests := []struct {
+		name          string
+		sha256        string
+		publicKey     string
+		signatureURL  string
+		expectedError string
+	}{
+		{
+			name:   "sha256",
+			sha256: hex.EncodeToString(digest[:]),
+		},
+		{
+			name:          "sha256 mismatch",
+			sha256:        strings.Repeat("0", 64),
+			expectedError: "wasm: guest not verified: guestSHA256 is " + strings.Repeat("0", 64) + ", but the guest is " + hex.EncodeToString(digest[:]),
+		},
+		{
+			name:          "sha256 invalid",
+			sha256:        "abc",
+			expectedError: `wasm: invalid guestSHA256 "abc": must be 64 hex characters`,
+		},
+		{
+			name:         "ed25519 signature",
+			publicKey:    publicKeyPEM(t, ed25519Public),
+			signatureURL: ed25519SignatureURL,
+		},
+		{
+			name:         "ecdsa signature",
+			publicKey:    publicKeyPEM(t, &ecdsaPrivate.PublicKey),
+			signatureURL: ecdsaSignatureURL,
+		},
+		{
+			name:          "signature of other content",
+			publicKey:     publicKeyPEM(t, ed25519Public),
+			signatureURL:  otherSignatureURL,
+			expectedError: "wasm: guest not verified: invalid signature at " + otherSignatureURL,
+		},
+		{
+			name:          "signature by other key",
+			publicKey:     publicKeyPEM(t, &ecdsaPrivate.PublicKey),
+			signatureURL:  ed25519SignatureURL,
+			expectedError: "wasm: guest not verified: invalid signature at " + ed25519SignatureURL,
+		},
+		{
+			name:          "signature missing",
+			publicKey:     publicKeyPEM(t, ed25519Public),
+			signatureURL:  "file://" + path.Join(tmpDir, "missing.sig"),
+			expectedError: "wasm: error reading guestSignatureURL file://" + path.Join(tmpDir, "missing.sig"),
+		},
+		{
+			name:          "public key without signature URL",
+			publicKey:     publicKeyPEM(t, ed25519Public),
+			expectedError: "wasm: guestSignatureURL is required with guestPublicKey",
+		},
+		{
+			name:          "signature URL without public key",
+			signatureURL:  ed25519SignatureURL,
+			expectedError: "wasm: guestPublicKey is required with guestSignatureURL",
+		},
+		{
+			name:          "public key not PEM",
+			publicKey:     "abc",
+			signatureURL:  ed25519SignatureURL,
+			expectedError: "wasm: invalid guestPublicKey: not PEM encoded",
+		},
+		{
+			name:          "public key unsupported",
+			publicKey:     publicKeyPEM(t, &rsaPrivate.PublicKey),
+			signatureURL:  ed25519SignatureURL,
+			expectedError: "wasm: invalid guestPublicKey: unsupported type *rsa.PublicKey",
+		},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewFromConfig(ctx, "wasm", PluginConfig{
+				GuestURL:          test.URLTestFilterFromGlobal,
+				GuestSHA256:       tt.sha256,
+				GuestPublicKey:    tt.publicKey,
+				GuestSignatureURL: tt.signatureURL,
+			}, nil)
+			if tt.expectedError != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.expectedError) {
+					t.Fatalf("expected error %q, have %v", tt.expectedError, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			p.(io.Closer).Close()
+		})
+	}
+}
+
+func Test_reload_notVerified(t *testing.T) {
+	guestBin, err := os.ReadFile(test.URLTestFilterFromGlobal[7:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(guestBin)
+
+	guestPath := path.Join(t.TempDir(), "guest.wasm")
+	writeGuest(t, guestPath, test.URLTestFilterFromGlobal, time.Unix(1, 0))
+	p, err := NewFromConfig(ctx, "reload", PluginConfig{
+		GuestURL:       "file://" + guestPath,
+		GuestSHA256:    hex.EncodeToString(digest[:]),
+		ReloadInterval: metav1.Duration{Duration: time.Hour},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.(io.Closer).Close()
+	pl := p.(ProfilerSupport).plugin()
+
+	fetcher, err := newGuestFetcher(pl.config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, version, err := fetcher.getIfChanged(ctx, pl.config.GuestURL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A guest with a different digest is rejected, and retried next time.
+	gen := pl.current()
+	writeGuest(t, guestPath, test.URLErrorPanicOnFilter, time.Unix(2, 0))
+	if have := pl.reloadIfChanged(ctx, fetcher, version); have != version {
+		t.Fatalf("expected the version to be unchanged, have %s", have)
+	}
+	if pl.current() != gen {
+		t.Fatal("expected the guest not to be reloaded")
+	}
+}
+
+func publicKeyPEM(t *testing.T, publicKey crypto.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
@@ -18,51 +18,369 @@ package wasm
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	neturl "net/url"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
 )
 
+// guestFetcher reads guest binaries from a URL.
+type guestFetcher struct {
+	// client is used for http[s]:// and oci:// URLs.
+	client *http.Client
+
+	// cacheDir is an optional directory to cache guests into. Blobs pulled
+	// from OCI registries are keyed by digest, and guests read via HTTP by
+	// URL.
+	cacheDir string
+
+	// retries and retryBackoff are documented on FetchConfig.
+	retries      int
+	retryBackoff time.Duration
+
+	// bearerTokenFile and basicAuthFile are documented on FetchConfig.
+	bearerTokenFile, basicAuthFile string
+
+	// handle is used to read configmap:// and secret:// URLs.
+	handle framework.Handle
+}
+
+// defaultRetryBackoff is the backoff before the first retry, if
+// FetchConfig.RetryBackoff is unset.
+const defaultRetryBackoff = time.Second
+
+// newGuestFetcher returns a guestFetcher configured by the plugin config.
+func newGuestFetcher(config PluginConfig, handle framework.Handle) (*guestFetcher, error) {
+	fc := config.GuestFetch
+	if fc.Retries < 0 {
+		return nil, fmt.Errorf("wasm: guestFetch.retries %d is negative", fc.Retries)
+	}
+	if fc.BearerTokenFile != "" && fc.BasicAuthFile != "" {
+		return nil, errors.New("wasm: guestFetch.bearerTokenFile and guestFetch.basicAuthFile are mutually exclusive")
+	}
+	if (fc.CertFile == "") != (fc.KeyFile == "") {
+		return nil, errors.New("wasm: guestFetch.certFile and guestFetch.keyFile must be set together")
+	}
+
+	f := &guestFetcher{
+		client:          http.DefaultClient,
+		cacheDir:        config.GuestCacheDir,
+		retries:         fc.Retries,
+		retryBackoff:    fc.RetryBackoff.Duration,
+		bearerTokenFile: fc.BearerTokenFile,
+		basicAuthFile:   fc.BasicAuthFile,
+		handle:          handle,
+	}
+	if f.retryBackoff == 0 {
+		f.retryBackoff = defaultRetryBackoff
+	}
+
+	// Only use a custom client when needed, so that the default one keeps
+	// sharing connections with any other use.
+	if fc.Timeout.Duration == 0 && fc.CAFile == "" && fc.CertFile == "" {
+		return f, nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if fc.CAFile != "" || fc.CertFile != "" {
+		tlsConfig, err := newTLSConfig(fc)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	f.client = &http.Client{Transport: transport, Timeout: fc.Timeout.Duration}
+	return f, nil
+}
+
+// newTLSConfig returns a TLS config trusting the system certificates and any
+// in the CAFile, and presenting any client certificate.
+func newTLSConfig(fc FetchConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if fc.CAFile != "" {
+		pem, err := os.ReadFile(fc.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("wasm: error reading guestFetch.caFile: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("wasm: guestFetch.caFile %s has no PEM certificates", fc.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if fc.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(fc.CertFile, fc.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("wasm: error loading guestFetch.certFile: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
 // getURL parses the URL manually, so that it can resolve relative file paths,
 // such as "file://../../path/to/plugin.wasm"
 func getURL(ctx context.Context, url string) ([]byte, error) {
+	f := &guestFetcher{client: http.DefaultClient}
+	bin, _, err := f.getIfChanged(ctx, url, "")
+	return bin, err
+}
+
+// getIfChanged is like getURL, except it returns a nil byte slice if the
+// content at the URL is still at the version, from a prior call. Otherwise,
+// it returns the content and its version.
+//
+// The version of a file is based on its modification time and size. The
+// version of an HTTP resource is its ETag, which is sent as If-None-Match, or
+// a hash of its content if the server doesn't return one. The version of an
+// OCI artifact is the digest of its manifest. The version of a key in a
+// ConfigMap or Secret is a hash of its content.
+func (f *guestFetcher) getIfChanged(ctx context.Context, url, version string) (bin []byte, newVersion string, err error) {
 	firstColon := strings.IndexByte(url, ':')
 	if firstColon == -1 {
-		return nil, fmt.Errorf("invalid URL: %s", url)
+		return nil, "", fmt.Errorf("invalid URL: %s", url)
 	}
 
 	scheme := url[:firstColon]
 	switch scheme {
 	case "http", "https":
-		return httpGet(ctx, http.DefaultClient, url)
+		err = f.withRetries(ctx, func() (err error) {
+			bin, newVersion, err = f.httpGetIfChanged(ctx, url, version)
+			return
+		})
+		if err == nil && bin != nil {
+			f.cacheHTTPGuest(ctx, url, bin)
+		} else if err != nil && version == "" && ctx.Err() == nil && isRetryable(err) {
+			if cached := f.readCachedHTTPGuest(url); cached != nil {
+				klog.FromContext(ctx).Error(err, "Using cached guest, as the server is unavailable", "guestURL", url)
+				bin, newVersion, err = cached, contentHash(cached), nil
+			}
+		}
 	case "file":
 		guestPath := url[7:] // strip file://
-		return os.ReadFile(guestPath)
+		bin, newVersion, err = readFileIfChanged(guestPath, version)
+	case "oci":
+		err = f.withRetries(ctx, func() (err error) {
+			bin, newVersion, err = f.ociPullIfChanged(ctx, url, version)
+			return
+		})
+	case "configmap", "secret":
+		bin, newVersion, err = f.kubeGetIfChanged(ctx, scheme, url, version)
 	default:
-		return nil, fmt.Errorf("unsupported URL scheme: %s", scheme)
+		return nil, "", fmt.Errorf("unsupported URL scheme: %s", scheme)
+	}
+	if err != nil || newVersion == version {
+		return nil, version, err
 	}
+	return
+}
+
+// readFileIfChanged reads the file, unless its version is unchanged.
+func readFileIfChanged(guestPath, version string) ([]byte, string, error) {
+	f, err := os.Open(guestPath)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, "", err
+	}
+	newVersion := fmt.Sprintf("%d-%d", info.ModTime().UnixNano(), info.Size())
+	if newVersion == version {
+		return nil, version, nil
+	}
+	bin, err := io.ReadAll(f)
+	return bin, newVersion, err
 }
 
 // httpGet returns a byte slice of the wasm module found at the given URL, or
 // an error otherwise.
 func httpGet(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	f := &guestFetcher{client: client}
+	bin, _, err := f.httpGetIfChanged(ctx, url, "")
+	return bin, err
+}
+
+// httpGetIfChanged is like httpGet, except it returns a nil byte slice if the
+// content is still at the version.
+func (f *guestFetcher) httpGetIfChanged(ctx context.Context, url, version string) ([]byte, string, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	if version != "" && !strings.HasPrefix(version, contentHashPrefix) {
+		req.Header.Set("If-None-Match", version)
+	}
+	if err = f.authorize(req); err != nil {
+		return nil, "", err
 	}
-	resp, err := client.Do(req)
+	resp, err := f.client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer resp.Body.Close()
 
 	// golang/go#60240 recommends to just close the client body, instead of
 	// draining it first.
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("received %v status code from %q", resp.StatusCode, url)
+	if resp.StatusCode == http.StatusNotModified && version != "" {
+		return nil, version, nil
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, "", &statusError{statusCode: resp.StatusCode, url: url}
 	}
 
-	return io.ReadAll(resp.Body)
+	bin, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		return bin, etag, nil
+	}
+	return bin, contentHash(bin), nil
+}
+
+// authorize sets the Authorization header from bearerTokenFile or
+// basicAuthFile, if either is set.
+func (f *guestFetcher) authorize(req *http.Request) error {
+	switch {
+	case f.bearerTokenFile != "":
+		token, err := os.ReadFile(f.bearerTokenFile)
+		if err != nil {
+			return fmt.Errorf("error reading bearer token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	case f.basicAuthFile != "":
+		userPass, err := os.ReadFile(f.basicAuthFile)
+		if err != nil {
+			return fmt.Errorf("error reading basic auth: %w", err)
+		}
+		username, password, ok := strings.Cut(strings.TrimRight(string(userPass), "\r\n"), ":")
+		if !ok {
+			return fmt.Errorf("basic auth file %s is not formatted as username:password", f.basicAuthFile)
+		}
+		req.SetBasicAuth(username, password)
+	}
+	return nil
+}
+
+// statusError is returned when a server responds with an unexpected status.
+type statusError struct {
+	statusCode int
+	url        string
+}
+
+// Error implements the same method as documented on error.
+func (e *statusError) Error() string {
+	return fmt.Sprintf("received %v status code from %q", e.statusCode, e.url)
+}
+
+// isRetryable returns true if the error may be temporary, such as a server
+// which is unreachable, timed out or overloaded.
+func isRetryable(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.statusCode == http.StatusTooManyRequests || se.statusCode >= 500
+	}
+	var ue *neturl.Error
+	return errors.As(err, &ue)
+}
+
+// withRetries calls fn until it succeeds, returns an error which isn't
+// retryable, or there are no retries left. The backoff between calls doubles
+// each time.
+func (f *guestFetcher) withRetries(ctx context.Context, fn func() error) error {
+	backoff := f.retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= f.retries || !isRetryable(err) {
+			return err
+		}
+		klog.FromContext(ctx).V(2).Info("Retrying guest fetch", "attempt", attempt+1, "backoff", backoff, "err", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// cachedHTTPGuestPath returns the path of content read from the URL in
+// cacheDir.
+func (f *guestFetcher) cachedHTTPGuestPath(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(f.cacheDir, "http", hex.EncodeToString(sum[:]))
+}
+
+// readCachedHTTPGuest returns the last guest read from the URL, or nil if
+// there is none in cacheDir.
+func (f *guestFetcher) readCachedHTTPGuest(url string) []byte {
+	if f.cacheDir == "" {
+		return nil
+	}
+	bin, err := os.ReadFile(f.cachedHTTPGuestPath(url))
+	if err != nil {
+		return nil
+	}
+	return bin
+}
+
+// cacheHTTPGuest writes the guest read from the URL to cacheDir, if set. A
+// failure is only logged, as the guest was read.
+func (f *guestFetcher) cacheHTTPGuest(ctx context.Context, url string, bin []byte) {
+	if f.cacheDir == "" {
+		return
+	}
+	if err := writeFileAtomic(f.cachedHTTPGuestPath(url), bin); err != nil {
+		klog.FromContext(ctx).Error(err, "Failed to cache guest", "guestURL", url)
+	}
+}
+
+// writeFileAtomic writes to a temporary file first, then renames it, so that
+// a concurrent reader never sees a partial file. Missing directories are
+// created.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Close()
+	} else {
+		_ = tmp.Close()
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// contentHashPrefix is the prefix of a version which is a content hash,
+// instead of an ETag.
+const contentHashPrefix = "sha256:"
+
+// contentHash returns a version based on the hash of the content.
+func contentHash(bin []byte) string {
+	sum := sha256.Sum256(bin)
+	return contentHashPrefix + hex.EncodeToString(sum[:])
 }
@@ -20,7 +20,15 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -30,6 +38,8 @@ import (
 	"testing"
 	"time"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
 	"sigs.k8s.io/kube-scheduler-wasm-extension/scheduler/test"
 )
 
@@ -199,3 +209,386 @@ func Test_httpGet(t *testing.T) {
 		})
 	}
 }
+
+func Test_httpGetIfChanged(t *testing.T) {
+	wasmBinary := append(wasmMagicNumber, 0x01, 0x00, 0x00, 0x00)
+	cases := []struct {
+		name            string
+		etag            string
+		expectedVersion string
+	}{
+		{
+			name:            "etag",
+			etag:            `"v1"`,
+			expectedVersion: `"v1"`,
+		},
+		{
+			name:            "no etag",
+			expectedVersion: contentHash(wasmBinary),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.etag != "" {
+					if r.Header.Get("If-None-Match") == tc.etag {
+						w.WriteHeader(http.StatusNotModified)
+						return
+					}
+					w.Header().Set("ETag", tc.etag)
+				} else if r.Header.Get("If-None-Match") != "" {
+					t.Error("unexpected If-None-Match without an ETag")
+				}
+				_, _ = w.Write(wasmBinary)
+			}))
+			defer ts.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			f := &guestFetcher{client: ts.Client()}
+			bin, version, err := f.httpGetIfChanged(ctx, ts.URL, "")
+			if err != nil {
+				t.Fatal(err)
+			} else if !bytes.Equal(wasmBinary, bin) {
+				t.Fatalf("unexpected binary: %v", bin)
+			} else if want, have := tc.expectedVersion, version; want != have {
+				t.Fatalf("unexpected version: want %s, have %s", want, have)
+			}
+
+			// When the version is the same, the server isn't asked for the
+			// content again, if it supports ETag.
+			_, version, err = f.httpGetIfChanged(ctx, ts.URL, version)
+			if err != nil {
+				t.Fatal(err)
+			} else if want, have := tc.expectedVersion, version; want != have {
+				t.Fatalf("unexpected version: want %s, have %s", want, have)
+			}
+		})
+	}
+}
+
+func Test_guestFetcher_getIfChanged_file(t *testing.T) {
+	guestPath := path.Join(t.TempDir(), "guest.wasm")
+	if err := os.WriteFile(guestPath, wasmMagicNumber, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	url := "file://" + guestPath
+	f := &guestFetcher{}
+
+	bin, version, err := f.getIfChanged(context.Background(), url, "")
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(wasmMagicNumber, bin) {
+		t.Fatalf("unexpected binary: %v", bin)
+	}
+
+	// Nothing is returned when the file is unchanged.
+	if bin, have, err := f.getIfChanged(context.Background(), url, version); err != nil {
+		t.Fatal(err)
+	} else if bin != nil || have != version {
+		t.Fatalf("expected no change, have %v, version %s", bin, have)
+	}
+
+	// A different modification time is a change.
+	modTime := time.Now().Add(time.Hour)
+	if err = os.Chtimes(guestPath, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	if bin, have, err := f.getIfChanged(context.Background(), url, version); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(wasmMagicNumber, bin) || have == version {
+		t.Fatalf("expected a change, have %v, version %s", bin, have)
+	}
+}
+
+func Test_newGuestFetcher(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        FetchConfig
+		expectedError string
+	}{
+		{
+			name:   "default",
+			config: FetchConfig{},
+		},
+		{
+			name:          "negative retries",
+			config:        FetchConfig{Retries: -1},
+			expectedError: "wasm: guestFetch.retries -1 is negative",
+		},
+		{
+			name:          "bearer and basic auth",
+			config:        FetchConfig{BearerTokenFile: "token", BasicAuthFile: "basic"},
+			expectedError: "wasm: guestFetch.bearerTokenFile and guestFetch.basicAuthFile are mutually exclusive",
+		},
+		{
+			name:          "cert without key",
+			config:        FetchConfig{CertFile: "tls.crt"},
+			expectedError: "wasm: guestFetch.certFile and guestFetch.keyFile must be set together",
+		},
+		{
+			name:          "missing CA file",
+			config:        FetchConfig{CAFile: filepath.Join(t.TempDir(), "ca.crt")},
+			expectedError: "wasm: error reading guestFetch.caFile",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := newGuestFetcher(PluginConfig{GuestFetch: tc.config}, nil)
+			if tc.expectedError == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tc.expectedError) {
+				t.Fatalf("expected err %v to contain %s", err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func Test_guestFetcher_authorize(t *testing.T) {
+	tmpDir := t.TempDir()
+	tokenFile := filepath.Join(tmpDir, "token")
+	basicAuthFile := filepath.Join(tmpDir, "basic")
+	if err := os.WriteFile(tokenFile, []byte("s3cr3t\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(basicAuthFile, []byte("user:pass\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name                  string
+		config                FetchConfig
+		expectedAuthorization string
+	}{
+		{
+			name:                  "bearer token",
+			config:                FetchConfig{BearerTokenFile: tokenFile},
+			expectedAuthorization: "Bearer s3cr3t",
+		},
+		{
+			name:                  "basic auth",
+			config:                FetchConfig{BasicAuthFile: basicAuthFile},
+			expectedAuthorization: "Basic dXNlcjpwYXNz",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != tc.expectedAuthorization {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				_, _ = w.Write(wasmMagicNumber)
+			}))
+			defer ts.Close()
+
+			f, err := newGuestFetcher(PluginConfig{GuestFetch: tc.config}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bin, _, err := f.getIfChanged(context.Background(), ts.URL, ""); err != nil {
+				t.Fatal(err)
+			} else if !bytes.Equal(wasmMagicNumber, bin) {
+				t.Fatalf("unexpected binary: %v", bin)
+			}
+		})
+	}
+}
+
+func Test_guestFetcher_retries(t *testing.T) {
+	tests := []struct {
+		name             string
+		statusCode       int
+		retries          int
+		expectedRequests int
+		expectedError    string
+	}{
+		{
+			name:             "succeeds after retries",
+			statusCode:       http.StatusServiceUnavailable,
+			retries:          2,
+			expectedRequests: 3,
+		},
+		{
+			name:             "too many requests",
+			statusCode:       http.StatusTooManyRequests,
+			retries:          2,
+			expectedRequests: 3,
+		},
+		{
+			name:             "out of retries",
+			statusCode:       http.StatusBadGateway,
+			retries:          1,
+			expectedRequests: 2,
+			expectedError:    "received 502 status code",
+		},
+		{
+			name:             "not retryable",
+			statusCode:       http.StatusNotFound,
+			retries:          2,
+			expectedRequests: 1,
+			expectedError:    "received 404 status code",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests int
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// Fail all but the third request.
+				if requests++; requests < 3 {
+					w.WriteHeader(tc.statusCode)
+					return
+				}
+				_, _ = w.Write(wasmMagicNumber)
+			}))
+			defer ts.Close()
+
+			f := &guestFetcher{client: ts.Client(), retries: tc.retries, retryBackoff: time.Millisecond}
+			_, _, err := f.getIfChanged(context.Background(), ts.URL, "")
+			if tc.expectedError == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tc.expectedError) {
+				t.Fatalf("expected err %v to contain %s", err, tc.expectedError)
+			}
+			if want, have := tc.expectedRequests, requests; want != have {
+				t.Fatalf("unexpected requests: want %d, have %d", want, have)
+			}
+		})
+	}
+}
+
+func Test_guestFetcher_cache(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(wasmMagicNumber)
+	}))
+	url := ts.URL
+	f := &guestFetcher{client: ts.Client(), cacheDir: t.TempDir()}
+
+	if _, _, err := f.getIfChanged(context.Background(), url, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// When the server is unreachable at startup, the cached guest is used.
+	ts.Close()
+	bin, version, err := f.getIfChanged(context.Background(), url, "")
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(wasmMagicNumber, bin) {
+		t.Fatalf("unexpected binary: %v", bin)
+	}
+
+	// When reloading, the current guest is kept instead.
+	if _, _, err = f.getIfChanged(context.Background(), url, version); err == nil {
+		t.Fatal("expected an error reloading from an unreachable server")
+	}
+}
+
+func Test_guestFetcher_tls(t *testing.T) {
+	tmpDir := t.TempDir()
+	clientCert, clientKey := writeClientCertificate(t, tmpDir)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(wasmMagicNumber)
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM(readFile(t, clientCert))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	ts.StartTLS()
+	defer ts.Close()
+
+	caFile := filepath.Join(tmpDir, "ca.crt")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		config        FetchConfig
+		expectedError string
+	}{
+		{
+			name:          "untrusted server",
+			config:        FetchConfig{CertFile: clientCert, KeyFile: clientKey},
+			expectedError: "certificate",
+		},
+		{
+			name:          "missing client certificate",
+			config:        FetchConfig{CAFile: caFile},
+			expectedError: "certificate",
+		},
+		{
+			name:   "client certificate",
+			config: FetchConfig{CAFile: caFile, CertFile: clientCert, KeyFile: clientKey, Timeout: metav1.Duration{Duration: 5 * time.Second}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := newGuestFetcher(PluginConfig{GuestFetch: tc.config}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _, err = f.getIfChanged(context.Background(), ts.URL, "")
+			if tc.expectedError == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tc.expectedError) {
+				t.Fatalf("expected err %v to contain %s", err, tc.expectedError)
+			}
+		})
+	}
+}
+
+// writeClientCertificate writes a self-signed client certificate and its key
+// to the directory, returning their paths.
+func writeClientCertificate(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kube-scheduler"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile = filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	if err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func readFile(t *testing.T, path string) []byte {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
@@ -22,10 +22,14 @@ var URLErrorPanicOnGetConfig = localURL(pathWatError("panic_on_get_config"))
 
 var URLErrorPanicOnEnqueue = localURL(pathWatError("panic_on_enqueue"))
 
+var URLErrorPanicOnPreEnqueue = localURL(pathWatError("panic_on_preenqueue"))
+
 var URLErrorPanicOnPreFilter = localURL(pathWatError("panic_on_prefilter"))
 
 var URLErrorPanicOnFilter = localURL(pathWatError("panic_on_filter"))
 
+var URLErrorPanicOnFilterFromGlobal = localURL(pathWatError("panic_on_filter_from_global"))
+
 var URLErrorPanicOnPostFilter = localURL(pathWatError("panic_on_postfilter"))
 
 var URLErrorPanicOnPreScore = localURL(pathWatError("panic_on_prescore"))
@@ -52,6 +56,16 @@ var URLErrorScoreExtensionsWithoutScore = localURL(pathWatError("scoreextensions
 
 var URLErrorPanicOnStart = localURL(pathWatError("panic_on_start"))
 
+var URLErrorLoopOnFilter = localURL(pathWatError("loop_on_filter"))
+
+var URLErrorLoopOnPermit = localURL(pathWatError("loop_on_permit"))
+
+var URLErrorLoopOnBind = localURL(pathWatError("loop_on_bind"))
+
+var URLErrorOOMOnFilter = localURL(pathWatError("oom_on_filter"))
+
+var URLErrorOOMWithMaxOnFilter = localURL(pathWatError("oom_with_max_on_filter"))
+
 var URLExampleNodeNumber = localURL(pathTinyGoExample("nodenumber"))
 
 var URLExampleAdvanced = localURL(pathTinyGoExample("advanced"))
@@ -62,12 +76,28 @@ var URLTestAllNoopWat = localURL(pathWatTest("all_noop"))
 
 var URLTestCycleState = localURL(pathTinyGoTest("cyclestate"))
 
+var URLTestCycleStateFromGlobal = localURL(pathWatTest("cyclestate_from_global"))
+
+var URLTestSharedCycleState = localURL(pathWatTest("sharedcyclestate"))
+
+var URLTestQueueingHintFromGlobal = localURL(pathWatTest("queueinghint_from_global"))
+
+var URLTestQueueingHintReordered = localURL(pathWatTest("queueinghint_reordered"))
+
+var URLTestPreEnqueueFromGlobal = localURL(pathWatTest("preenqueue_from_global"))
+
+var URLTestLessFromParams = localURL(pathWatTest("less_from_params"))
+
 var URLTestPreFilterFromGlobal = localURL(pathWatTest("prefilter_from_global"))
 
 var URLTestFilter = localURL(pathTinyGoTest("filter"))
 
 var URLTestFilterFromGlobal = localURL(pathWatTest("filter_from_global"))
 
+var URLTestFilterStorage = localURL(pathWatTest("filter_storage"))
+
+var URLTestNodeInfoPodsFromGlobal = localURL(pathWatTest("nodeinfo_pods_from_global"))
+
 var URLTestPostFilterFromGlobal = localURL(pathWatTest("postfilter_from_global"))
 
 var URLTestPreScoreFromGlobal = localURL(pathWatTest("prescore_from_global"))
@@ -0,0 +1,12 @@
+;; loop_on_bind is a bind which never returns. This simulates a guest
+;; stuck in an infinite loop.
+(module $loop_on_bind
+
+  ;; Allocate the minimum amount of memory, 1 page (64KB).
+  (memory (export "memory") 1 1)
+
+  ;; On bind, loop forever instead of returning a code.
+  (func (export "bind") (result i32)
+    (loop $forever (br $forever))
+    (unreachable))
+)
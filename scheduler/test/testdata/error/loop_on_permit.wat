@@ -0,0 +1,12 @@
+;; loop_on_permit is a permit which never returns. This simulates a guest
+;; stuck in an infinite loop.
+(module $loop_on_permit
+
+  ;; Allocate the minimum amount of memory, 1 page (64KB).
+  (memory (export "memory") 1 1)
+
+  ;; On permit, loop forever instead of returning a code.
+  (func (export "permit") (result i64)
+    (loop $forever (br $forever))
+    (unreachable))
+)
@@ -0,0 +1,17 @@
+;; oom_on_filter is a filter which grows its memory, and issues an unreachable
+;; instruction if that failed. This simulates an out of memory panic in TinyGo.
+(module $oom_on_filter
+
+  ;; Allocate two pages of memory (128KB), without a maximum.
+  (memory (export "memory") 2)
+
+  ;; On filter, double memory and crash if that failed.
+  (func (export "filter") (result i32)
+    (memory.grow (i32.const 2))
+    (i32.const -1)
+    (i32.eq)
+    (if (then (unreachable)))
+
+    ;; Otherwise, return success.
+    (return (i32.const 0)))
+)
@@ -0,0 +1,17 @@
+;; oom_with_max_on_filter is like oom_on_filter, except it declares a maximum
+;; amount of memory, like some TinyGo guests do.
+(module $oom_with_max_on_filter
+
+  ;; Allocate two pages of memory (128KB), with a maximum of 16 pages (1MB).
+  (memory (export "memory") 2 16)
+
+  ;; On filter, double memory and crash if that failed.
+  (func (export "filter") (result i32)
+    (memory.grow (i32.const 2))
+    (i32.const -1)
+    (i32.eq)
+    (if (then (unreachable)))
+
+    ;; Otherwise, return success.
+    (return (i32.const 0)))
+)
@@ -0,0 +1,15 @@
+;; panic_on_filter_from_global lets us test that a guest isn't re-used after
+;; it panics, as a new guest won't have the global set.
+(module $panic_on_filter_from_global
+
+  ;; Allocate the minimum amount of memory, 1 page (64KB).
+  (memory (export "memory") 1 1)
+
+  ;; panic is set by the host.
+  (global $panic (export "panic_global") (mut i32) (i32.const 0))
+
+  ;; On filter, crash if panic is set. Otherwise, return success.
+  (func (export "filter") (result i32)
+    (if (global.get $panic) (then (unreachable)))
+    (return (i32.const 0)))
+)
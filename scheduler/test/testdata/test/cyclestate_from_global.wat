@@ -0,0 +1,44 @@
+;; cyclestate_from_global lets us test that the host switches the guest between
+;; copies of its cycle state, when the scheduler clones framework.CycleState.
+(module $cyclestate_from_global
+
+  ;; Allocate the minimum amount of memory, 1 page (64KB).
+  (memory (export "memory") 1 1)
+
+  ;; state is the cycle state of the guest.
+  (global $state (export "state_global") (mut i32) (i32.const 0))
+
+  ;; dropped is the ID last passed to dropcyclestate, so that the host can
+  ;; verify a snapshot was dropped.
+  (global $dropped (export "dropped_global") (mut i32) (i32.const 0))
+
+  ;; prefilter begins a new cycle state.
+  (func (export "prefilter") (result i32)
+    (global.set $state (i32.const 0))
+    (return (i32.const 0)))
+
+  ;; addpod changes the cycle state, like a plugin tracking pods would.
+  (func (export "addpod") (result i32)
+    (global.set $state (i32.add (global.get $state) (i32.const 1)))
+    (return (i32.const 0)))
+
+  (func (export "removepod") (result i32)
+    (global.set $state (i32.sub (global.get $state) (i32.const 1)))
+    (return (i32.const 0)))
+
+  ;; filter returns the cycle state as the status code, so that the host can
+  ;; verify which state was used.
+  (func (export "filter") (result i32) (return (global.get $state)))
+
+  ;; snapshotcyclestate saves the cycle state to memory at offset id*4.
+  (func (export "snapshotcyclestate") (param $id i32)
+    (i32.store (i32.mul (local.get $id) (i32.const 4)) (global.get $state)))
+
+  ;; restorecyclestate loads the cycle state from memory at offset id*4.
+  (func (export "restorecyclestate") (param $id i32)
+    (global.set $state (i32.load (i32.mul (local.get $id) (i32.const 4)))))
+
+  ;; dropcyclestate records the ID of the snapshot dropped.
+  (func (export "dropcyclestate") (param $id i32)
+    (global.set $dropped (local.get $id)))
+)
@@ -0,0 +1,14 @@
+;; filter_storage imports a function to look up storage objects, which lets us
+;; test that their informers are registered before the scheduler starts.
+(module $filter_storage
+  (import "k8s.io/scheduler" "storage.storage_class"
+    (func $storage.storage_class
+      (param $name i32) (param $name_len i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; len ;) i32)))
+
+  ;; Allocate the minimum amount of memory, 1 page (64KB).
+  (memory (export "memory") 1 1)
+
+  (func (export "filter") (result i32) (return (i32.const 0)))
+)
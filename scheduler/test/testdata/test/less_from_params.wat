@@ -0,0 +1,32 @@
+;; less_from_params lets us test the parameters passed to less, and the host
+;; functions which read the queued pods.
+(module $less_from_params
+  (import "k8s.io/scheduler" "queued_pod_key"
+    (func $queued_pod_key (param $index i32) (param $buf i32) (param $buf_limit i32) (result (; len ;) i32)))
+  (import "k8s.io/scheduler" "queued_pod"
+    (func $queued_pod (param $index i32) (param $buf i32) (param $buf_limit i32) (result (; len ;) i32)))
+
+  ;; Allocate the minimum amount of memory, 1 page (64KB).
+  (memory (export "memory") 1 1)
+
+  ;; pod_index is set by the host, to choose the pod to read.
+  (global $pod_index (export "pod_index_global") (mut i32) (i32.const 1))
+
+  ;; The below are set by less, so that the host can read them.
+  (global $pod_key_len (export "pod_key_len_global") (mut i32) (i32.const 0))
+  (global $pod_len (export "pod_len_global") (mut i32) (i32.const 0))
+
+  ;; less sorts pods with more attempts first, then the oldest.
+  (func (export "less")
+    (param $timestamp1 i64) (param $initial_attempt_timestamp1 i64) (param $attempts1 i32)
+    (param $timestamp2 i64) (param $initial_attempt_timestamp2 i64) (param $attempts2 i32)
+    (result i32)
+
+    ;; Use a zero buf_limit to read only the size of each value.
+    (global.set $pod_key_len (call $queued_pod_key (i32.const 0) (i32.const 0) (i32.const 0)))
+    (global.set $pod_len (call $queued_pod (global.get $pod_index) (i32.const 0) (i32.const 0)))
+
+    (if (i32.ne (local.get $attempts1) (local.get $attempts2))
+      (then (return (i32.gt_u (local.get $attempts1) (local.get $attempts2)))))
+    (return (i64.lt_s (local.get $timestamp1) (local.get $timestamp2))))
+)
@@ -0,0 +1,42 @@
+;; nodeinfo_pods_from_global lets us test the pod lists of NodeInfo.
+(module $nodeinfo_pods_from_global
+  (import "k8s.io/scheduler" "currentNodeName"
+    (func $currentNodeName (param $buf i32) (param $buf_limit i32) (result (; len ;) i32)))
+  (import "k8s.io/scheduler" "node_info.pods"
+    (func $node_info.pods
+      (param $nodename i32) (param $nodename_len i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; len ;) i32)))
+  (import "k8s.io/scheduler" "node_info.pods_with_affinity"
+    (func $node_info.pods_with_affinity
+      (param $nodename i32) (param $nodename_len i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; len ;) i32)))
+  (import "k8s.io/scheduler" "node_info.pods_with_required_anti_affinity"
+    (func $node_info.pods_with_required_anti_affinity
+      (param $nodename i32) (param $nodename_len i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; len ;) i32)))
+
+  ;; Allocate the minimum amount of memory, 1 page (64KB).
+  (memory (export "memory") 1 1)
+
+  ;; pods_kind is set by the host to choose the list of pods:
+  ;; 0 for all, 1 for with affinity, 2 for with required anti-affinity.
+  (global $pods_kind (export "pods_kind_global") (mut i32) (i32.const 0))
+
+  ;; filter returns the length of the PodList of the current node as the
+  ;; status code, so that the host can verify it.
+  (func (export "filter") (result i32)
+    (local $nodename_len i32)
+    (local.set $nodename_len (call $currentNodeName (i32.const 0) (i32.const 256)))
+
+    (if (i32.eq (global.get $pods_kind) (i32.const 1))
+      (then (return (call $node_info.pods_with_affinity
+        (i32.const 0) (local.get $nodename_len) (i32.const 256) (i32.const 65280)))))
+    (if (i32.eq (global.get $pods_kind) (i32.const 2))
+      (then (return (call $node_info.pods_with_required_anti_affinity
+        (i32.const 0) (local.get $nodename_len) (i32.const 256) (i32.const 65280)))))
+    (return (call $node_info.pods
+      (i32.const 0) (local.get $nodename_len) (i32.const 256) (i32.const 65280))))
+)
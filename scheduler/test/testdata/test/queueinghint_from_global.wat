@@ -0,0 +1,45 @@
+;; queueinghint_from_global lets us test the value range of the queueing hint,
+;; and which objects the host passes for each event.
+(module $queueinghint_from_global
+  (import "k8s.io/scheduler" "result.cluster_events"
+    (func $result.cluster_events (param $buf i32) (param $buf_len i32)))
+  (import "k8s.io/scheduler" "currentPod"
+    (func $currentPod (param $buf i32) (param $buf_limit i32) (result (; len ;) i32)))
+  (import "k8s.io/scheduler" "event.old_object"
+    (func $event.old_object (param $buf i32) (param $buf_limit i32) (result (; len ;) i32)))
+  (import "k8s.io/scheduler" "event.new_object"
+    (func $event.new_object (param $buf i32) (param $buf_limit i32) (result (; len ;) i32)))
+
+  ;; Allocate the minimum amount of memory, 1 page (64KB).
+  (memory (export "memory") 1 1)
+
+  ;; Pre-populate memory with the cluster events, as 32-bit little endian
+  ;; gvk and action type pairs.
+  (data (i32.const 0) "\01\00\00\00\01\00\00\00") ;; Node Add
+  (data (i32.const 8) "\00\00\00\00\02\00\00\00") ;; Pod Delete
+
+  ;; queueing_hint is set by the host.
+  (global $queueing_hint (export "queueing_hint_global") (mut i32) (i32.const 0))
+
+  ;; The below are set by queueinghint, so that the host can read them.
+  (global $event_index (export "event_index_global") (mut i32) (i32.const -1))
+  (global $pod_len (export "pod_len_global") (mut i32) (i32.const 0))
+  (global $old_object_len (export "old_object_len_global") (mut i32) (i32.const 0))
+  (global $new_object_len (export "new_object_len_global") (mut i32) (i32.const 0))
+
+  (func (export "enqueue")
+    (call $result.cluster_events (i32.const 0) (i32.const 16)))
+
+  (func (export "queueinghint") (param $event_index i32) (result i32)
+    (global.set $event_index (local.get $event_index))
+
+    ;; Use a zero buf_limit to read only the size of each object.
+    (global.set $pod_len (call $currentPod (i32.const 16) (i32.const 0)))
+    (global.set $old_object_len (call $event.old_object (i32.const 16) (i32.const 0)))
+    (global.set $new_object_len (call $event.new_object (i32.const 16) (i32.const 0)))
+
+    (return (global.get $queueing_hint)))
+
+  ;; We require exporting filter
+  (func (export "filter") (result i32) (unreachable))
+)
@@ -0,0 +1,26 @@
+;; queueinghint_reordered registers the same cluster events as
+;; queueinghint_from_global, in a different order. This lets us test that a
+;; reloaded guest can't change the events queueing hints are registered for.
+(module $queueinghint_reordered
+  (import "k8s.io/scheduler" "result.cluster_events"
+    (func $result.cluster_events (param $buf i32) (param $buf_len i32)))
+
+  ;; Allocate the minimum amount of memory, 1 page (64KB).
+  (memory (export "memory") 1 1)
+
+  ;; Pre-populate memory with the cluster events, as 32-bit little endian
+  ;; gvk and action type pairs.
+  (data (i32.const 0) "\00\00\00\00\02\00\00\00") ;; Pod Delete
+  (data (i32.const 8) "\01\00\00\00\01\00\00\00") ;; Node Add
+
+  (func (export "enqueue")
+    (call $result.cluster_events (i32.const 0) (i32.const 16)))
+
+  ;; queueinghint returns the event index, which is a valid hint for the
+  ;; first two events.
+  (func (export "queueinghint") (param $event_index i32) (result i32)
+    (return (local.get $event_index)))
+
+  ;; We require exporting filter
+  (func (export "filter") (result i32) (unreachable))
+)
@@ -0,0 +1,36 @@
+;; sharedcyclestate lets us test reading, writing and deleting values in the
+;; framework.CycleState of the host.
+(module $sharedcyclestate
+  (import "k8s.io/scheduler" "cyclestate.read"
+    (func $cyclestate.read
+      (param $key i32) (param $key_len i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; found_len ;) i64)))
+  (import "k8s.io/scheduler" "cyclestate.write"
+    (func $cyclestate.write
+      (param $key i32) (param $key_len i32)
+      (param $buf i32) (param $buf_len i32)))
+  (import "k8s.io/scheduler" "cyclestate.delete"
+    (func $cyclestate.delete (param $key i32) (param $key_len i32)))
+
+  ;; Allocate the minimum amount of memory, 1 page (64KB).
+  (memory (export "memory") 1 1)
+
+  (data (i32.const 0) "in")
+  (data (i32.const 8) "out")
+
+  ;; filter moves the value of "in" to "out", returning success if it was
+  ;; found or error if not.
+  (func (export "filter") (result i32)
+    (local $found_len i64)
+    (local.set $found_len
+      (call $cyclestate.read (i32.const 0) (i32.const 2) (i32.const 16) (i32.const 1024)))
+
+    (if (i64.eqz (i64.shr_u (local.get $found_len) (i64.const 32)))
+      (then (return (i32.const 1))))
+
+    (call $cyclestate.write (i32.const 8) (i32.const 3)
+      (i32.const 16) (i32.wrap_i64 (local.get $found_len)))
+    (call $cyclestate.delete (i32.const 0) (i32.const 2))
+    (return (i32.const 0)))
+)
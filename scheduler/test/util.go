@@ -32,10 +32,16 @@ func (f *FakeRecorder) Eventf(regarding runtime.Object, related runtime.Object,
 }
 
 type FakeHandle struct {
-	Recorder              events.EventRecorder
-	RejectWaitingPodValue types.UID
-	SharedLister          framework.SharedLister
-	GetWaitingPodValue    framework.WaitingPod
+	ClientSetValue             clientset.Interface
+	Recorder                   events.EventRecorder
+	RejectWaitingPodValue      types.UID
+	SharedLister               framework.SharedLister
+	GetWaitingPodValue         framework.WaitingPod
+	SharedInformerFactoryValue informers.SharedInformerFactory
+	// NominatedPods are the pods nominated to run on each node name.
+	NominatedPods map[string][]*framework.PodInfo
+	// ActivatedPods are the pods passed to Activate.
+	ActivatedPods map[string]*v1.Pod
 }
 
 func (h *FakeHandle) EventRecorder() events.EventRecorder {
@@ -47,7 +53,10 @@ func (h *FakeHandle) AddNominatedPod(logger klog.Logger, pod *framework.PodInfo,
 }
 
 func (h *FakeHandle) ClientSet() clientset.Interface {
-	panic("unimplemented")
+	if h.ClientSetValue == nil {
+		panic("unimplemented")
+	}
+	return h.ClientSetValue
 }
 
 func (h *FakeHandle) DeleteNominatedPodIfExists(pod *v1.Pod) {
@@ -63,7 +72,10 @@ func (h *FakeHandle) KubeConfig() *restclient.Config {
 }
 
 func (h *FakeHandle) SharedInformerFactory() informers.SharedInformerFactory {
-	panic("unimplemented")
+	if h.SharedInformerFactoryValue == nil {
+		panic("unimplemented")
+	}
+	return h.SharedInformerFactoryValue
 }
 
 func (h *FakeHandle) RunFilterPluginsWithNominatedPods(ctx context.Context, state *framework.CycleState, pod *v1.Pod, info *framework.NodeInfo) (s *framework.Status) {
@@ -129,7 +141,7 @@ func (h *FakeHandle) IterateOverWaitingPods(callback func(framework.WaitingPod))
 }
 
 func (h *FakeHandle) NominatedPodsForNode(nodeName string) (f []*framework.PodInfo) {
-	panic("unimplemented")
+	return h.NominatedPods[nodeName]
 }
 
 func (h *FakeHandle) RejectWaitingPod(uid types.UID) (b bool) {
@@ -166,7 +178,7 @@ func (h *FakeHandle) UpdateNominatedPod(logger klog.Logger, oldPod *v1.Pod, newP
 }
 
 func (h *FakeHandle) Activate(logger klog.Logger, pods map[string]*v1.Pod) {
-	// No-op implementation for testing
+	h.ActivatedPods = pods
 }
 
 func (h *FakeHandle) SharedDRAManager() framework.SharedDRAManager {
@@ -174,7 +186,8 @@ func (h *FakeHandle) SharedDRAManager() framework.SharedDRAManager {
 }
 
 type FakeSharedLister struct {
-	NodeInfoLister framework.NodeInfoLister
+	NodeInfoLister    framework.NodeInfoLister
+	StorageInfoLister framework.StorageInfoLister
 }
 
 func (c *FakeSharedLister) NodeInfos() framework.NodeInfoLister {
@@ -182,7 +195,24 @@ func (c *FakeSharedLister) NodeInfos() framework.NodeInfoLister {
 }
 
 func (c *FakeSharedLister) StorageInfos() framework.StorageInfoLister {
-	panic("unimplemented")
+	if c.StorageInfoLister == nil {
+		panic("unimplemented")
+	}
+	return c.StorageInfoLister
+}
+
+type FakeStorageInfoLister struct {
+	// UsedPVCs are the "namespace/name" keys of PVCs used by pods.
+	UsedPVCs []string
+}
+
+func (c *FakeStorageInfoLister) IsPVCUsedByPods(key string) bool {
+	for _, k := range c.UsedPVCs {
+		if k == key {
+			return true
+		}
+	}
+	return false
 }
 
 type FakeNodeInfoLister struct {